@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -57,6 +58,7 @@ type Bootstrap struct {
 	t     settings.StartupTree
 	agent bool
 	rs    *cache.Cache
+	m     sync.Mutex
 }
 
 func NewBootstrap(agent bool) *Bootstrap {
@@ -415,6 +417,9 @@ func (b *Bootstrap) Second() {
 	//start up all boot services ([boot, end] slice)
 	b.startupServices(settings.AfterBoot, settings.ToTheEnd)
 
+	//services can only be reloaded once they are all booted
+	pm.RegisterBuiltIn(cmdConfigReload, b.reload)
+
 	progress.Text = "Bootstrapping: Done"
 	progress.Leave()
 
@@ -0,0 +1,148 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/settings"
+)
+
+const (
+	cmdConfigReload = "core.config.reload"
+
+	reloadStopTimeout = 30 * time.Second
+)
+
+type reloadArguments struct {
+	DryRun bool `json:"dry_run"`
+}
+
+//reloadPlan is the diff between the running startup services and the reloaded configuration
+type reloadPlan struct {
+	Start   []string `json:"start"`
+	Stop    []string `json:"stop"`
+	Restart []string `json:"restart"`
+	Errors  []string `json:"errors,omitempty"`
+	DryRun  bool     `json:"dry_run"`
+}
+
+//plan builds the reload plan, services are ordered by their weight in the startup tree
+func (b *Bootstrap) plan(included *settings.IncludedSettings, tree settings.StartupTree) *reloadPlan {
+	plan := &reloadPlan{
+		Start:   []string{},
+		Stop:    []string{},
+		Restart: []string{},
+	}
+
+	for _, startup := range tree.Services() {
+		old, ok := b.i.Startup[startup.Key()]
+		if !ok {
+			plan.Start = append(plan.Start, startup.Key())
+		} else if !reflect.DeepEqual(old, startup) {
+			plan.Restart = append(plan.Restart, startup.Key())
+		}
+	}
+
+	//stop in the reverse order, so services are stopped before the ones they depend on
+	services := b.t.Services()
+	for i := len(services) - 1; i >= 0; i-- {
+		key := services[i].Key()
+		if _, ok := included.Startup[key]; !ok {
+			plan.Stop = append(plan.Stop, key)
+		}
+	}
+
+	return plan
+}
+
+func (b *Bootstrap) stop(keys ...string) []string {
+	var errors []string
+	for _, key := range keys {
+		if _, ok := pm.JobOf(key); !ok {
+			//service is not running (exited, or its condition was not met)
+			continue
+		}
+
+		log.Infof("stopping service %s", key)
+		if err := pm.Stop(key, reloadStopTimeout); err != nil {
+			errors = append(errors, fmt.Sprintf("failed to stop %s: %s", key, err))
+		}
+	}
+
+	return errors
+}
+
+//reload re-reads the included configurations and applies the changes to the startup services
+func (b *Bootstrap) reload(cmd *pm.Command) (interface{}, error) {
+	var args reloadArguments
+	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
+		return nil, pm.BadRequestError(err)
+	}
+
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	included, errs := settings.Settings.GetIncludedSettings()
+	tree, treeErrs := included.GetStartupTree()
+	errs = append(errs, treeErrs...)
+
+	plan := b.plan(included, tree)
+	plan.DryRun = args.DryRun
+	for _, err := range errs {
+		plan.Errors = append(plan.Errors, err.Error())
+	}
+
+	if args.DryRun {
+		return plan, nil
+	}
+
+	if len(errs) > 0 {
+		//a broken file would make all its services look removed
+		return nil, pm.BadRequestError(fmt.Errorf("configuration has errors, not reloading: %v", plan.Errors))
+	}
+
+	log.Infof("reloading configuration, start: %v, stop: %v, restart: %v", plan.Start, plan.Stop, plan.Restart)
+
+	restart := make(map[string]struct{})
+	for _, key := range plan.Restart {
+		restart[key] = struct{}{}
+	}
+
+	//stop removed and changed services, in the reverse order of the running tree
+	var stop []string
+	services := b.t.Services()
+	for i := len(services) - 1; i >= 0; i-- {
+		key := services[i].Key()
+		if _, ok := included.Startup[key]; !ok {
+			stop = append(stop, key)
+		} else if _, ok := restart[key]; ok {
+			stop = append(stop, key)
+		}
+	}
+
+	plan.Errors = append(plan.Errors, b.stop(stop...)...)
+
+	start := restart
+	for _, key := range plan.Start {
+		start[key] = struct{}{}
+	}
+
+	var slice settings.StartupSlice
+	for _, startup := range tree.Services() {
+		if _, ok := start[startup.Key()]; ok {
+			slice = append(slice, startup)
+		}
+	}
+
+	b.i = included
+	b.t = tree
+
+	//RunSlice honors the After dependencies inside the slice, the dependencies
+	//outside the slice are already running.
+	pm.RunSlice(slice)
+
+	return plan, nil
+}
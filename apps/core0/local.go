@@ -104,6 +104,23 @@ func (l *Local) server(con net.Conn) {
 		}
 
 		return
+	} else if !lcmd.Sync {
+		//the command keeps its id, so it can be followed (or attached to) by the caller
+		_, err := pm.Run(&pm.Command{
+			Command: "corex.dispatch",
+			Arguments: pm.MustArguments(containers.ContainerDispatchArguments{
+				Container: container.ID(),
+				Command:   *cmd,
+			}),
+		})
+
+		if err != nil {
+			result.Streams = pm.Streams{"", fmt.Sprintf("Failed to dispatch command (%s): %s", cmd.Command, err)}
+			return
+		}
+
+		result.ID = cmd.ID
+		result.State = pm.StateSuccess
 	} else {
 		contjob, err := l.mgr.Dispatch(container.ID(), cmd)
 		if err != nil {
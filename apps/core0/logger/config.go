@@ -1,6 +1,8 @@
 package logger
 
 import (
+	"fmt"
+
 	"github.com/threefoldtech/0-core/base/pm"
 	"github.com/threefoldtech/0-core/base/pm/stream"
 	"github.com/threefoldtech/0-core/base/settings"
@@ -27,13 +29,38 @@ func (l Loggers) LogRecord(record *LogRecord) {
 	}
 }
 
-// ConfigureLogging attachs the correct message handler on top the process manager from the configurations
+// ConfigureLogging attachs the correct message handler on top the process manager from the configurations.
+// Each logger gets its own queue, so a slow logger doesn't block the processes
 func ConfigureLogging(sink *transport.Sink) {
+	logging := settings.Settings.Logging
+
 	Current = append(Current,
-		NewConsoleLogger(settings.Settings.Logging.File.Levels),
-		NewLedisLogger(sink, settings.Settings.Logging.Ledis.Levels, settings.Settings.Logging.Ledis.Size),
-		NewStreamLogger(sink, 0),
+		queued("file", NewConsoleLogger(logging.File.Levels), logging.File),
+		queued("ledis", NewLedisLogger(sink, logging.Ledis.Levels, logging.Ledis.Size), logging.Ledis.Logger),
+		queued("stream", NewStreamLogger(sink, 0), settings.Logger{}),
 	)
 
+	for name, remote := range logging.Remote {
+		logger, err := NewRemoteLogger(name, remote)
+		if err != nil {
+			log.Errorf("failed to configure remote logger %s: %s", name, err)
+			continue
+		}
+
+		Current = append(Current, queued(fmt.Sprintf("remote.%s", name), logger, remote.Logger))
+	}
+
+	if logging.Store.Enabled {
+		logger, err := NewStoreLogger(logging.Store)
+		if err != nil {
+			log.Errorf("failed to configure job logs store: %s", err)
+		} else {
+			Current = append(Current, queued("store", logger, logging.Store.Logger))
+		}
+	}
+
+	pm.RegisterBuiltIn("logger.status", status)
 	pm.AddHandle(Current)
+
+	go Current.stats()
 }
@@ -0,0 +1,375 @@
+package logger
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	segmentExt = ".seg"
+	cursorFile = "cursor"
+	//segmentsCount the buffer is split over this number of segments, when the buffer is full
+	//the oldest segment is dropped
+	segmentsCount = 8
+	//cursorSync the cursor is persisted every cursorSync acks
+	cursorSync = 100
+)
+
+//diskQueue is a bounded fifo queue stored on disk. Entries are appended to segment files, and
+//read back in order. Once the queue reaches its max size, the oldest segment is dropped.
+//The read position is persisted (periodically) so the queue survives a restart, entries
+//may be read twice after a restart.
+type diskQueue struct {
+	dir     string
+	max     int64
+	segment int64
+
+	segments []uint64
+	sizes    map[uint64]int64
+	total    int64
+
+	w     *os.File
+	wseq  uint64
+	wsize int64
+
+	r     *os.File
+	rseq  uint64
+	roff  int64
+	next  int64
+	acks  int
+	m     sync.Mutex
+	c     *sync.Cond
+	close bool
+}
+
+func newDiskQueue(dir string, max int64) (*diskQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	q := &diskQueue{
+		dir:     dir,
+		max:     max,
+		segment: max / segmentsCount,
+		sizes:   make(map[uint64]int64),
+	}
+
+	if q.segment <= 0 {
+		q.segment = 1
+	}
+	q.c = sync.NewCond(&q.m)
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), segmentExt) {
+			continue
+		}
+
+		seq, err := strconv.ParseUint(strings.TrimSuffix(file.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		q.segments = append(q.segments, seq)
+		q.sizes[seq] = file.Size()
+		q.total += file.Size()
+	}
+
+	sort.Slice(q.segments, func(i, j int) bool {
+		return q.segments[i] < q.segments[j]
+	})
+
+	if len(q.segments) == 0 {
+		q.segments = []uint64{0}
+		q.sizes[0] = 0
+	} else if err := q.repair(q.segments[len(q.segments)-1]); err != nil {
+		return nil, err
+	}
+
+	q.wseq = q.segments[len(q.segments)-1]
+	q.wsize = q.sizes[q.wseq]
+	if q.w, err = q.open(q.wseq, os.O_CREATE|os.O_WRONLY|os.O_APPEND); err != nil {
+		return nil, err
+	}
+
+	q.rseq = q.segments[0]
+	q.loadCursor()
+
+	return q, nil
+}
+
+func (q *diskQueue) path(seq uint64) string {
+	return path.Join(q.dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
+
+func (q *diskQueue) open(seq uint64, flags int) (*os.File, error) {
+	return os.OpenFile(q.path(seq), flags, 0644)
+}
+
+//repair truncates a segment after its last complete entry, a crash while writing can leave a
+//partial entry at the end of the write segment
+func (q *diskQueue) repair(seq uint64) error {
+	file, err := q.open(seq, os.O_RDWR)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	size := q.sizes[seq]
+	header := make([]byte, 4)
+
+	var off int64
+	for off+4 <= size {
+		if _, err := file.ReadAt(header, off); err != nil {
+			return err
+		}
+
+		length := int64(binary.BigEndian.Uint32(header))
+		if length > size-off-4 {
+			break
+		}
+
+		off += 4 + length
+	}
+
+	if off == size {
+		return nil
+	}
+
+	log.Warningf("truncating log buffer segment %s to its last complete entry (%d/%d)", q.path(seq), off, size)
+	if err := file.Truncate(off); err != nil {
+		return err
+	}
+
+	q.sizes[seq] = off
+	q.total -= size - off
+	return nil
+}
+
+func (q *diskQueue) loadCursor() {
+	data, err := ioutil.ReadFile(path.Join(q.dir, cursorFile))
+	if err != nil {
+		return
+	}
+
+	var seq uint64
+	var off int64
+	if _, err := fmt.Sscanf(string(data), "%d %d", &seq, &off); err != nil {
+		return
+	}
+
+	size, ok := q.sizes[seq]
+	if !ok || off > size {
+		return
+	}
+
+	//the segments before the cursor were already consumed
+	for q.segments[0] != seq {
+		q.drop()
+	}
+
+	q.roff = off
+}
+
+func (q *diskQueue) saveCursor() {
+	cursor := path.Join(q.dir, cursorFile)
+	data := fmt.Sprintf("%d %d", q.rseq, q.roff)
+	if err := ioutil.WriteFile(cursor+".tmp", []byte(data), 0644); err != nil {
+		log.Errorf("failed to save log buffer cursor: %s", err)
+		return
+	}
+
+	os.Rename(cursor+".tmp", cursor)
+}
+
+//drop removes the oldest segment, and moves the read position to the next segment if
+//it was in the dropped one. It returns true if the read position has changed.
+func (q *diskQueue) drop() bool {
+	seq := q.segments[0]
+	q.segments = q.segments[1:]
+	q.total -= q.sizes[seq]
+	delete(q.sizes, seq)
+	os.Remove(q.path(seq))
+
+	if q.rseq != seq {
+		return false
+	}
+
+	if q.r != nil {
+		q.r.Close()
+		q.r = nil
+	}
+
+	q.rseq, q.roff, q.next = q.segments[0], 0, 0
+	return true
+}
+
+//rotate starts a new write segment
+func (q *diskQueue) rotate() error {
+	q.w.Close()
+	q.wseq++
+	q.wsize = 0
+
+	w, err := q.open(q.wseq, os.O_CREATE|os.O_WRONLY|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+
+	q.w = w
+	q.segments = append(q.segments, q.wseq)
+	q.sizes[q.wseq] = 0
+	return nil
+}
+
+//Push appends an entry to the queue
+func (q *diskQueue) Push(data []byte) error {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	size := int64(len(data) + 4)
+	if q.wsize > 0 && q.wsize+size > q.segment {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+	}
+
+	for q.total+size > q.max && len(q.segments) > 1 {
+		if q.drop() {
+			log.Warningf("log buffer %s is full, dropping oldest records", q.dir)
+		}
+	}
+
+	entry := make([]byte, size)
+	binary.BigEndian.PutUint32(entry, uint32(len(data)))
+	copy(entry[4:], data)
+
+	if _, err := q.w.Write(entry); err != nil {
+		return err
+	}
+
+	q.wsize += size
+	q.sizes[q.wseq] += size
+	q.total += size
+
+	q.c.Broadcast()
+	return nil
+}
+
+//read reads the entry at the read position, it returns io.EOF if there is no more entries
+//in the read segment
+func (q *diskQueue) read() ([]byte, error) {
+	if q.r == nil {
+		r, err := q.open(q.rseq, os.O_RDONLY)
+		if err != nil {
+			return nil, err
+		}
+		q.r = r
+	}
+
+	size := q.sizes[q.rseq]
+	if q.roff >= size {
+		return nil, io.EOF
+	}
+
+	if q.roff+4 > size {
+		return nil, fmt.Errorf("truncated entry header at %d", q.roff)
+	}
+
+	header := make([]byte, 4)
+	if _, err := q.r.ReadAt(header, q.roff); err != nil {
+		return nil, err
+	}
+
+	//the length is not trusted, a corrupted header must not allocate past the segment
+	length := int64(binary.BigEndian.Uint32(header))
+	if length > size-q.roff-4 {
+		return nil, fmt.Errorf("invalid entry length %d at %d", length, q.roff)
+	}
+
+	data := make([]byte, length)
+	if _, err := q.r.ReadAt(data, q.roff+4); err != nil {
+		return nil, err
+	}
+
+	q.next = q.roff + 4 + int64(len(data))
+	return data, nil
+}
+
+//Peek returns the oldest entry in the queue without removing it, it blocks until an entry
+//is available. Ack must be called to move to the next entry.
+func (q *diskQueue) Peek() ([]byte, error) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	for {
+		if q.close {
+			return nil, io.EOF
+		}
+
+		data, err := q.read()
+		if err == nil {
+			return data, nil
+		}
+
+		if err != io.EOF {
+			//corrupted segment, skip what left of it
+			log.Errorf("failed to read log buffer segment %s: %s", q.path(q.rseq), err)
+			q.roff = q.sizes[q.rseq]
+		}
+
+		if q.rseq == q.wseq {
+			q.c.Wait()
+			continue
+		}
+
+		//done with this segment
+		q.drop()
+		q.saveCursor()
+	}
+}
+
+//Ack removes the entry returned by the last Peek
+func (q *diskQueue) Ack() {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	if q.next <= q.roff {
+		return
+	}
+
+	q.roff = q.next
+	q.acks++
+	if q.acks >= cursorSync {
+		q.acks = 0
+		q.saveCursor()
+	}
+}
+
+//Close closes the queue, and persists the read position
+func (q *diskQueue) Close() error {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	q.close = true
+	q.c.Broadcast()
+	q.saveCursor()
+
+	if q.r != nil {
+		q.r.Close()
+	}
+
+	return q.w.Close()
+}
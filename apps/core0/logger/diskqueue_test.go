@@ -0,0 +1,199 @@
+package logger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskqueue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := newDiskQueue(dir, 1024)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	for i := 0; i < 10; i++ {
+		if ok := assert.NoError(t, q.Push([]byte(fmt.Sprintf("record %d", i)))); !ok {
+			t.Fatal()
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		data, err := q.Peek()
+		if ok := assert.NoError(t, err); !ok {
+			t.Fatal()
+		}
+
+		//not acked, so the same record is returned again
+		data, _ = q.Peek()
+		if ok := assert.Equal(t, fmt.Sprintf("record %d", i), string(data)); !ok {
+			t.Error()
+		}
+		q.Ack()
+	}
+
+	//reopen the queue, it continues after the acked records
+	if ok := assert.NoError(t, q.Close()); !ok {
+		t.Fatal()
+	}
+
+	q, err = newDiskQueue(dir, 1024)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer q.Close()
+
+	for i := 5; i < 10; i++ {
+		data, err := q.Peek()
+		if ok := assert.NoError(t, err); !ok {
+			t.Fatal()
+		}
+
+		if ok := assert.Equal(t, fmt.Sprintf("record %d", i), string(data)); !ok {
+			t.Error()
+		}
+		q.Ack()
+	}
+}
+
+func TestDiskQueueFull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskqueue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	//each record takes 14 bytes (10 bytes + 4 bytes header), segments are 16 bytes (a record each)
+	q, err := newDiskQueue(dir, 8*16)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer q.Close()
+
+	for i := 0; i < 20; i++ {
+		if ok := assert.NoError(t, q.Push([]byte(fmt.Sprintf("record %03d", i)))); !ok {
+			t.Fatal()
+		}
+	}
+
+	if ok := assert.True(t, q.total <= q.max); !ok {
+		t.Error()
+	}
+
+	//the oldest records were dropped
+	data, err := q.Peek()
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, "record 011", string(data)); !ok {
+		t.Error()
+	}
+}
+
+func TestDiskQueueRepair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskqueue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := newDiskQueue(dir, 1024)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	for i := 0; i < 3; i++ {
+		if ok := assert.NoError(t, q.Push([]byte(fmt.Sprintf("record %d", i)))); !ok {
+			t.Fatal()
+		}
+	}
+
+	segment := q.path(q.wseq)
+	if ok := assert.NoError(t, q.Close()); !ok {
+		t.Fatal()
+	}
+
+	//a partial entry, like a crash in the middle of a write
+	file, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0644)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	file.Write([]byte{0, 0, 0, 100, 'p', 'a', 'r'})
+	file.Close()
+
+	q, err = newDiskQueue(dir, 1024)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer q.Close()
+
+	if ok := assert.NoError(t, q.Push([]byte("record 3"))); !ok {
+		t.Fatal()
+	}
+
+	for i := 0; i < 4; i++ {
+		data, err := q.Peek()
+		if ok := assert.NoError(t, err); !ok {
+			t.Fatal()
+		}
+
+		if ok := assert.Equal(t, fmt.Sprintf("record %d", i), string(data)); !ok {
+			t.Error()
+		}
+		q.Ack()
+	}
+}
+
+func TestDiskQueueCorrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskqueue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := newDiskQueue(dir, 1024)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	defer q.Close()
+
+	//an entry with a corrupted length in the first segment
+	if ok := assert.NoError(t, q.Push([]byte("corrupted"))); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.NoError(t, q.rotate()); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.NoError(t, q.Push([]byte("record"))); !ok {
+		t.Fatal()
+	}
+
+	file, err := os.OpenFile(q.path(q.segments[0]), os.O_WRONLY, 0644)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+	file.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, 0)
+	file.Close()
+
+	//the corrupted segment is skipped
+	data, err := q.Peek()
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, "record", string(data)); !ok {
+		t.Error()
+	}
+}
@@ -25,8 +25,6 @@ type redisLogger struct {
 	buffer   *stream.Buffer
 	queues   map[string]levels
 	m        sync.RWMutex
-
-	ch chan *LogRecord
 }
 
 // NewRedisLogger creates new redis logger handler
@@ -41,28 +39,26 @@ func NewLedisLogger(sink *transport.Sink, defaults []uint16, size int64) Logger
 		size:     size,
 		buffer:   stream.NewBuffer(MaxStreamRedisQueueSize),
 		queues:   make(map[string]levels),
-		ch:       make(chan *LogRecord, MaxRedisQueueSize),
 	}
 
 	pm.RegisterBuiltIn("logger.subscribe", rl.subscribe)
 	pm.RegisterBuiltIn("logger.unsubscribe", rl.unSubscribe)
 
-	go rl.pusher()
 	return rl
 }
 
+//Accept implements Filter
+func (l *redisLogger) Accept(record *LogRecord) bool {
+	return IsLoggable(l.defaults, record.Message)
+}
+
 func (l *redisLogger) LogRecord(record *LogRecord) {
-	if !IsLoggable(l.defaults, record.Message) {
+	if !l.Accept(record) {
 		return
 	}
-	l.ch <- record
-}
 
-func (l *redisLogger) pusher() {
-	for {
-		if err := l.push(); err != nil {
-			//we don't sleep to avoid blocking the logging channel and to not slow down processes.
-		}
+	if err := l.pushQueues(record); err != nil {
+		log.Errorf("failed to push logs to queue: %s", err)
 	}
 }
 
@@ -195,12 +191,3 @@ func (l *redisLogger) pushQueues(record *LogRecord) error {
 
 	return nil
 }
-
-func (l *redisLogger) push() error {
-	for {
-		record := <-l.ch
-		if err := l.pushQueues(record); err != nil {
-			log.Errorf("failed to push logs to queue: %s", err)
-		}
-	}
-}
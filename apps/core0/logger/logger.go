@@ -40,8 +40,13 @@ func NewConsoleLogger(defaults []uint16) Logger {
 	}
 }
 
+//Accept implements Filter
+func (logger *ConsoleLogger) Accept(record *LogRecord) bool {
+	return IsLoggable(logger.defaults, record.Message)
+}
+
 func (logger *ConsoleLogger) LogRecord(record *LogRecord) {
-	if !IsLoggable(logger.defaults, record.Message) {
+	if !logger.Accept(record) {
 		return
 	}
 
@@ -0,0 +1,218 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/settings"
+)
+
+const (
+	//PolicyDropOldest drops the oldest queued record to make room for the new one when the queue is full
+	PolicyDropOldest = "drop-oldest"
+	//PolicyDropNewest drops the new record when the queue is full
+	PolicyDropNewest = "drop-newest"
+	//PolicyBlock blocks the caller until there is room in the queue
+	PolicyBlock = "block"
+
+	//DefaultQueueSize default size of a logger queue
+	DefaultQueueSize = 1000
+
+	//StateOK logger is processing records
+	StateOK = "ok"
+	//StateStalled logger is stuck processing a record for more than stallTimeout
+	StateStalled = "stalled"
+
+	stallTimeout = 10 * time.Second
+	//statsInterval interval between two reports of the queues counters
+	statsInterval = 30 * time.Second
+)
+
+//Filter is implemented by the loggers that only process some of the records, the rejected records are
+//not queued
+type Filter interface {
+	Accept(record *LogRecord) bool
+}
+
+//QueueStatus status of a logger queue
+type QueueStatus struct {
+	Name      string `json:"name"`
+	Policy    string `json:"policy"`
+	Size      int    `json:"size"`
+	Queued    int    `json:"queued"`
+	Dropped   uint64 `json:"dropped"`
+	Delivered uint64 `json:"delivered"`
+	State     string `json:"state"`
+}
+
+//queue wraps a logger with a bounded queue. The records are delivered to the logger from its own routine,
+//so a slow (or stuck) logger doesn't slow down the processes, or the other loggers.
+type queue struct {
+	name   string
+	logger Logger
+	policy string
+
+	records []*LogRecord
+	head    int
+	count   int
+
+	dropped    uint64
+	delivered  uint64
+	delivering time.Time
+
+	m sync.Mutex
+	c *sync.Cond
+}
+
+//NewQueue wraps the logger with a bounded queue using the given policy, size and policy default
+//to DefaultQueueSize and PolicyDropOldest
+func NewQueue(name string, logger Logger, size int, policy string) (Logger, error) {
+	switch policy {
+	case "":
+		policy = PolicyDropOldest
+	case PolicyDropOldest, PolicyDropNewest, PolicyBlock:
+	default:
+		return nil, fmt.Errorf("unknown queue policy '%s'", policy)
+	}
+
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+
+	q := &queue{
+		name:    name,
+		logger:  logger,
+		policy:  policy,
+		records: make([]*LogRecord, size),
+	}
+
+	q.c = sync.NewCond(&q.m)
+
+	go q.run()
+
+	return q, nil
+}
+
+//LogRecord implements Logger
+func (q *queue) LogRecord(record *LogRecord) {
+	if filter, ok := q.logger.(Filter); ok && !filter.Accept(record) {
+		return
+	}
+
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	size := len(q.records)
+	if q.count == size {
+		switch q.policy {
+		case PolicyBlock:
+			for q.count == size {
+				q.c.Wait()
+			}
+		case PolicyDropNewest:
+			q.dropped++
+			return
+		default:
+			q.records[q.head] = nil
+			q.head = (q.head + 1) % size
+			q.count--
+			q.dropped++
+		}
+	}
+
+	q.records[(q.head+q.count)%size] = record
+	q.count++
+	q.c.Broadcast()
+}
+
+func (q *queue) pop() *LogRecord {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	for q.count == 0 {
+		q.c.Wait()
+	}
+
+	record := q.records[q.head]
+	q.records[q.head] = nil
+	q.head = (q.head + 1) % len(q.records)
+	q.count--
+	q.delivering = time.Now()
+
+	q.c.Broadcast()
+	return record
+}
+
+func (q *queue) run() {
+	for {
+		record := q.pop()
+		q.logger.LogRecord(record)
+
+		q.m.Lock()
+		q.delivered++
+		q.delivering = time.Time{}
+		q.m.Unlock()
+	}
+}
+
+//Status returns the queue counters
+func (q *queue) Status() QueueStatus {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	status := QueueStatus{
+		Name:      q.name,
+		Policy:    q.policy,
+		Size:      len(q.records),
+		Queued:    q.count,
+		Dropped:   q.dropped,
+		Delivered: q.delivered,
+		State:     StateOK,
+	}
+
+	if !q.delivering.IsZero() && time.Since(q.delivering) > stallTimeout {
+		status.State = StateStalled
+	}
+
+	return status
+}
+
+//queued wraps the logger in a queue configured from the logger settings
+func queued(name string, logger Logger, config settings.Logger) Logger {
+	q, err := NewQueue(name, logger, config.QueueSize, config.Policy)
+	if err != nil {
+		log.Errorf("logger %s: %s, using default policy", name, err)
+		q, _ = NewQueue(name, logger, config.QueueSize, PolicyDropOldest)
+	}
+
+	return q
+}
+
+//Status returns the status of all the queued loggers
+func (l Loggers) Status() []QueueStatus {
+	var status []QueueStatus
+	for _, logger := range l {
+		if q, ok := logger.(*queue); ok {
+			status = append(status, q.Status())
+		}
+	}
+
+	return status
+}
+
+//stats reports the queues counters as stats keys
+func (l Loggers) stats() {
+	for range time.Tick(statsInterval) {
+		for _, status := range l.Status() {
+			pm.Aggregate(pm.AggreagteAverage, "logger.queued", float64(status.Queued), status.Name)
+			pm.Aggregate(pm.AggreagteDifference, "logger.dropped", float64(status.Dropped), status.Name)
+			pm.Aggregate(pm.AggreagteDifference, "logger.delivered", float64(status.Delivered), status.Name)
+		}
+	}
+}
+
+func status(cmd *pm.Command) (interface{}, error) {
+	return Current.Status(), nil
+}
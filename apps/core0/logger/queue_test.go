@@ -0,0 +1,159 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-core/base/pm/stream"
+)
+
+//testLogger records the messages it receives, it blocks until released
+type testLogger struct {
+	release  chan struct{}
+	messages []string
+	m        sync.Mutex
+}
+
+func (l *testLogger) LogRecord(record *LogRecord) {
+	<-l.release
+
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.messages = append(l.messages, record.Message.Message)
+}
+
+func (l *testLogger) received() []string {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return append([]string(nil), l.messages...)
+}
+
+func testRecord(i int) *LogRecord {
+	return &LogRecord{
+		Command: "job",
+		Message: &stream.Message{
+			Message: fmt.Sprint(i),
+			Meta:    stream.NewMeta(stream.LevelStdout),
+		},
+	}
+}
+
+func testQueueWait(t *testing.T, q Logger, delivered uint64) {
+	for i := 0; i < 100; i++ {
+		if q.(*queue).Status().Delivered == delivered {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("records were not delivered")
+}
+
+//testQueuePicked waits until the queued records are picked by the delivery routine
+func testQueuePicked(q Logger) {
+	for q.(*queue).Status().Queued != 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQueueDropOldest(t *testing.T) {
+	logger := &testLogger{release: make(chan struct{})}
+	q, err := NewQueue("test", logger, 3, PolicyDropOldest)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	//the first record is being delivered, the logger is stuck
+	q.LogRecord(testRecord(0))
+	testQueuePicked(q)
+
+	for i := 1; i <= 5; i++ {
+		q.LogRecord(testRecord(i))
+	}
+
+	status := q.(*queue).Status()
+	if ok := assert.Equal(t, 3, status.Queued); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, uint64(2), status.Dropped); !ok {
+		t.Error()
+	}
+
+	close(logger.release)
+	testQueueWait(t, q, 4)
+
+	if ok := assert.Equal(t, []string{"0", "3", "4", "5"}, logger.received()); !ok {
+		t.Error()
+	}
+}
+
+func TestQueueDropNewest(t *testing.T) {
+	logger := &testLogger{release: make(chan struct{})}
+	q, err := NewQueue("test", logger, 3, PolicyDropNewest)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	q.LogRecord(testRecord(0))
+	testQueuePicked(q)
+
+	for i := 1; i <= 5; i++ {
+		q.LogRecord(testRecord(i))
+	}
+
+	if ok := assert.Equal(t, uint64(2), q.(*queue).Status().Dropped); !ok {
+		t.Error()
+	}
+
+	close(logger.release)
+	testQueueWait(t, q, 4)
+
+	if ok := assert.Equal(t, []string{"0", "1", "2", "3"}, logger.received()); !ok {
+		t.Error()
+	}
+}
+
+func TestQueueBlock(t *testing.T) {
+	logger := &testLogger{release: make(chan struct{})}
+	q, err := NewQueue("test", logger, 1, PolicyBlock)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			q.LogRecord(testRecord(i))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("queue didn't block")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(logger.release)
+	<-done
+	testQueueWait(t, q, 5)
+
+	if ok := assert.Equal(t, []string{"0", "1", "2", "3", "4"}, logger.received()); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, uint64(0), q.(*queue).Status().Dropped); !ok {
+		t.Error()
+	}
+}
+
+func TestQueueUnknownPolicy(t *testing.T) {
+	_, err := NewQueue("test", &testLogger{}, 1, "unknown")
+	if ok := assert.Error(t, err); !ok {
+		t.Error()
+	}
+}
@@ -0,0 +1,349 @@
+package logger
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/pm/stream"
+	"github.com/threefoldtech/0-core/base/settings"
+)
+
+const (
+	//DefaultRemoteBufferDir is the parent directory of the remote loggers disk buffers
+	DefaultRemoteBufferDir = "/var/cache/core0/logger"
+	//DefaultRemoteBufferSize max size in bytes of a remote logger disk buffer
+	DefaultRemoteBufferSize = 64 * 1024 * 1024
+
+	//maxRecordSize max size of the record message, longer messages are truncated
+	maxRecordSize = 32 * 1024
+	//gelfUDPSize max size of a gelf udp message (chunking is not supported)
+	gelfUDPSize = 8192
+
+	remoteLoggerTimeout  = 10 * time.Second
+	remoteLoggerMaxRetry = 1 * time.Minute
+
+	syslogFacility = 1 //user-level messages
+	syslogSDID     = "job@32473"
+)
+
+var (
+	sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+)
+
+//severity maps a message level to a syslog severity
+func severity(level uint16) int {
+	switch level {
+	case stream.LevelStderr, stream.LevelOpsError:
+		return 3 //error
+	case stream.LevelCritical:
+		return 2 //critical
+	case stream.LevelWarning:
+		return 4 //warning
+	case stream.LevelPublic:
+		return 5 //notice
+	case stream.LevelStatsd, stream.LevelDebug:
+		return 7 //debug
+	default:
+		return 6 //informational
+	}
+}
+
+//remoteRecord is a log record with the information the remote formats need
+type remoteRecord struct {
+	host    string
+	app     string
+	job     string
+	command string
+	core    uint16
+	level   uint16
+	time    time.Time
+	message string
+}
+
+func newRemoteRecord(host string, record *LogRecord) *remoteRecord {
+	r := &remoteRecord{
+		host:    host,
+		app:     "core0",
+		job:     record.Command,
+		core:    record.Core,
+		level:   record.Message.Meta.Level(),
+		message: strings.TrimRight(record.Message.Message, "\n"),
+	}
+
+	if record.Core != 0 {
+		r.app = fmt.Sprintf("corex-%d", record.Core)
+	} else if job, ok := pm.JobOf(record.Command); ok {
+		r.command = job.Command().Command
+	}
+
+	if record.Message.Epoch != 0 {
+		r.time = time.Unix(0, record.Message.Epoch)
+	} else {
+		r.time = time.Now()
+	}
+
+	if len(r.message) > maxRecordSize {
+		r.message = r.message[:maxRecordSize]
+	}
+
+	return r
+}
+
+func nilValue(value string) string {
+	if len(value) == 0 {
+		return "-"
+	}
+
+	return value
+}
+
+//syslog formats the record as an RFC5424 syslog message
+func (r *remoteRecord) syslog() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s - - [%s id=\"%s\"",
+		syslogFacility*8+severity(r.level),
+		r.time.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		nilValue(r.host),
+		r.app,
+		syslogSDID,
+		sdEscaper.Replace(r.job),
+	)
+
+	if len(r.command) != 0 {
+		fmt.Fprintf(&buf, " command=\"%s\"", sdEscaper.Replace(r.command))
+	}
+
+	fmt.Fprintf(&buf, " level=\"%d\" container=\"%d\"] %s", r.level, r.core, r.message)
+	return buf.Bytes()
+}
+
+//gelf formats the record as a GELF 1.1 message
+func (r *remoteRecord) gelf() []byte {
+	short := r.message
+	if i := strings.IndexByte(short, '\n'); i >= 0 {
+		short = short[:i]
+	}
+
+	if len(short) == 0 {
+		short = "-"
+	}
+
+	msg := map[string]interface{}{
+		"version":       "1.1",
+		"host":          r.host,
+		"short_message": short,
+		"timestamp":     float64(r.time.UnixNano()) / float64(time.Second),
+		"level":         severity(r.level),
+		"_job_id":       r.job,
+		"_stream_level": r.level,
+		"_container":    r.core,
+		"_app":          r.app,
+	}
+
+	if short != r.message {
+		msg["full_message"] = r.message
+	}
+
+	if len(r.command) != 0 {
+		msg["_command"] = r.command
+	}
+
+	data, _ := json.Marshal(msg)
+	return data
+}
+
+//remoteLogger ships the log records to a remote syslog or gelf endpoint. Records are written
+//to a disk buffer first, so a slow or unreachable remote never blocks the logging
+type remoteLogger struct {
+	name     string
+	defaults []uint16
+	config   settings.RemoteLogger
+	host     string
+	tls      *tls.Config
+
+	queue *diskQueue
+	conn  net.Conn
+}
+
+//NewRemoteLogger creates a logger that ships the records to the configured remote endpoint
+func NewRemoteLogger(name string, config settings.RemoteLogger) (Logger, error) {
+	switch config.Format {
+	case "syslog", "gelf":
+	default:
+		return nil, fmt.Errorf("unknown format '%s'", config.Format)
+	}
+
+	switch config.Network {
+	case "tcp", "udp", "tls":
+	default:
+		return nil, fmt.Errorf("unknown network '%s'", config.Network)
+	}
+
+	if len(config.Address) == 0 {
+		return nil, fmt.Errorf("address is required")
+	}
+
+	if len(config.Buffer) == 0 {
+		config.Buffer = path.Join(DefaultRemoteBufferDir, name)
+	}
+
+	if config.BufferSize <= 0 {
+		config.BufferSize = DefaultRemoteBufferSize
+	}
+
+	l := &remoteLogger{
+		name:     name,
+		defaults: config.Levels,
+		config:   config,
+	}
+
+	l.host, _ = os.Hostname()
+
+	if config.Network == "tls" {
+		host, _, err := net.SplitHostPort(config.Address)
+		if err != nil {
+			return nil, err
+		}
+
+		l.tls = &tls.Config{ServerName: host}
+		if len(config.CA) != 0 {
+			pem, err := ioutil.ReadFile(config.CA)
+			if err != nil {
+				return nil, err
+			}
+
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in '%s'", config.CA)
+			}
+			l.tls.RootCAs = pool
+		}
+	}
+
+	queue, err := newDiskQueue(config.Buffer, config.BufferSize)
+	if err != nil {
+		return nil, err
+	}
+
+	l.queue = queue
+
+	go l.sender()
+
+	return l, nil
+}
+
+//Accept implements Filter
+func (l *remoteLogger) Accept(record *LogRecord) bool {
+	return IsLoggable(l.defaults, record.Message) && len(record.Message.Message) != 0
+}
+
+//LogRecord implements Logger, the record is written to the disk buffer
+func (l *remoteLogger) LogRecord(record *LogRecord) {
+	if !l.Accept(record) {
+		return
+	}
+
+	if err := l.queue.Push(l.format(record)); err != nil {
+		log.Errorf("failed to buffer log record for remote %s: %s", l.name, err)
+	}
+}
+
+func (l *remoteLogger) format(record *LogRecord) []byte {
+	r := newRemoteRecord(l.host, record)
+	if l.config.Format == "gelf" {
+		data := r.gelf()
+		if l.config.Network == "udp" && len(data) > gelfUDPSize {
+			//chunking is not supported, the message is truncated to fit in a single datagram
+			cut := len(data) - gelfUDPSize
+			if cut > len(r.message) {
+				cut = len(r.message)
+			}
+			r.message = r.message[:len(r.message)-cut]
+			data = r.gelf()
+		}
+
+		return data
+	}
+
+	return r.syslog()
+}
+
+func (l *remoteLogger) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: remoteLoggerTimeout}
+	switch l.config.Network {
+	case "tls":
+		return tls.DialWithDialer(dialer, "tcp", l.config.Address, l.tls)
+	default:
+		return dialer.Dial(l.config.Network, l.config.Address)
+	}
+}
+
+//frame returns the message as it's sent over the connection
+func (l *remoteLogger) frame(data []byte) []byte {
+	if l.config.Network == "udp" {
+		//a message per datagram
+		return data
+	}
+
+	if l.config.Format == "gelf" {
+		//null byte delimited
+		return append(data, 0)
+	}
+
+	//octet counting (RFC 5425 and RFC 6587)
+	return append([]byte(fmt.Sprintf("%d ", len(data))), data...)
+}
+
+func (l *remoteLogger) send(data []byte) error {
+	if l.conn == nil {
+		conn, err := l.dial()
+		if err != nil {
+			return err
+		}
+		l.conn = conn
+	}
+
+	l.conn.SetWriteDeadline(time.Now().Add(remoteLoggerTimeout))
+	if _, err := l.conn.Write(l.frame(data)); err != nil {
+		l.conn.Close()
+		l.conn = nil
+		return err
+	}
+
+	return nil
+}
+
+//sender ships the buffered records to the remote, retrying while the remote is unreachable
+func (l *remoteLogger) sender() {
+	wait := time.Second
+	for {
+		data, err := l.queue.Peek()
+		if err != nil {
+			log.Errorf("failed to read log buffer of remote %s: %s", l.name, err)
+			return
+		}
+
+		if err := l.send(data); err != nil {
+			log.Errorf("failed to send logs to remote %s (retry in %s): %s", l.name, wait, err)
+			<-time.After(wait)
+			wait *= 2
+			if wait > remoteLoggerMaxRetry {
+				wait = remoteLoggerMaxRetry
+			}
+			continue
+		}
+
+		wait = time.Second
+		l.queue.Ack()
+	}
+}
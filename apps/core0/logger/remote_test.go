@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-core/base/pm/stream"
+)
+
+func TestRemoteRecordFormat(t *testing.T) {
+	record := newRemoteRecord("node", &LogRecord{
+		Core:    2,
+		Command: "job-id",
+		Message: &stream.Message{
+			Message: "first line\nsecond \"line\"\n",
+			Epoch:   time.Date(2017, 6, 21, 10, 0, 0, 0, time.UTC).UnixNano(),
+			Meta:    stream.NewMeta(stream.LevelStderr),
+		},
+	})
+
+	expected := "<11>1 2017-06-21T10:00:00.000000Z node corex-2 - - [job@32473 id=\"job-id\" level=\"2\" container=\"2\"] first line\nsecond \"line\""
+	if ok := assert.Equal(t, expected, string(record.syslog())); !ok {
+		t.Error()
+	}
+
+	var gelf map[string]interface{}
+	if err := json.Unmarshal(record.gelf(), &gelf); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok := assert.Equal(t, "first line", gelf["short_message"]); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, "first line\nsecond \"line\"", gelf["full_message"]); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, "job-id", gelf["_job_id"]); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, float64(3), gelf["level"]); !ok {
+		t.Error()
+	}
+}
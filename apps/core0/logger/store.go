@@ -0,0 +1,623 @@
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/pm/stream"
+	"github.com/threefoldtech/0-core/base/settings"
+)
+
+const (
+	//DefaultStoreDir root directory of the job logs store
+	DefaultStoreDir = "/var/log/core0/jobs"
+	//DefaultStoreMaxSize size after which a job log file is rotated
+	DefaultStoreMaxSize = 10 * 1024 * 1024
+	//DefaultStoreMaxAge age after which a job log file is rotated
+	DefaultStoreMaxAge = 24 * time.Hour
+	//DefaultStoreMaxFiles max number of rotated files kept per job
+	DefaultStoreMaxFiles = 10
+	//DefaultStoreRetention time after which rotated files and logs of finished jobs are deleted
+	DefaultStoreRetention = 7 * 24 * time.Hour
+
+	//DefaultQueryLimit default page size of the logger.query results
+	DefaultQueryLimit = 100
+	//MaxQueryLimit max page size of the logger.query results
+	MaxQueryLimit = 10000
+
+	currentLog = "current.log"
+	logExt     = ".log"
+	gzipExt    = ".gz"
+
+	//storeIdle time after which the log file of an idle job is closed
+	storeIdle = time.Minute
+	//storeCleanup interval between two runs of the retention cleanup
+	storeCleanup = time.Hour
+)
+
+type storeFile struct {
+	file    *os.File
+	size    int64
+	created time.Time
+	used    time.Time
+}
+
+//storeLogger writes the log records of each job to its own files under the store directory
+//(<dir>/<core>/<job-id>/). The files are rotated when they get too big or too old, and the
+//rotated files are optionally compressed. Logs can be queried with the logger.query builtin.
+type storeLogger struct {
+	defaults  []uint16
+	config    settings.LogStore
+	maxAge    time.Duration
+	retention time.Duration
+
+	files map[string]*storeFile
+	m     sync.Mutex
+}
+
+//NewStoreLogger creates a logger that persists the job logs on disk
+func NewStoreLogger(config settings.LogStore) (Logger, error) {
+	if len(config.Dir) == 0 {
+		config.Dir = DefaultStoreDir
+	}
+
+	if config.MaxSize <= 0 {
+		config.MaxSize = DefaultStoreMaxSize
+	}
+
+	if config.MaxFiles <= 0 {
+		config.MaxFiles = DefaultStoreMaxFiles
+	}
+
+	l := &storeLogger{
+		defaults:  config.Levels,
+		config:    config,
+		maxAge:    time.Duration(config.MaxAge) * time.Second,
+		retention: time.Duration(config.Retention) * time.Second,
+		files:     make(map[string]*storeFile),
+	}
+
+	if l.maxAge <= 0 {
+		l.maxAge = DefaultStoreMaxAge
+	}
+
+	if l.retention <= 0 {
+		l.retention = DefaultStoreRetention
+	}
+
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	pm.RegisterBuiltIn("logger.query", l.query)
+
+	go l.maintain()
+
+	return l, nil
+}
+
+//Accept implements Filter
+func (l *storeLogger) Accept(record *LogRecord) bool {
+	return IsLoggable(l.defaults, record.Message) && len(record.Command) != 0
+}
+
+//LogRecord implements Logger
+func (l *storeLogger) LogRecord(record *LogRecord) {
+	if !l.Accept(record) {
+		return
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if err := l.write(record); err != nil {
+		log.Errorf("failed to store log record of job %s: %s", record.Command, err)
+	}
+}
+
+//escape makes the job id safe to use as a directory name
+func escape(id string) string {
+	id = url.PathEscape(id)
+	if id == "." || id == ".." {
+		id = strings.Replace(id, ".", "%2E", -1)
+	}
+
+	return id
+}
+
+func (l *storeLogger) jobDir(core uint16, id string) string {
+	return path.Join(l.config.Dir, fmt.Sprint(core), escape(id))
+}
+
+//maintain closes the idle files, and runs the retention cleanup periodically
+func (l *storeLogger) maintain() {
+	sweep := time.NewTicker(storeIdle)
+	cleanup := time.NewTicker(storeCleanup)
+
+	l.m.Lock()
+	l.cleanup()
+	l.m.Unlock()
+
+	for {
+		select {
+		case <-sweep.C:
+			l.m.Lock()
+			l.sweep()
+			l.m.Unlock()
+		case <-cleanup.C:
+			l.m.Lock()
+			l.cleanup()
+			l.m.Unlock()
+		}
+	}
+}
+
+//created gets the creation time of a log file from its first record
+func created(file *os.File) time.Time {
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil {
+		return time.Now()
+	}
+
+	var record LogRecord
+	if err := json.Unmarshal(line, &record); err != nil || record.Message == nil {
+		return time.Now()
+	}
+
+	return time.Unix(0, record.Message.Epoch)
+}
+
+func (l *storeLogger) open(dir string) (*storeFile, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path.Join(dir, currentLog), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	f := &storeFile{
+		file:    file,
+		size:    info.Size(),
+		created: time.Now(),
+	}
+
+	if f.size > 0 {
+		f.created = created(file)
+	}
+
+	return f, nil
+}
+
+func (l *storeLogger) write(record *LogRecord) error {
+	dir := l.jobDir(record.Core, record.Command)
+	file, ok := l.files[dir]
+	if !ok {
+		var err error
+		if file, err = l.open(dir); err != nil {
+			return err
+		}
+		l.files[dir] = file
+	}
+
+	if file.size >= l.config.MaxSize || (file.size > 0 && time.Since(file.created) >= l.maxAge) {
+		if err := l.rotate(dir); err != nil {
+			return err
+		}
+
+		return l.write(record)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	n, err := file.file.Write(append(data, '\n'))
+	file.size += int64(n)
+	file.used = time.Now()
+
+	if record.Message.Meta.Is(stream.ExitSuccessFlag | stream.ExitErrorFlag) {
+		//job exited, no more logs are expected
+		l.close(dir)
+	}
+
+	return err
+}
+
+func (l *storeLogger) close(dir string) {
+	if file, ok := l.files[dir]; ok {
+		file.file.Close()
+		delete(l.files, dir)
+	}
+}
+
+//rotate moves the current log file of the job to a rotated file named after the rotation time
+func (l *storeLogger) rotate(dir string) error {
+	l.close(dir)
+
+	name := path.Join(dir, fmt.Sprintf("%020d%s", time.Now().UnixNano(), logExt))
+	if err := os.Rename(path.Join(dir, currentLog), name); err != nil {
+		return err
+	}
+
+	if l.config.Compress {
+		go func() {
+			if err := compress(name); err != nil {
+				log.Errorf("failed to compress log file %s: %s", name, err)
+			}
+		}()
+	}
+
+	//drop the oldest rotated files
+	files, err := rotated(dir)
+	if err != nil {
+		return err
+	}
+
+	for len(files) > l.config.MaxFiles {
+		remove(files[0])
+		files = files[1:]
+	}
+
+	return nil
+}
+
+//compress compresses a rotated file, the original file is removed once done
+func compress(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	tmp := name + gzipExt + ".tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp)
+	defer dst.Close()
+
+	writer := gzip.NewWriter(dst)
+	if _, err := io.Copy(writer, src); err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, name+gzipExt); err != nil {
+		return err
+	}
+
+	return os.Remove(name)
+}
+
+//remove removes a rotated file, and its compressed version
+func remove(name string) {
+	os.Remove(name)
+	os.Remove(name + gzipExt)
+}
+
+//rotated lists the rotated files of a job (oldest first), a file that is being compressed is only
+//returned once (by its uncompressed name)
+func rotated(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{})
+	var files []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), gzipExt)
+		if name == currentLog || !strings.HasSuffix(name, logExt) {
+			continue
+		}
+
+		if _, ok := seen[name]; ok {
+			continue
+		}
+
+		seen[name] = struct{}{}
+		files = append(files, path.Join(dir, name))
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+//sweep closes the log files of the idle jobs
+func (l *storeLogger) sweep() {
+	for dir, file := range l.files {
+		if time.Since(file.used) >= storeIdle {
+			l.close(dir)
+		}
+	}
+}
+
+//cleanup deletes the rotated files, and the logs of the finished jobs older than the retention time
+func (l *storeLogger) cleanup() {
+	cores, err := ioutil.ReadDir(l.config.Dir)
+	if err != nil {
+		log.Errorf("failed to list job logs: %s", err)
+		return
+	}
+
+	for _, core := range cores {
+		if !core.IsDir() {
+			continue
+		}
+
+		jobs, err := ioutil.ReadDir(path.Join(l.config.Dir, core.Name()))
+		if err != nil {
+			continue
+		}
+
+		for _, job := range jobs {
+			dir := path.Join(l.config.Dir, core.Name(), job.Name())
+			if _, ok := l.files[dir]; ok {
+				//job is still logging, only its rotated files can be deleted
+				files, _ := rotated(dir)
+				for _, file := range files {
+					if info, err := os.Stat(file); err == nil && time.Since(info.ModTime()) >= l.retention {
+						remove(file)
+					}
+				}
+
+				continue
+			}
+
+			entries, err := ioutil.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+
+			left := len(entries)
+			for _, entry := range entries {
+				if time.Since(entry.ModTime()) >= l.retention {
+					if os.Remove(path.Join(dir, entry.Name())) == nil {
+						left--
+					}
+				}
+			}
+
+			if left == 0 {
+				os.Remove(dir)
+			}
+		}
+	}
+}
+
+//StoreQuery filter of the logger.query builtin
+type StoreQuery struct {
+	ID     string   `json:"id"`
+	Core   uint16   `json:"core"`
+	Levels []uint16 `json:"levels"`
+	//Start, End time range (epoch seconds) of the returned records
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"`
+	Regex  string `json:"regex"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+}
+
+//StoreQueryResult is a page of logger.query results
+type StoreQueryResult struct {
+	Records []*LogRecord `json:"records"`
+	//Next offset of the next page
+	Next int `json:"next"`
+	//More is true if there are more records after this page
+	More bool `json:"more"`
+}
+
+type recordFilter struct {
+	levels     map[uint16]struct{}
+	start, end int64
+	regex      *regexp.Regexp
+}
+
+func (f *recordFilter) match(record *LogRecord) bool {
+	msg := record.Message
+	if msg == nil {
+		return false
+	}
+
+	if len(f.levels) > 0 {
+		if _, ok := f.levels[msg.Meta.Level()]; !ok {
+			return false
+		}
+	}
+
+	if msg.Epoch < f.start || (f.end != 0 && msg.Epoch > f.end) {
+		return false
+	}
+
+	if f.regex != nil && !f.regex.MatchString(msg.Message) {
+		return false
+	}
+
+	return true
+}
+
+func openLog(name string) (io.ReadCloser, error) {
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		//compressed in the mean time
+		file, err = os.Open(name + gzipExt)
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(file.Name(), gzipExt) {
+		return file, nil
+	}
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{reader, file}, nil
+}
+
+//scan calls fn with each record of the log file that matches the filter, it stops when fn returns false
+func scan(name string, filter *recordFilter, fn func(*LogRecord) bool) (bool, error) {
+	file, err := openLog(name)
+	if os.IsNotExist(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var record LogRecord
+			if json.Unmarshal(line, &record) == nil && filter.match(&record) {
+				if !fn(&record) {
+					return false, nil
+				}
+			}
+		}
+
+		if err == io.EOF {
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+	}
+}
+
+func (l *storeLogger) query(cmd *pm.Command) (interface{}, error) {
+	var args StoreQuery
+	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
+		return nil, err
+	}
+
+	if len(args.ID) == 0 {
+		return nil, pm.BadRequestError(fmt.Errorf("id is required"))
+	}
+
+	if args.End != 0 && args.End < args.Start {
+		return nil, pm.BadRequestError(fmt.Errorf("end must be after start"))
+	}
+
+	if args.Offset < 0 {
+		return nil, pm.BadRequestError(fmt.Errorf("offset must be positive"))
+	}
+
+	if args.Limit <= 0 {
+		args.Limit = DefaultQueryLimit
+	} else if args.Limit > MaxQueryLimit {
+		args.Limit = MaxQueryLimit
+	}
+
+	filter := recordFilter{
+		levels: make(map[uint16]struct{}),
+		start:  args.Start * int64(time.Second),
+	}
+
+	if args.End != 0 {
+		//end second is inclusive
+		filter.end = (args.End+1)*int64(time.Second) - 1
+	}
+
+	for _, level := range args.Levels {
+		filter.levels[level] = struct{}{}
+	}
+
+	if len(args.Regex) != 0 {
+		regex, err := regexp.Compile(args.Regex)
+		if err != nil {
+			return nil, pm.BadRequestError(err)
+		}
+		filter.regex = regex
+	}
+
+	dir := l.jobDir(args.Core, args.ID)
+	files, err := rotated(dir)
+	if os.IsNotExist(err) {
+		return nil, pm.NotFoundError(fmt.Errorf("no logs found for job '%s'", args.ID))
+	} else if err != nil {
+		return nil, pm.InternalError(err)
+	}
+
+	files = append(files, path.Join(dir, currentLog))
+
+	result := StoreQueryResult{
+		Records: []*LogRecord{},
+		Next:    args.Offset,
+	}
+
+	skip := args.Offset
+	for _, file := range files {
+		if filter.start != 0 && file != path.Join(dir, currentLog) {
+			//rotated files are named after their rotation time, so they only have older records
+			if rotation, err := strconv.ParseInt(strings.TrimSuffix(path.Base(file), logExt), 10, 64); err == nil && rotation < filter.start {
+				continue
+			}
+		}
+
+		more, err := scan(file, &filter, func(record *LogRecord) bool {
+			if skip > 0 {
+				skip--
+				return true
+			}
+
+			if len(result.Records) == args.Limit {
+				result.More = true
+				return false
+			}
+
+			result.Records = append(result.Records, record)
+			result.Next++
+			return true
+		})
+
+		if err != nil {
+			return nil, pm.InternalError(err)
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,180 @@
+package logger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/pm/stream"
+	"github.com/threefoldtech/0-core/base/settings"
+)
+
+func testStore(t *testing.T, config settings.LogStore) (*storeLogger, func()) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Dir = dir
+	store := &storeLogger{
+		config:    config,
+		maxAge:    DefaultStoreMaxAge,
+		retention: DefaultStoreRetention,
+		files:     make(map[string]*storeFile),
+	}
+
+	return store, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func testRecords(t *testing.T, store *storeLogger, count int) {
+	start := time.Date(2017, 6, 21, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < count; i++ {
+		level := stream.LevelStdout
+		if i%2 == 1 {
+			level = stream.LevelStderr
+		}
+
+		err := store.write(&LogRecord{
+			Command: "job",
+			Message: &stream.Message{
+				Message: fmt.Sprintf("line %03d", i),
+				Epoch:   start.Add(time.Duration(i) * time.Second).UnixNano(),
+				Meta:    stream.NewMeta(level),
+			},
+		})
+
+		if ok := assert.NoError(t, err); !ok {
+			t.Fatal()
+		}
+	}
+}
+
+func testQuery(t *testing.T, store *storeLogger, query StoreQuery) StoreQueryResult {
+	result, err := store.query(&pm.Command{Arguments: pm.MustArguments(query)})
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	return result.(StoreQueryResult)
+}
+
+func TestStoreQuery(t *testing.T) {
+	store, clean := testStore(t, settings.LogStore{MaxSize: 1024, MaxFiles: 100, Compress: true})
+	defer clean()
+
+	testRecords(t, store, 100)
+
+	files, err := rotated(store.jobDir(0, "job"))
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.NotEmpty(t, files); !ok {
+		t.Error()
+	}
+
+	//all records are returned in order, across the rotated files
+	result := testQuery(t, store, StoreQuery{ID: "job", Limit: 1000})
+	if ok := assert.Len(t, result.Records, 100); !ok {
+		t.Fatal()
+	}
+
+	for i, record := range result.Records {
+		if ok := assert.Equal(t, fmt.Sprintf("line %03d", i), record.Message.Message); !ok {
+			t.Fatal()
+		}
+	}
+
+	//paging
+	result = testQuery(t, store, StoreQuery{ID: "job", Offset: 90, Limit: 5})
+	if ok := assert.Len(t, result.Records, 5); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, "line 090", result.Records[0].Message.Message); !ok {
+		t.Error()
+	}
+
+	if ok := assert.True(t, result.More); !ok {
+		t.Error()
+	}
+
+	result = testQuery(t, store, StoreQuery{ID: "job", Offset: result.Next, Limit: 5})
+	if ok := assert.Len(t, result.Records, 5); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.False(t, result.More); !ok {
+		t.Error()
+	}
+
+	//filters
+	start := time.Date(2017, 6, 21, 10, 0, 0, 0, time.UTC).Unix()
+	result = testQuery(t, store, StoreQuery{
+		ID:     "job",
+		Levels: []uint16{stream.LevelStderr},
+		Start:  start + 10,
+		End:    start + 19,
+		Regex:  `line 01[0-5]`,
+	})
+
+	var lines []string
+	for _, record := range result.Records {
+		lines = append(lines, record.Message.Message)
+	}
+
+	if ok := assert.Equal(t, []string{"line 011", "line 013", "line 015"}, lines); !ok {
+		t.Error()
+	}
+}
+
+func TestStoreRotate(t *testing.T) {
+	store, clean := testStore(t, settings.LogStore{MaxSize: 256, MaxFiles: 2})
+	defer clean()
+
+	testRecords(t, store, 100)
+
+	files, err := rotated(store.jobDir(0, "job"))
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Len(t, files, 2); !ok {
+		t.Error()
+	}
+
+	//only the records of the kept files are returned
+	result := testQuery(t, store, StoreQuery{ID: "job"})
+	if ok := assert.NotEmpty(t, result.Records); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, "line 099", result.Records[len(result.Records)-1].Message.Message); !ok {
+		t.Error()
+	}
+
+	if ok := assert.NotEqual(t, "line 000", result.Records[0].Message.Message); !ok {
+		t.Error()
+	}
+}
+
+func TestStoreQueryNotFound(t *testing.T) {
+	store, clean := testStore(t, settings.LogStore{})
+	defer clean()
+
+	_, err := store.query(&pm.Command{Arguments: pm.MustArguments(StoreQuery{ID: "../job"})})
+	if ok := assert.Error(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, uint32(http.StatusNotFound), err.(pm.RunError).Code()); !ok {
+		t.Error()
+	}
+}
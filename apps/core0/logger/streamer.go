@@ -17,8 +17,6 @@ const (
 type streamLogger struct {
 	sink *transport.Sink
 	size int64
-
-	ch chan *LogRecord
 }
 
 // NewRedisLogger creates new redis logger handler
@@ -27,50 +25,43 @@ func NewStreamLogger(db *transport.Sink, size int64) Logger {
 		size = MaxStreamRedisQueueSize
 	}
 
-	rl := &streamLogger{
+	return &streamLogger{
 		sink: db,
 		size: size,
-		ch:   make(chan *LogRecord, MaxStreamRedisQueueSize),
 	}
+}
 
-	go rl.pusher()
-	return rl
+//Accept implements Filter
+func (l *streamLogger) Accept(record *LogRecord) bool {
+	//only records with the stream flag set
+	return record.Message.Meta.Is(stream.StreamFlag)
 }
 
 func (l *streamLogger) LogRecord(record *LogRecord) {
-	if !record.Message.Meta.Is(stream.StreamFlag) {
-		//stream flag is not set
+	if !l.Accept(record) {
 		return
 	}
 
-	l.ch <- record
-}
-
-func (l *streamLogger) pusher() {
-	for {
-		if err := l.push(); err != nil {
-			//we don't sleep to avoid blocking the logging channel and to not slow down processes.
-		}
+	if err := l.push(record); err != nil {
+		log.Errorf("failed to push stream of job %s: %s", record.Command, err)
 	}
 }
 
-func (l *streamLogger) push() error {
-	for {
-		record := <-l.ch
-		bytes, err := json.Marshal(record)
-		if err != nil {
-			continue
-		}
-
-		queue := fmt.Sprintf("stream:%s", record.Command)
-		if _, err := l.sink.RPush(queue, bytes); err != nil {
-			return err
-		}
+func (l *streamLogger) push(record *LogRecord) error {
+	bytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
 
-		if err := l.sink.LTrim(queue, -1*l.size, -1); err != nil {
-			return err
-		}
+	queue := fmt.Sprintf("stream:%s", record.Command)
+	if _, err := l.sink.RPush(queue, bytes); err != nil {
+		return err
+	}
 
-		l.sink.LExpire(queue, StreamRedisQueueTTL)
+	if err := l.sink.LTrim(queue, -1*l.size, -1); err != nil {
+		return err
 	}
+
+	l.sink.LExpire(queue, StreamRedisQueueTTL)
+	return nil
 }
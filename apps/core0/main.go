@@ -166,12 +166,26 @@ func main() {
 	var config = settings.Settings
 
 	pm.MaxJobs = config.Main.MaxJobs
+	policy, err := pm.NewWebhookPolicy(config.Webhook.Allow, config.Webhook.Deny)
+	if err != nil {
+		log.Fatalf("invalid webhook policy: %s", err)
+	}
+	pm.SetWebhookPolicy(policy)
 
-	//start process mgr.
-	log.Infof("Starting process manager")
+	for name, queue := range config.Queue {
+		pm.ConfigureQueue(name, queue.Width, queue.Priority)
+	}
+
+	if config.Main.Journal != "" {
+		journal, err := pm.NewFileJournal(config.Main.Journal)
+		if err != nil {
+			log.Errorf("failed to open jobs journal: %s", err)
+		} else {
+			pm.SetJournal(journal)
+		}
+	}
 
 	pm.AddHandle((*console)(nil))
-	pm.Start()
 
 	//configure logging handlers from configurations
 	log.Infof("Configure logging")
@@ -183,6 +197,17 @@ func main() {
 
 	logger.ConfigureLogging(sink)
 
+	//the cgroups add the jobs limits handler, it's needed by the restored jobs
+	if err := cgroups.Init(); err != nil {
+		log.Fatal("failed to initialize cgroups subsystem")
+	}
+
+	//start process mgr. (after the result handlers are registered, so results
+	//of jobs lost during a restart can be forwarded)
+	log.Infof("Starting process manager")
+	pm.Start()
+	pm.Replay()
+
 	bs := bootstrap.NewBootstrap(options.Agent())
 	bs.First()
 
@@ -199,21 +224,27 @@ func main() {
 	}
 	screen.Push(row)
 
-	if err := cgroups.Init(); err != nil {
-		log.Fatal("failed to initialize cgroups subsystem")
-	}
-
 	contMgr, err := containers.ContainerSubsystem(sink, &row.Cells[0])
 	if err != nil {
 		log.Fatal("failed to intialize container subsystem", err)
 	}
 
+	//restore the jobs of the extensions and the containers commands, before the startup
+	//services are started, so the restored services are not started again
+	pm.Replay()
+
 	bs.Second()
 
 	if err := kvm.KVMSubsystem(contMgr, &row.Cells[1]); err != nil {
 		log.Errorf("failed to initialize kvm subsystem: %s", err)
 	}
 
+	//all the commands are registered by now
+	pm.ReplayDone()
+
+	//the networking is ready, recreate the persisted containers
+	go contMgr.Recreate()
+
 	log.Infof("Starting local transport")
 	local, err := NewLocal(contMgr, "/var/run/core.sock")
 	if err != nil {
@@ -228,10 +259,52 @@ func main() {
 	sink.Start()
 	screen.Refresh()
 
+	api := transport.NewAPI(sink)
+	if len(config.API.Socket) != 0 {
+		go func() {
+			if err := api.ListenUnix(config.API.Socket); err != nil {
+				log.Errorf("failed to serve api on %s: %s", config.API.Socket, err)
+			}
+		}()
+	}
+
+	if len(config.API.Listen) != 0 {
+		go func() {
+			if err := api.ListenTLS(config.API.Listen, config.API.Cert, config.API.Key, config.API.ClientCA); err != nil {
+				log.Errorf("failed to serve api on %s: %s", config.API.Listen, err)
+			}
+		}()
+	}
+
+	statsConfig := stats.Config{
+		Periods: config.Stats.Periods,
+		History: config.Stats.History,
+		TTL:     time.Duration(config.Stats.TTL) * time.Second,
+	}
+
+	var aggregator stats.Aggregator
 	if config.Stats.Enabled {
-		aggregator := stats.NewLedisStatsAggregator(sink)
+		aggregator = stats.NewLedisStatsAggregator(sink, statsConfig)
 		pm.AddHandle(aggregator)
 	}
 
+	if len(config.Stats.Remote) != 0 {
+		if exporter, err := stats.NewRemoteExporter(statsConfig, config.Stats.Remote); err != nil {
+			log.Errorf("failed to configure stats remotes: %s", err)
+		} else {
+			pm.AddHandle(exporter)
+		}
+	}
+
+	if config.Stats.Listen != "" {
+		log.Infof("Starting metrics exporter on %s", config.Stats.Listen)
+		go func() {
+			exporter := stats.NewExporter(aggregator)
+			if err := exporter.ListenAndServe(config.Stats.Listen); err != nil {
+				log.Errorf("metrics exporter error: %s", err)
+			}
+		}()
+	}
+
 	select {}
 }
@@ -0,0 +1,186 @@
+package stats
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/threefoldtech/0-core/base/settings"
+)
+
+const (
+	remoteTimeout = 10 * time.Second
+	//udpPayload max size of a udp datagram payload, so it's not fragmented
+	udpPayload = 1400
+)
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	graphiteEscaper          = strings.NewReplacer(" ", "_", ";", "_", "~", "_")
+)
+
+type formatter func(buf *bytes.Buffer, prefix string, update *Update)
+
+//lineBackend writes the updates formatted as text lines over a network connection
+type lineBackend struct {
+	network string
+	address string
+	prefix  string
+	format  formatter
+	//packet max size of a single write (0 for no limit)
+	packet int
+
+	conn net.Conn
+}
+
+func newLineBackend(network string, remote settings.StatsRemote, format formatter) (Backend, error) {
+	if len(remote.Address) == 0 {
+		return nil, fmt.Errorf("address is required")
+	}
+
+	backend := &lineBackend{
+		network: network,
+		address: remote.Address,
+		prefix:  remote.Prefix,
+		format:  format,
+	}
+
+	if network == "udp" {
+		backend.packet = udpPayload
+	}
+
+	return backend, nil
+}
+
+func newInfluxUDPBackend(remote settings.StatsRemote) (Backend, error) {
+	return newLineBackend("udp", remote, influxLine)
+}
+
+func newInfluxTCPBackend(remote settings.StatsRemote) (Backend, error) {
+	return newLineBackend("tcp", remote, influxLine)
+}
+
+func newGraphiteBackend(remote settings.StatsRemote) (Backend, error) {
+	return newLineBackend("tcp", remote, graphiteLines)
+}
+
+func (b *lineBackend) send(data []byte) error {
+	if b.conn == nil {
+		conn, err := net.DialTimeout(b.network, b.address, remoteTimeout)
+		if err != nil {
+			return err
+		}
+		b.conn = conn
+	}
+
+	b.conn.SetWriteDeadline(time.Now().Add(remoteTimeout))
+	if _, err := b.conn.Write(data); err != nil {
+		//reconnect on next write
+		b.conn.Close()
+		b.conn = nil
+		return err
+	}
+
+	return nil
+}
+
+//Write implements Backend
+func (b *lineBackend) Write(updates []*Update) error {
+	var buf bytes.Buffer
+	for _, update := range updates {
+		mark := buf.Len()
+		b.format(&buf, b.prefix, update)
+
+		if b.packet > 0 && buf.Len() > b.packet && mark > 0 {
+			//send what fits in a packet, and keep the last lines for the next one
+			if err := b.send(buf.Bytes()[:mark]); err != nil {
+				return err
+			}
+
+			rest := append([]byte(nil), buf.Bytes()[mark:]...)
+			buf.Reset()
+			buf.Write(rest)
+		}
+	}
+
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	return b.send(buf.Bytes())
+}
+
+func float(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func sortedTags(update *Update) []string {
+	var keys []string
+	for k := range update.Tags {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+//influxLine formats the update in the influxdb line protocol, the tags are added to the period tag
+//and all the sample values are fields of the same line
+func influxLine(buf *bytes.Buffer, prefix string, update *Update) {
+	buf.WriteString(influxMeasurementEscaper.Replace(prefix + update.Key))
+	for _, k := range sortedTags(update) {
+		v := update.Tags[k]
+		if len(v) == 0 {
+			continue
+		}
+		fmt.Fprintf(buf, ",%s=%s", influxTagEscaper.Replace(k), influxTagEscaper.Replace(v))
+	}
+
+	fmt.Fprintf(buf, ",period=%d", update.Period)
+
+	s := update.Sample
+	fmt.Fprintf(buf, " avg=%s,max=%s,min=%s,total=%s,p50=%s,p90=%s,p99=%s,count=%di %d\n",
+		float(s.Avg), float(s.Max), float(s.Min), float(s.Total),
+		float(s.P50), float(s.P90), float(s.P99), s.Count,
+		s.Start*int64(time.Second),
+	)
+}
+
+//graphiteLines formats the update as graphite tagged plaintext metrics, one per sample value
+func graphiteLines(buf *bytes.Buffer, prefix string, update *Update) {
+	var tags string
+	for _, k := range sortedTags(update) {
+		v := update.Tags[k]
+		if len(v) == 0 {
+			continue
+		}
+		tags += fmt.Sprintf(";%s=%s", graphiteEscaper.Replace(k), graphiteEscaper.Replace(v))
+	}
+
+	tags += fmt.Sprintf(";period=%d", update.Period)
+
+	name := graphiteEscaper.Replace(prefix + update.Key)
+	s := update.Sample
+	values := []struct {
+		field string
+		value float64
+	}{
+		{"avg", s.Avg},
+		{"max", s.Max},
+		{"min", s.Min},
+		{"total", s.Total},
+		{"p50", s.P50},
+		{"p90", s.P90},
+		{"p99", s.P99},
+		{"count", float64(s.Count)},
+	}
+
+	for _, v := range values {
+		fmt.Fprintf(buf, "%s.%s%s %s %d\n", name, v.field, tags, float(v.value), s.Start)
+	}
+}
@@ -0,0 +1,201 @@
+package stats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/threefoldtech/0-core/base/pm"
+)
+
+const (
+	//MetricsPath is the http path of the metrics exporter
+	MetricsPath = "/metrics"
+
+	openMetricsType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	textMetricsType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+//Exporter renders the aggregated stats and the jobs process stats in the OpenMetrics text format, or the
+//prometheus text format if OpenMetrics is not accepted by the scraper
+type Exporter struct {
+	aggregator Aggregator
+}
+
+//NewExporter creates a new metrics exporter, aggregator can be nil (stats are disabled) in that case only
+//the jobs stats are exported
+func NewExporter(aggregator Aggregator) *Exporter {
+	return &Exporter{aggregator: aggregator}
+}
+
+//metricName converts a stats key to a valid metric name
+func metricName(key string) string {
+	name := invalidNameChars.ReplaceAllString(key, "_")
+	if len(name) > 0 && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+
+	return name
+}
+
+func labels(tags []pm.Tag) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	var pairs []string
+	for _, tag := range tags {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, metricName(tag.Key), labelEscaper.Replace(tag.Value)))
+	}
+
+	sort.Strings(pairs)
+	return fmt.Sprintf("{%s}", strings.Join(pairs, ","))
+}
+
+type family struct {
+	name    string
+	kind    string
+	samples []string
+}
+
+type families struct {
+	index map[string]*family
+	order []*family
+}
+
+func (f *families) add(name, kind, sample string) {
+	if f.index == nil {
+		f.index = make(map[string]*family)
+	}
+
+	fam, ok := f.index[name]
+	if !ok {
+		fam = &family{name: name, kind: kind}
+		f.index[name] = fam
+		f.order = append(f.order, fam)
+	} else if fam.kind != kind {
+		//same key reported with a different operation, can't be part of the same family
+		log.Warningf("metric %s is reported as both %s and %s", name, fam.kind, kind)
+		return
+	}
+
+	fam.samples = append(fam.samples, sample)
+}
+
+//write writes the families in the OpenMetrics format, or in the prometheus text format (0.0.4) if
+//openMetrics is false
+func (f *families) write(w io.Writer, openMetrics bool) {
+	sort.Slice(f.order, func(i, j int) bool {
+		return f.order[i].name < f.order[j].name
+	})
+
+	for _, fam := range f.order {
+		name := fam.name
+		if fam.kind == "counter" && !openMetrics {
+			//the text format has no suffixes, the family is named after its samples
+			name += "_total"
+		}
+
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, fam.kind)
+		sort.Strings(fam.samples)
+		for _, sample := range fam.samples {
+			fmt.Fprintln(w, sample)
+		}
+	}
+}
+
+//addStates adds the aggregated keys, average keys are exported as gauges, and differential keys as
+//counters with their last reported (not differentiated) value
+func (f *families) addStates(states []*State) {
+	for _, state := range states {
+		if state.LastTime == -1 {
+			continue
+		}
+
+		name := metricName(state.Key)
+		switch state.Operation {
+		case Differential:
+			f.add(name, "counter", fmt.Sprintf("%s_total%s %v", name, labels(state.Tags), state.LastValue))
+		default:
+			f.add(name, "gauge", fmt.Sprintf("%s%s %v", name, labels(state.Tags), state.LastValue))
+		}
+	}
+}
+
+//addJobs adds the process stats of the running jobs
+func (f *families) addJobs(jobs map[string]pm.Job) {
+	for id, job := range jobs {
+		stater, ok := job.Process().(pm.Stater)
+		if !ok {
+			continue
+		}
+
+		stats := stater.Stats()
+		if stats == nil {
+			continue
+		}
+
+		tags := labels([]pm.Tag{
+			{Key: "id", Value: id},
+			{Key: "command", Value: job.Command().Command},
+		})
+
+		f.add("job_cpu_percent", "gauge", fmt.Sprintf("job_cpu_percent%s %v", tags, stats.CPU))
+		f.add("job_rss_bytes", "gauge", fmt.Sprintf("job_rss_bytes%s %v", tags, stats.RSS))
+		f.add("job_vms_bytes", "gauge", fmt.Sprintf("job_vms_bytes%s %v", tags, stats.VMS))
+		f.add("job_swap_bytes", "gauge", fmt.Sprintf("job_swap_bytes%s %v", tags, stats.Swap))
+	}
+}
+
+//ServeHTTP implements http.Handler
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var f families
+	if e.aggregator != nil {
+		f.addStates(e.aggregator.States())
+	}
+
+	f.addJobs(pm.Jobs())
+
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", openMetricsType)
+	} else {
+		w.Header().Set("Content-Type", textMetricsType)
+	}
+
+	buf := bufio.NewWriter(w)
+	f.write(buf, openMetrics)
+	if openMetrics {
+		fmt.Fprintln(buf, "# EOF")
+	}
+	buf.Flush()
+}
+
+//ListenAndServe starts the metrics http listener on the given address, the exporter has no
+//authentication so an address without a host (ex: :9100) only binds the loopback interface
+func (e *Exporter) ListenAndServe(listen string) error {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return err
+	}
+
+	if len(host) == 0 {
+		listen = net.JoinHostPort("127.0.0.1", port)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, e)
+
+	return http.ListenAndServe(listen, mux)
+}
@@ -0,0 +1,96 @@
+package stats
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-core/base/pm"
+)
+
+func TestExporterStates(t *testing.T) {
+	cpu := NewState(Average, Periods...)
+	cpu.Key = "machine.CPU.percent"
+	cpu.Tags = []pm.Tag{{Key: "id", Value: "1"}, {Key: "type", Value: `phy"sical`}}
+	cpu.FeedOn(100, 10)
+
+	rx := NewState(Differential, Periods...)
+	rx.Key = "network.packets.rx"
+	rx.Tags = []pm.Tag{{Key: "id", Value: "eth0"}}
+	rx.FeedOn(100, 1000)
+
+	//never fed
+	empty := NewState(Average, Periods...)
+	empty.Key = "empty"
+
+	var f families
+	f.addStates([]*State{cpu, rx, empty})
+
+	var buf bytes.Buffer
+	f.write(&buf, true)
+
+	expected := `# TYPE machine_CPU_percent gauge
+machine_CPU_percent{id="1",type="phy\"sical"} 10
+# TYPE network_packets_rx counter
+network_packets_rx_total{id="eth0"} 1000
+`
+
+	if ok := assert.Equal(t, expected, buf.String()); !ok {
+		t.Error()
+	}
+
+	//the prometheus text format names the counter family after its samples
+	buf.Reset()
+	f.write(&buf, false)
+
+	expected = `# TYPE machine_CPU_percent gauge
+machine_CPU_percent{id="1",type="phy\"sical"} 10
+# TYPE network_packets_rx_total counter
+network_packets_rx_total{id="eth0"} 1000
+`
+
+	if ok := assert.Equal(t, expected, buf.String()); !ok {
+		t.Error()
+	}
+}
+
+func TestExporterFormat(t *testing.T) {
+	exporter := NewExporter(nil)
+
+	request := httptest.NewRequest(http.MethodGet, MetricsPath, nil)
+	response := httptest.NewRecorder()
+	exporter.ServeHTTP(response, request)
+
+	if ok := assert.Equal(t, textMetricsType, response.Header().Get("Content-Type")); !ok {
+		t.Error()
+	}
+
+	if ok := assert.NotContains(t, response.Body.String(), "# EOF"); !ok {
+		t.Error()
+	}
+
+	request.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	response = httptest.NewRecorder()
+	exporter.ServeHTTP(response, request)
+
+	if ok := assert.Equal(t, openMetricsType, response.Header().Get("Content-Type")); !ok {
+		t.Error()
+	}
+
+	if ok := assert.True(t, strings.HasSuffix(response.Body.String(), "# EOF\n")); !ok {
+		t.Error()
+	}
+}
+
+func TestExporterMetricName(t *testing.T) {
+	if ok := assert.Equal(t, "disk_iops_read", metricName("disk.iops-read")); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, "_1m_load", metricName("1m.load")); !ok {
+		t.Error()
+	}
+}
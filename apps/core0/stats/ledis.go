@@ -19,6 +19,9 @@ const (
 	StateKey           = "state:%s:%s"
 	KeyIdSep           = ":"
 	IDTag              = "id"
+
+	//DefaultTTL time after which a key that is no longer reported is dropped
+	DefaultTTL = 1 * time.Hour
 )
 
 var (
@@ -57,15 +60,61 @@ type Stats struct {
 	Tags      []pm.Tag  `json:"tags"`
 }
 
+//Aggregator is a stats handler that keeps the aggregated state of the stats keys
+type Aggregator interface {
+	pm.StatsHandler
+	//States returns the current state of all the aggregated keys
+	States() []*State
+}
+
+//Config of the stats aggregator, zero values are replaced with the defaults
+type Config struct {
+	//Periods aggregation periods in seconds (default to Periods)
+	Periods []int64
+	//History number of aggregated samples kept per period (default to HistoryLength)
+	History int
+	//TTL time after which a key that is no longer reported is dropped (default to DefaultTTL)
+	TTL time.Duration
+}
+
 type redisStatsBuffer struct {
-	db    *transport.Sink
-	cache *cache.Cache
+	db     *transport.Sink
+	cache  *cache.Cache
+	config Config
 }
 
-func NewLedisStatsAggregator(sink *transport.Sink) pm.StatsHandler {
+//defaults replaces the config zero (or invalid) values with the defaults
+func (c *Config) defaults() {
+	var periods []int64
+	for _, period := range c.Periods {
+		if period <= 0 {
+			log.Errorf("ignoring invalid stats period: %d", period)
+			continue
+		}
+		periods = append(periods, period)
+	}
+
+	c.Periods = periods
+	if len(c.Periods) == 0 {
+		c.Periods = Periods
+	}
+
+	if c.History <= 0 {
+		c.History = HistoryLength
+	}
+
+	if c.TTL <= 0 {
+		c.TTL = DefaultTTL
+	}
+}
+
+func NewLedisStatsAggregator(sink *transport.Sink, config Config) Aggregator {
+	config.defaults()
+
 	redisBuffer := &redisStatsBuffer{
-		db:    sink,
-		cache: cache.New(1*time.Hour, 5*time.Minute),
+		db:     sink,
+		cache:  cache.New(config.TTL, 5*time.Minute),
+		config: config,
 	}
 
 	redisBuffer.cache.OnEvicted(func(key string, _ interface{}) {
@@ -87,36 +136,31 @@ type Point struct {
 
 func (r *redisStatsBuffer) query(cmd *pm.Command) (interface{}, error) {
 	var filter struct {
-		Key  string            `json:"key"`
-		Tags map[string]string `json:"tags"`
+		Key     string            `json:"key"`
+		Tags    map[string]string `json:"tags"`
+		Start   int64             `json:"start"`
+		End     int64             `json:"end"`
+		Periods []int64           `json:"periods"`
 	}
 
 	if err := json.Unmarshal(*cmd.Arguments, &filter); err != nil {
 		return nil, err
 	}
 
+	if filter.End != 0 && filter.End < filter.Start {
+		return nil, pm.BadRequestError(fmt.Errorf("end must be after start"))
+	}
+
 	result := make(map[string]*State)
 
-	for key := range r.cache.Items() {
-		parts := strings.SplitN(key, KeyIdSep, 3) //formated as `StateKey`
-		metric := parts[1]
+	for _, state := range r.States() {
+		metric := state.Key
 		if len(filter.Key) != 0 {
 			if filter.Key != metric {
 				continue
 			}
 		}
 
-		data, err := r.db.Get(key)
-		if err != nil {
-			log.Errorf("failed to get state for metric: %s", key)
-			continue
-		}
-
-		state, err := LoadState(data)
-		if err != nil {
-			log.Errorf("failed to load stat for %s", key)
-		}
-
 		//filter on tags
 		m := true
 		for k, v := range filter.Tags {
@@ -144,24 +188,83 @@ func (r *redisStatsBuffer) query(cmd *pm.Command) (interface{}, error) {
 			}
 		}
 
-		result[metric] = state
+		result[metric] = state.Range(filter.Start, filter.End, filter.Periods...)
 	}
 
 	return result, nil
 }
 
-func (r *redisStatsBuffer) hash(tags []pm.Tag) string {
+//States implements Aggregator
+func (r *redisStatsBuffer) States() []*State {
+	var states []*State
+	for key := range r.cache.Items() {
+		parts := strings.SplitN(key, KeyIdSep, 3) //formated as `StateKey`
+
+		data, err := r.db.Get(key)
+		if err != nil {
+			log.Errorf("failed to get state for metric: %s", key)
+			continue
+		}
+
+		if data == nil {
+			//not fed yet
+			continue
+		}
+
+		state, err := LoadState(data)
+		if err != nil {
+			log.Errorf("failed to load stat for %s", key)
+			continue
+		}
+
+		state.Key = parts[1]
+		states = append(states, state)
+	}
+
+	return states
+}
+
+func hash(tags []pm.Tag) string {
 	sort.Sort(Tags(tags))
 	return fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%v", tags))))
 }
 
-func (r *redisStatsBuffer) Stats(op string, key string, value float64, id string, tags ...pm.Tag) {
+//stateKey returns the internal key of the state of a metric key and its tags (the id is added to the tags)
+func stateKey(key string, id string, tags []pm.Tag) (string, []pm.Tag) {
 	if len(id) != 0 {
 		tags = append(tags, pm.Tag{IDTag, id})
 	}
 
-	hash := r.hash(tags)
-	internal := fmt.Sprintf(StateKey, key, hash)
+	return fmt.Sprintf(StateKey, key, hash(tags)), tags
+}
+
+//points returns the points of the flushed samples of a state
+func points(key string, state *State, updates Samples) map[int64]*Point {
+	result := make(map[int64]*Point)
+	for period, sample := range updates {
+		if sample.Start == 0 {
+			//undefined sample
+			continue
+		}
+
+		p := &Point{
+			Sample: sample,
+			Key:    key,
+			Tags:   make(map[string]string),
+		}
+
+		for _, tag := range state.Tags {
+			p.Tags[tag.Key] = tag.Value
+		}
+
+		result[period] = p
+	}
+
+	return result
+}
+
+func (r *redisStatsBuffer) Stats(op string, key string, value float64, id string, tags ...pm.Tag) {
+	internal, tags := stateKey(key, id, tags)
 
 	//touch key in cache so we know we are tracking this key
 	r.cache.Set(internal, nil, cache.DefaultExpiration)
@@ -174,34 +277,21 @@ func (r *redisStatsBuffer) Stats(op string, key string, value float64, id string
 
 	var state *State
 	if data == nil {
-		state = NewState(Operation(op), Periods...)
+		state = NewState(Operation(op), r.config.Periods...)
 	} else if state, err = LoadState(data); err != nil {
 		log.Errorf("failed to load state object for %s: %s", key, err)
 		return
 	}
 
+	state.configure(r.config.History, r.config.Periods...)
+
 	if len(tags) != 0 {
 		state.Tags = tags
 	}
 
-	for period, sample := range state.Feed(value) {
-		if sample.Start == 0 {
-			//undefined sample
-			continue
-		}
-
+	for period, p := range points(key, state, state.Feed(value)) {
 		queue := fmt.Sprintf(StatisticsQueueKey, period)
-		p := Point{
-			Sample: sample,
-			Key:    key,
-			Tags:   make(map[string]string),
-		}
-
-		for _, tag := range state.Tags {
-			p.Tags[tag.Key] = tag.Value
-		}
-
-		if data, err := json.Marshal(&p); err == nil {
+		if data, err := json.Marshal(p); err == nil {
 			r.db.RPush(queue, data)
 		} else {
 			log.Errorf("statistics point marshal error: %s", err)
@@ -0,0 +1,238 @@
+package stats
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/settings"
+)
+
+const (
+	//DefaultRemoteBuffer max number of updates kept per remote while it's unreachable
+	DefaultRemoteBuffer = 10000
+	//DefaultRemoteBatch max number of updates sent to a remote at once
+	DefaultRemoteBatch = 500
+	//DefaultRemoteInterval time between two flushes of a remote
+	DefaultRemoteInterval = 10 * time.Second
+
+	//maxRetryInterval max time between two attempts to push to an unreachable remote
+	maxRetryInterval = 5 * time.Minute
+)
+
+//Update is a flushed sample of a metric for an aggregation period
+type Update struct {
+	*Point
+	Period int64
+}
+
+//Backend writes updates to a remote time-series backend
+type Backend interface {
+	Write(updates []*Update) error
+}
+
+//BackendFactory creates a backend for a remote configuration
+type BackendFactory func(remote settings.StatsRemote) (Backend, error)
+
+var (
+	backends = map[string]BackendFactory{
+		"influx-udp": newInfluxUDPBackend,
+		"influx-tcp": newInfluxTCPBackend,
+		"graphite":   newGraphiteBackend,
+	}
+)
+
+//RegisterBackend registers a backend factory for a remote protocol
+func RegisterBackend(protocol string, factory BackendFactory) {
+	backends[protocol] = factory
+}
+
+//remote buffers the updates of a backend, and flushes them periodically. If the backend is unreachable
+//the updates are kept (up to size, the oldest are dropped) and retried later.
+type remote struct {
+	name     string
+	backend  Backend
+	size     int
+	batch    int
+	interval time.Duration
+
+	queue []*Update
+	//head absolute index of the first update in the queue
+	head    uint64
+	dropped uint64
+	m       sync.Mutex
+}
+
+func newRemote(name string, backend Backend, size, batch int, interval time.Duration) *remote {
+	if size <= 0 {
+		size = DefaultRemoteBuffer
+	}
+
+	if batch <= 0 {
+		batch = DefaultRemoteBatch
+	}
+
+	if interval <= 0 {
+		interval = DefaultRemoteInterval
+	}
+
+	return &remote{
+		name:     name,
+		backend:  backend,
+		size:     size,
+		batch:    batch,
+		interval: interval,
+	}
+}
+
+func (r *remote) push(updates ...*Update) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.queue = append(r.queue, updates...)
+	if over := len(r.queue) - r.size; over > 0 {
+		if r.dropped == 0 {
+			log.Warningf("stats remote %s buffer is full, dropping oldest updates", r.name)
+		}
+
+		r.queue = r.queue[over:]
+		r.head += uint64(over)
+		r.dropped += uint64(over)
+	}
+}
+
+//flush writes all the queued updates in batches, it stops on the first write error. The updates that
+//were not written are kept in the queue
+func (r *remote) flush() error {
+	for {
+		r.m.Lock()
+		n := len(r.queue)
+		if n > r.batch {
+			n = r.batch
+		}
+
+		if n == 0 {
+			r.m.Unlock()
+			return nil
+		}
+
+		batch := make([]*Update, n)
+		copy(batch, r.queue)
+		end := r.head + uint64(n)
+		r.m.Unlock()
+
+		if err := r.backend.Write(batch); err != nil {
+			return err
+		}
+
+		r.m.Lock()
+		//some of the batch updates could have been dropped while writing
+		if end > r.head {
+			r.queue = r.queue[end-r.head:]
+			r.head = end
+		}
+		r.m.Unlock()
+	}
+}
+
+func (r *remote) run() {
+	wait := r.interval
+	for {
+		<-time.After(wait)
+		if err := r.flush(); err != nil {
+			wait *= 2
+			if wait > maxRetryInterval {
+				wait = maxRetryInterval
+			}
+
+			log.Errorf("failed to push stats to remote %s (retry in %s): %s", r.name, wait, err)
+			continue
+		}
+
+		wait = r.interval
+
+		r.m.Lock()
+		if r.dropped != 0 {
+			log.Warningf("stats remote %s dropped %d updates", r.name, r.dropped)
+			r.dropped = 0
+		}
+		r.m.Unlock()
+	}
+}
+
+//remoteExporter aggregates the stats like the aggregator, and pushes the flushed samples to the remotes
+type remoteExporter struct {
+	config  Config
+	cache   *cache.Cache
+	m       sync.Mutex
+	remotes []*remote
+}
+
+//NewRemoteExporter creates a stats handler that pushes the aggregated samples to the configured remotes
+func NewRemoteExporter(config Config, remotes map[string]settings.StatsRemote) (pm.StatsHandler, error) {
+	config.defaults()
+
+	exporter := &remoteExporter{
+		config: config,
+		cache:  cache.New(config.TTL, 5*time.Minute),
+	}
+
+	for name, cfg := range remotes {
+		factory, ok := backends[cfg.Protocol]
+		if !ok {
+			return nil, fmt.Errorf("stats remote %s: unknown protocol '%s'", name, cfg.Protocol)
+		}
+
+		backend, err := factory(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("stats remote %s: %s", name, err)
+		}
+
+		exporter.remotes = append(exporter.remotes,
+			newRemote(name, backend, cfg.Buffer, cfg.Batch, time.Duration(cfg.Interval)*time.Second),
+		)
+	}
+
+	for _, remote := range exporter.remotes {
+		go remote.run()
+	}
+
+	return exporter, nil
+}
+
+//Stats implements pm.StatsHandler
+func (e *remoteExporter) Stats(op string, key string, value float64, id string, tags ...pm.Tag) {
+	internal, tags := stateKey(key, id, tags)
+
+	e.m.Lock()
+	var state *State
+	if cached, ok := e.cache.Get(internal); ok {
+		state = cached.(*State)
+	} else {
+		state = NewState(Operation(op), e.config.Periods...)
+		//only the flushed samples are needed
+		state.configure(1, e.config.Periods...)
+	}
+
+	if len(tags) != 0 {
+		state.Tags = tags
+	}
+
+	e.cache.Set(internal, state, cache.DefaultExpiration)
+
+	var updates []*Update
+	for period, p := range points(key, state, state.Feed(value)) {
+		updates = append(updates, &Update{Point: p, Period: period})
+	}
+	e.m.Unlock()
+
+	if len(updates) == 0 {
+		return
+	}
+
+	for _, remote := range e.remotes {
+		remote.push(updates...)
+	}
+}
@@ -0,0 +1,100 @@
+package stats
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testBackend struct {
+	fail    int
+	written []*Update
+}
+
+func (b *testBackend) Write(updates []*Update) error {
+	if b.fail > 0 {
+		b.fail--
+		return fmt.Errorf("remote is down")
+	}
+
+	b.written = append(b.written, updates...)
+	return nil
+}
+
+func testUpdate(start int64) *Update {
+	return &Update{
+		Point: &Point{
+			Sample: &Sample{Avg: 1.5, Max: 2, Min: 1, Total: 3, P50: 1, P90: 2, P99: 2, Count: 2, Start: start},
+			Key:    "network.packets.rx",
+			Tags:   map[string]string{"id": "eth0", "type": "phys ical"},
+		},
+		Period: 300,
+	}
+}
+
+func TestRemoteRetry(t *testing.T) {
+	backend := &testBackend{fail: 2}
+	r := newRemote("test", backend, 5, 2, 0)
+
+	for i := int64(1); i <= 7; i++ {
+		r.push(testUpdate(i))
+	}
+
+	//the 2 oldest are dropped
+	if ok := assert.Len(t, r.queue, 5); !ok {
+		t.Fatal()
+	}
+
+	for i := 0; i < 2; i++ {
+		if ok := assert.Error(t, r.flush()); !ok {
+			t.Fatal()
+		}
+	}
+
+	if ok := assert.Len(t, r.queue, 5); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.NoError(t, r.flush()); !ok {
+		t.Fatal()
+	}
+
+	var starts []int64
+	for _, update := range backend.written {
+		starts = append(starts, update.Start)
+	}
+
+	if ok := assert.Equal(t, []int64{3, 4, 5, 6, 7}, starts); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Empty(t, r.queue); !ok {
+		t.Error()
+	}
+}
+
+func TestInfluxLine(t *testing.T) {
+	var buf bytes.Buffer
+	influxLine(&buf, "zos.", testUpdate(1500000000))
+
+	expected := `zos.network.packets.rx,id=eth0,type=phys\ ical,period=300 avg=1.5,max=2,min=1,total=3,p50=1,p90=2,p99=2,count=2i 1500000000000000000` + "\n"
+	if ok := assert.Equal(t, expected, buf.String()); !ok {
+		t.Error()
+	}
+}
+
+func TestGraphiteLines(t *testing.T) {
+	var buf bytes.Buffer
+	graphiteLines(&buf, "", testUpdate(1500000000))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if ok := assert.Len(t, lines, 8); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, "network.packets.rx.avg;id=eth0;type=phys_ical;period=300 1.5 1500000000", string(lines[0])); !ok {
+		t.Error()
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"github.com/threefoldtech/0-core/base/pm"
 	"math"
+	"math/rand"
+	"sort"
 	"time"
 )
 
@@ -12,6 +14,8 @@ const (
 	Differential Operation = "D"
 
 	HistoryLength = 5
+	//ReservoirSize max number of values kept per sample to estimate the percentiles
+	ReservoirSize = 100
 )
 
 type Operation string
@@ -20,8 +24,45 @@ type Sample struct {
 	Avg   float64 `json:"avg"`
 	Total float64 `json:"total"`
 	Max   float64 `json:"max"`
+	Min   float64 `json:"min"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
 	Count uint    `json:"count"`
 	Start int64   `json:"start"`
+	//Reservoir is a uniform random sample of the period values used to estimate the percentiles,
+	//only kept for the current period. It's persisted with the state, but it's internal to the
+	//aggregation and stripped from the ranges returned to the clients
+	Reservoir []float64 `json:"reservoir,omitempty"`
+}
+
+//percentile of sorted values (nearest rank)
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+func (m *Sample) sample(value float64) {
+	if len(m.Reservoir) < ReservoirSize {
+		m.Reservoir = append(m.Reservoir, value)
+	} else if i := rand.Int63n(int64(m.Count)); i < ReservoirSize {
+		m.Reservoir[i] = value
+	}
+
+	sorted := append([]float64(nil), m.Reservoir...)
+	sort.Float64s(sorted)
+
+	m.P50 = percentile(sorted, 50)
+	m.P90 = percentile(sorted, 90)
+	m.P99 = percentile(sorted, 99)
 }
 
 /*
@@ -34,12 +75,17 @@ func (m *Sample) Feed(value float64, now int64, duration int64) *Sample {
 	if period != 0 && m.Start < period {
 		//start a new period
 		update := *m
-
-		m.Total = value
-		m.Avg = value
-		m.Max = value
-		m.Count = 1
-		m.Start = period
+		update.Reservoir = nil
+
+		*m = Sample{
+			Total: value,
+			Avg:   value,
+			Max:   value,
+			Min:   value,
+			Count: 1,
+			Start: period,
+		}
+		m.sample(value)
 
 		return &update
 	}
@@ -48,12 +94,18 @@ func (m *Sample) Feed(value float64, now int64, duration int64) *Sample {
 		m.Start = period
 	}
 
+	if m.Count == 0 || value > m.Max {
+		m.Max = value
+	}
+
+	if m.Count == 0 || value < m.Min {
+		m.Min = value
+	}
+
 	m.Total += value
 	m.Count += 1
 	m.Avg = m.Total / float64(m.Count)
-	if value > m.Max {
-		m.Max = value
-	}
+	m.sample(value)
 
 	return nil
 }
@@ -62,12 +114,16 @@ type Samples map[int64]*Sample
 type History map[int64][]Sample
 
 type State struct {
+	Key       string    `json:"-"`
 	Operation Operation `json:"op"`
 	LastValue float64   `json:"last_value"`
 	LastTime  int64     `json:"last_time"`
 	Tags      []pm.Tag  `json:"tags,omitempty"`
 	Current   Samples   `json:"current"`
 	History   History   `json:"history"`
+
+	//history max number of samples kept per period
+	history int
 }
 
 func NewState(op Operation, durations ...int64) *State {
@@ -90,6 +146,96 @@ func LoadState(data []byte) (*State, error) {
 	return &state, json.Unmarshal(data, &state)
 }
 
+//configure makes sure the state aggregates exactly the given periods (a state can be loaded from
+//a previous configuration) and keeps history samples per period
+func (s *State) configure(history int, periods ...int64) {
+	s.history = history
+
+	keep := make(map[int64]struct{})
+	for _, d := range periods {
+		keep[d] = struct{}{}
+		if _, ok := s.Current[d]; !ok {
+			s.Current[d] = &Sample{}
+		}
+	}
+
+	for d := range s.Current {
+		if _, ok := keep[d]; !ok {
+			delete(s.Current, d)
+			delete(s.History, d)
+		}
+	}
+
+	if history > 0 {
+		for d, his := range s.History {
+			if len(his) > history {
+				s.History[d] = his[len(his)-history:]
+			}
+		}
+	}
+}
+
+//Range returns a copy of the state with only the samples of the given periods (all if not set) that
+//overlap with the time range [start, end], an end of 0 means now. The samples of the copy have no reservoir.
+func (s *State) Range(start, end int64, periods ...int64) *State {
+	inRange := func(d int64, sample *Sample) bool {
+		if start == 0 && end == 0 {
+			return true
+		}
+
+		if sample.Start == 0 {
+			return false
+		}
+
+		return sample.Start+d > start && (end == 0 || sample.Start <= end)
+	}
+
+	selected := func(d int64) bool {
+		if len(periods) == 0 {
+			return true
+		}
+
+		for _, p := range periods {
+			if p == d {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	r := *s
+	r.Current = Samples{}
+	r.History = History{}
+
+	for d, sample := range s.Current {
+		if selected(d) && inRange(d, sample) {
+			current := *sample
+			current.Reservoir = nil
+			r.Current[d] = &current
+		}
+	}
+
+	for d, his := range s.History {
+		if !selected(d) {
+			continue
+		}
+
+		var samples []Sample
+		for i := range his {
+			if inRange(d, &his[i]) {
+				samples = append(samples, his[i])
+			}
+		}
+
+		if len(samples) != 0 {
+			r.History[d] = samples
+		}
+	}
+
+	return &r
+}
+
 func (s *State) avg(now int64, value float64) {
 	for d, sample := range s.Current {
 		sample.Feed(value, now, d)
@@ -110,10 +256,15 @@ func (s *State) log(period int64, sample *Sample) {
 		return
 	}
 
+	length := s.history
+	if length <= 0 {
+		length = HistoryLength
+	}
+
 	his := s.History[period]
 	his = append(his, *sample)
-	if len(his) > HistoryLength {
-		his = his[len(his)-HistoryLength : len(his)]
+	if len(his) > length {
+		his = his[len(his)-length : len(his)]
 	}
 
 	s.History[period] = his
@@ -144,3 +144,119 @@ func TestStateDiff(t *testing.T) {
 		t.Fatal()
 	}
 }
+
+func TestSampleMinPercentiles(t *testing.T) {
+	var s Sample
+	var duration int64 = 1000
+
+	for i := 1; i <= 100; i++ {
+		s.Feed(float64(i), int64(i), duration)
+	}
+
+	if !assert.Equal(t, 1., s.Min) {
+		t.Fail()
+	}
+
+	if !assert.Equal(t, 100., s.Max) {
+		t.Fail()
+	}
+
+	if !assert.Equal(t, 50., s.P50) {
+		t.Fail()
+	}
+
+	if !assert.Equal(t, 90., s.P90) {
+		t.Fail()
+	}
+
+	if !assert.Equal(t, 99., s.P99) {
+		t.Fail()
+	}
+
+	//closing the period drops the reservoir from the closed sample
+	update := s.Feed(-5, duration, duration)
+	if !assert.NotNil(t, update) {
+		t.Fatal()
+	}
+
+	if !assert.Nil(t, update.Reservoir) {
+		t.Fail()
+	}
+
+	if !assert.Equal(t, -5., s.Min) || !assert.Equal(t, -5., s.Max) || !assert.Equal(t, -5., s.P50) {
+		t.Fail()
+	}
+}
+
+func TestStateHistory(t *testing.T) {
+	var p int64 = 10
+	state := NewState(Average, p, p*10)
+	state.configure(3, p, p*2)
+
+	if !assert.Len(t, state.Current, 2) {
+		t.Fatal()
+	}
+
+	if _, ok := state.Current[p*2]; !assert.True(t, ok) {
+		t.Fail()
+	}
+
+	for i := int64(0); i <= p*10; i += p {
+		state.FeedOn(i, float64(i))
+	}
+
+	if !assert.Len(t, state.History[p], 3) {
+		t.Fail()
+	}
+
+	if !assert.Equal(t, p*9, state.History[p][2].Start) {
+		t.Fail()
+	}
+}
+
+func TestStateRange(t *testing.T) {
+	var p int64 = 10
+	state := NewState(Average, p, p*5)
+	state.configure(100, p, p*5)
+
+	for i := int64(0); i <= p*10; i += 5 {
+		state.FeedOn(i, float64(i))
+	}
+
+	//samples that overlap with [25, 45] from the 10 seconds period only
+	r := state.Range(25, 45, p)
+	if !assert.Len(t, r.Current, 0) || !assert.Len(t, r.History, 1) {
+		t.Fatal()
+	}
+
+	var starts []int64
+	for _, sample := range r.History[p] {
+		starts = append(starts, sample.Start)
+	}
+
+	if !assert.Equal(t, []int64{20, 30, 40}, starts) {
+		t.Fail()
+	}
+
+	//no range returns everything
+	r = state.Range(0, 0)
+	if !assert.Len(t, r.Current, 2) || !assert.Len(t, r.History, 2) {
+		t.Fail()
+	}
+
+	//the reservoirs are not returned
+	for _, sample := range r.Current {
+		if !assert.Nil(t, sample.Reservoir) {
+			t.Fail()
+		}
+	}
+
+	//the original state is not touched
+	if !assert.Len(t, state.History[p], 9) {
+		t.Fail()
+	}
+
+	if !assert.NotEmpty(t, state.Current[p].Reservoir) {
+		t.Fail()
+	}
+}
@@ -180,3 +180,118 @@ func memorySpec(cmd *pm.Command) (interface{}, error) {
 
 	return nil, pm.InternalError(ErrInvalidType)
 }
+
+func cpuSpec(cmd *pm.Command) (interface{}, error) {
+	var args struct {
+		Name   string `json:"name,omitempty"`
+		Shares int    `json:"shares"`
+		Quota  int    `json:"quota"`
+		Period int    `json:"period"`
+		Usage  uint64 `json:"usage"`
+	}
+
+	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
+		return nil, pm.BadRequestError(err)
+	}
+
+	group, err := Get(CPUSubsystem, args.Name)
+
+	if err != nil {
+		return nil, pm.NotFoundError(err)
+	}
+
+	if group, ok := group.(CPUGroup); ok {
+		if args.Shares != 0 {
+			if err := group.Shares(args.Shares); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := group.Limit(args.Quota, args.Period); err != nil {
+			return nil, err
+		}
+
+		args.Name = ""
+		args.Shares, _ = group.GetShares()
+		args.Quota, args.Period, _ = group.GetLimit()
+		args.Usage, _ = group.Usage()
+
+		return args, nil
+	}
+
+	return nil, pm.InternalError(ErrInvalidType)
+}
+
+func blkioSpec(cmd *pm.Command) (interface{}, error) {
+	var args struct {
+		Name     string             `json:"name,omitempty"`
+		Weight   int                `json:"weight"`
+		Throttle []pm.BlkioThrottle `json:"throttle"`
+	}
+
+	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
+		return nil, pm.BadRequestError(err)
+	}
+
+	group, err := Get(BlkioSubsystem, args.Name)
+
+	if err != nil {
+		return nil, pm.NotFoundError(err)
+	}
+
+	if group, ok := group.(BlkioGroup); ok {
+		if args.Weight != 0 {
+			if err := group.Weight(args.Weight); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, throttle := range args.Throttle {
+			if err := group.Throttle(throttle); err != nil {
+				return nil, pm.BadRequestError(err)
+			}
+		}
+
+		args.Name = ""
+		args.Weight, _ = group.GetWeight()
+		args.Throttle, _ = group.Throttles()
+
+		return args, nil
+	}
+
+	return nil, pm.InternalError(ErrInvalidType)
+}
+
+func pidsSpec(cmd *pm.Command) (interface{}, error) {
+	var args struct {
+		Name    string `json:"name,omitempty"`
+		Max     int    `json:"max"`
+		Current int    `json:"current"`
+	}
+
+	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
+		return nil, pm.BadRequestError(err)
+	}
+
+	group, err := Get(PidsSubsystem, args.Name)
+
+	if err != nil {
+		return nil, pm.NotFoundError(err)
+	}
+
+	if group, ok := group.(PidsGroup); ok {
+		if args.Max != 0 {
+			if err := group.Max(args.Max); err != nil {
+				return nil, err
+			}
+		}
+
+		args.Name = ""
+		args.Max, _ = group.GetMax()
+		args.Current, _ = group.Current()
+
+		return args, nil
+	}
+
+	return nil, pm.InternalError(ErrInvalidType)
+}
@@ -0,0 +1,161 @@
+package cgroups
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/threefoldtech/0-core/base/pm"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	//BlkioDefaultWeight default block io weight of a group
+	BlkioDefaultWeight = 500
+)
+
+type BlkioGroup interface {
+	Group
+	Weight(weight int) error
+	GetWeight() (int, error)
+	//Throttle sets the throttles of a device, zero values remove the throttle
+	Throttle(throttle pm.BlkioThrottle) error
+	Throttles() ([]pm.BlkioThrottle, error)
+}
+
+func mkBlkioGroup(name string, subsys Subsystem) Group {
+	return &blkioCGroup{
+		cgroup{name: name, subsys: subsys},
+	}
+}
+
+type blkioCGroup struct {
+	cgroup
+}
+
+func (c *blkioCGroup) Reset() {
+	c.Weight(BlkioDefaultWeight)
+
+	throttles, _ := c.Throttles()
+	for _, throttle := range throttles {
+		c.Throttle(pm.BlkioThrottle{Device: throttle.Device})
+	}
+}
+
+func (c *blkioCGroup) Weight(weight int) error {
+	return ioutil.WriteFile(path.Join(c.base(), "blkio.weight"), []byte(fmt.Sprintf("%d", weight)), 0644)
+}
+
+func (c *blkioCGroup) GetWeight() (int, error) {
+	data, err := ioutil.ReadFile(path.Join(c.base(), "blkio.weight"))
+	if err != nil {
+		return 0, err
+	}
+
+	var weight int
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(data)), "%d", &weight); err != nil {
+		return 0, err
+	}
+
+	return weight, nil
+}
+
+//blkioDevice gets the major:minor numbers of a block device, the device is either a path or
+//already major:minor
+func blkioDevice(device string) (string, error) {
+	var major, minor uint32
+	if _, err := fmt.Sscanf(device, "%d:%d", &major, &minor); err == nil {
+		return fmt.Sprintf("%d:%d", major, minor), nil
+	}
+
+	stat, err := os.Stat(device)
+	if err != nil {
+		return "", err
+	}
+
+	sys, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok || stat.Mode()&os.ModeDevice == 0 || stat.Mode()&os.ModeCharDevice != 0 {
+		return "", fmt.Errorf("%s: not a block device", device)
+	}
+
+	devid := uint64(sys.Rdev)
+	return fmt.Sprintf("%d:%d", unix.Major(devid), unix.Minor(devid)), nil
+}
+
+func (c *blkioCGroup) throttleFiles(throttle *pm.BlkioThrottle) map[string]*uint64 {
+	return map[string]*uint64{
+		"blkio.throttle.read_bps_device":   &throttle.ReadBps,
+		"blkio.throttle.write_bps_device":  &throttle.WriteBps,
+		"blkio.throttle.read_iops_device":  &throttle.ReadIOps,
+		"blkio.throttle.write_iops_device": &throttle.WriteIOps,
+	}
+}
+
+func (c *blkioCGroup) Throttle(throttle pm.BlkioThrottle) error {
+	device, err := blkioDevice(throttle.Device)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range c.throttleFiles(&throttle) {
+		spec := fmt.Sprintf("%s %d", device, *value)
+		if err := ioutil.WriteFile(path.Join(c.base(), name), []byte(spec), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *blkioCGroup) Throttles() ([]pm.BlkioThrottle, error) {
+	devices := make(map[string]*pm.BlkioThrottle)
+	get := func(device string) *pm.BlkioThrottle {
+		throttle, ok := devices[device]
+		if !ok {
+			throttle = &pm.BlkioThrottle{Device: device}
+			devices[device] = throttle
+		}
+
+		return throttle
+	}
+
+	for name := range c.throttleFiles(&pm.BlkioThrottle{}) {
+		data, err := ioutil.ReadFile(path.Join(c.base(), name))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range strings.Split(string(data), "\n") {
+			var device string
+			var value uint64
+			if _, err := fmt.Sscanf(line, "%s %d", &device, &value); err != nil {
+				continue
+			}
+
+			*c.throttleFiles(get(device))[name] = value
+		}
+	}
+
+	var throttles []pm.BlkioThrottle
+	for _, throttle := range devices {
+		throttles = append(throttles, *throttle)
+	}
+
+	sort.Slice(throttles, func(i, j int) bool {
+		return throttles[i].Device < throttles[j].Device
+	})
+
+	return throttles, nil
+}
+
+func (c *blkioCGroup) Root() Group {
+	return &blkioCGroup{
+		cgroup: cgroup{subsys: c.subsys},
+	}
+}
+
+var _ BlkioGroup = &blkioCGroup{}
@@ -0,0 +1,147 @@
+package cgroups
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/threefoldtech/0-core/base/pm"
+)
+
+const (
+	//IODefaultWeight default io weight of a group in the unified hierarchy
+	IODefaultWeight = 100
+
+	blkioMinWeight = 10
+	blkioMaxWeight = 1000
+	ioMaxWeight    = 10000
+)
+
+func mkBlkioGroupV2(name string, subsys Subsystem) Group {
+	return &blkioCGroupV2{
+		cgroup{name: name, subsys: subsys},
+	}
+}
+
+//blkioCGroupV2 block io group of the unified hierarchy (io controller), the v1 weight [10-1000] is
+//mapped to the io weight [1-10000]
+type blkioCGroupV2 struct {
+	cgroup
+}
+
+//ioMaxKeys maps the io.max keys to the throttle values
+func ioMaxKeys(throttle *pm.BlkioThrottle) map[string]*uint64 {
+	return map[string]*uint64{
+		"rbps":  &throttle.ReadBps,
+		"wbps":  &throttle.WriteBps,
+		"riops": &throttle.ReadIOps,
+		"wiops": &throttle.WriteIOps,
+	}
+}
+
+//parseIOMax parses the content of io.max, 'max' values are returned as zero (no throttle)
+func parseIOMax(content string) ([]pm.BlkioThrottle, error) {
+	var throttles []pm.BlkioThrottle
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		throttle := pm.BlkioThrottle{Device: fields[0]}
+		keys := ioMaxKeys(&throttle)
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			value, ok := keys[kv[0]]
+			if !ok || len(kv) != 2 || kv[1] == "max" {
+				continue
+			}
+
+			if _, err := fmt.Sscanf(kv[1], "%d", value); err != nil {
+				return nil, fmt.Errorf("invalid io.max entry '%s': %s", line, err)
+			}
+		}
+
+		throttles = append(throttles, throttle)
+	}
+
+	sort.Slice(throttles, func(i, j int) bool {
+		return throttles[i].Device < throttles[j].Device
+	})
+
+	return throttles, nil
+}
+
+func (c *blkioCGroupV2) Reset() {
+	ioutil.WriteFile(path.Join(c.base(), "io.weight"), []byte(fmt.Sprintf("default %d", IODefaultWeight)), 0644)
+
+	throttles, _ := c.Throttles()
+	for _, throttle := range throttles {
+		c.Throttle(pm.BlkioThrottle{Device: throttle.Device})
+	}
+}
+
+func (c *blkioCGroupV2) Weight(weight int) error {
+	switch {
+	case weight < blkioMinWeight:
+		weight = blkioMinWeight
+	case weight > blkioMaxWeight:
+		weight = blkioMaxWeight
+	}
+
+	weight = 1 + (weight-blkioMinWeight)*(ioMaxWeight-1)/(blkioMaxWeight-blkioMinWeight)
+	return ioutil.WriteFile(path.Join(c.base(), "io.weight"), []byte(fmt.Sprintf("default %d", weight)), 0644)
+}
+
+func (c *blkioCGroupV2) GetWeight() (int, error) {
+	data, err := ioutil.ReadFile(path.Join(c.base(), "io.weight"))
+	if err != nil {
+		return 0, err
+	}
+
+	var weight int
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(data)), "default %d", &weight); err != nil {
+		return 0, err
+	}
+
+	return blkioMinWeight + (weight-1)*(blkioMaxWeight-blkioMinWeight)/(ioMaxWeight-1), nil
+}
+
+func (c *blkioCGroupV2) Throttle(throttle pm.BlkioThrottle) error {
+	device, err := blkioDevice(throttle.Device)
+	if err != nil {
+		return err
+	}
+
+	spec := []string{device}
+	keys := ioMaxKeys(&throttle)
+	for _, key := range []string{"rbps", "wbps", "riops", "wiops"} {
+		value := "max"
+		if *keys[key] != 0 {
+			value = fmt.Sprint(*keys[key])
+		}
+
+		spec = append(spec, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	return ioutil.WriteFile(path.Join(c.base(), "io.max"), []byte(strings.Join(spec, " ")), 0644)
+}
+
+func (c *blkioCGroupV2) Throttles() ([]pm.BlkioThrottle, error) {
+	data, err := ioutil.ReadFile(path.Join(c.base(), "io.max"))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseIOMax(string(data))
+}
+
+func (c *blkioCGroupV2) Root() Group {
+	return &blkioCGroupV2{
+		cgroup: cgroup{subsys: c.subsys},
+	}
+}
+
+var _ BlkioGroup = &blkioCGroupV2{}
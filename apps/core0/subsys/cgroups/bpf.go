@@ -0,0 +1,213 @@
+package cgroups
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+//eBPF definitions from linux/bpf.h needed to control the devices access of a cgroup
+const (
+	bpfProgLoad   = 5
+	bpfProgAttach = 8
+
+	bpfProgTypeCGroupDevice = 15
+	bpfCGroupDevice         = 6
+
+	//instructions
+	bpfLdxMemW   = 0x61 //dst = *(u32 *)(src + off)
+	bpfAndK      = 0x54 //dst &= imm (32 bits)
+	bpfRshK      = 0x74 //dst >>= imm (32 bits)
+	bpfMovX      = 0xbc //dst = src (32 bits)
+	bpfMovK64    = 0xb7 //dst = imm
+	bpfJeqK      = 0x15 //if dst == imm goto pc + off
+	bpfJneK      = 0x55 //if dst != imm goto pc + off
+	bpfExit      = 0x95
+	bpfRegCtx    = 1
+	bpfRegTmp    = 1 //the context is not needed anymore once the request is loaded
+	bpfRegType   = 2
+	bpfRegAccess = 3
+	bpfRegMajor  = 4
+	bpfRegMinor  = 5
+
+	//struct bpf_cgroup_dev_ctx
+	bpfDevTypeBlock = 1
+	bpfDevTypeChar  = 2
+	bpfDevAccMknod  = 1
+	bpfDevAccRead   = 2
+	bpfDevAccWrite  = 4
+)
+
+type bpfInsn struct {
+	code uint8
+	regs uint8 //dst (low 4 bits) and src (high 4 bits) registers
+	off  int16
+	imm  int32
+}
+
+func insn(code uint8, dst, src uint8, off int16, imm int32) bpfInsn {
+	return bpfInsn{code: code, regs: dst | src<<4, off: off, imm: imm}
+}
+
+//devicesProgram builds a cgroup device program that returns the default access for all
+//devices except the ones matching the rules
+func devicesProgram(rules *deviceRules) []bpfInsn {
+	prog := []bpfInsn{
+		insn(bpfLdxMemW, bpfRegType, bpfRegCtx, 0, 0),
+		insn(bpfAndK, bpfRegType, 0, 0, 0xffff),
+		insn(bpfLdxMemW, bpfRegAccess, bpfRegCtx, 0, 0),
+		insn(bpfRshK, bpfRegAccess, 0, 0, 16),
+		insn(bpfLdxMemW, bpfRegMajor, bpfRegCtx, 4, 0),
+		insn(bpfLdxMemW, bpfRegMinor, bpfRegCtx, 8, 0),
+	}
+
+	var result, fallback int32
+	if rules.allow {
+		fallback = 1
+	} else {
+		result = 1
+	}
+
+	for _, rule := range rules.rules {
+		var block []bpfInsn
+		switch rule.kind {
+		case 'b':
+			block = append(block, insn(bpfJneK, bpfRegType, 0, 0, bpfDevTypeBlock))
+		case 'c':
+			block = append(block, insn(bpfJneK, bpfRegType, 0, 0, bpfDevTypeChar))
+		}
+
+		if access := rule.mask(); access != bpfDevAccMknod|bpfDevAccRead|bpfDevAccWrite {
+			block = append(block, insn(bpfMovX, bpfRegTmp, bpfRegAccess, 0, 0))
+			if rules.allow {
+				//a deny rule matches if any of the requested access is denied
+				block = append(block,
+					insn(bpfAndK, bpfRegTmp, 0, 0, int32(access)),
+					insn(bpfJeqK, bpfRegTmp, 0, 0, 0),
+				)
+			} else {
+				//an allow rule matches if all the requested access is allowed
+				block = append(block,
+					insn(bpfAndK, bpfRegTmp, 0, 0, int32(^access&0x7)),
+					insn(bpfJneK, bpfRegTmp, 0, 0, 0),
+				)
+			}
+		}
+
+		if rule.major >= 0 {
+			block = append(block, insn(bpfJneK, bpfRegMajor, 0, 0, int32(rule.major)))
+		}
+
+		if rule.minor >= 0 {
+			block = append(block, insn(bpfJneK, bpfRegMinor, 0, 0, int32(rule.minor)))
+		}
+
+		block = append(block,
+			insn(bpfMovK64, 0, 0, 0, result),
+			insn(bpfExit, 0, 0, 0, 0),
+		)
+
+		//jumps skip to the next rule
+		for i := range block {
+			if block[i].code == bpfJneK || block[i].code == bpfJeqK {
+				block[i].off = int16(len(block) - i - 1)
+			}
+		}
+
+		prog = append(prog, block...)
+	}
+
+	return append(prog,
+		insn(bpfMovK64, 0, 0, 0, fallback),
+		insn(bpfExit, 0, 0, 0, 0),
+	)
+}
+
+func bpf(cmd uintptr, attr unsafe.Pointer, size uintptr) (uintptr, error) {
+	r, _, errno := unix.Syscall(unix.SYS_BPF, cmd, uintptr(attr), size)
+	if errno != 0 {
+		return 0, errno
+	}
+
+	return r, nil
+}
+
+//bpfLoadDevices loads a cgroup device program, it returns the program file descriptor
+func bpfLoadDevices(prog []bpfInsn) (int, error) {
+	license := []byte("GPL\x00")
+	attr := struct {
+		progType uint32
+		insnCnt  uint32
+		insns    uint64
+		license  uint64
+		logLevel uint32
+		logSize  uint32
+		logBuf   uint64
+	}{
+		progType: bpfProgTypeCGroupDevice,
+		insnCnt:  uint32(len(prog)),
+		insns:    uint64(uintptr(unsafe.Pointer(&prog[0]))),
+		license:  uint64(uintptr(unsafe.Pointer(&license[0]))),
+	}
+
+	fd, err := bpf(bpfProgLoad, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	runtime.KeepAlive(prog)
+	runtime.KeepAlive(license)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to load devices program: %s", err)
+	}
+
+	return int(fd), nil
+}
+
+//bpfAttachDevices attaches a cgroup device program to the cgroup directory, it replaces the program
+//attached by a previous call
+func bpfAttachDevices(dir string, prog int) error {
+	cgroup, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	defer cgroup.Close()
+
+	attr := struct {
+		targetFd    uint32
+		attachBpfFd uint32
+		attachType  uint32
+		attachFlags uint32
+	}{
+		targetFd:    uint32(cgroup.Fd()),
+		attachBpfFd: uint32(prog),
+		attachType:  bpfCGroupDevice,
+	}
+
+	if _, err := bpf(bpfProgAttach, unsafe.Pointer(&attr), unsafe.Sizeof(attr)); err != nil {
+		return fmt.Errorf("failed to attach devices program to '%s': %s", dir, err)
+	}
+
+	return nil
+}
+
+//attachDevices loads the rules program and attaches it to the cgroups directories
+func attachDevices(rules *deviceRules, dirs ...string) error {
+	prog, err := bpfLoadDevices(devicesProgram(rules))
+	if err != nil {
+		return err
+	}
+
+	//the attached program holds its own reference
+	defer syscall.Close(prog)
+
+	for _, dir := range dirs {
+		if err := bpfAttachDevices(dir, prog); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
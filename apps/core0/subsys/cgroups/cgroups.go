@@ -34,6 +34,14 @@ const (
 	CPUSetSubsystem = Subsystem("cpuset")
 	//MemorySubsystem memory subsystem
 	MemorySubsystem = Subsystem("memory")
+	//CPUSubsystem cpu subsystem
+	CPUSubsystem = Subsystem("cpu")
+	//BlkioSubsystem block io subsystem
+	BlkioSubsystem = Subsystem("blkio")
+	//FreezerSubsystem freezer subsystem
+	FreezerSubsystem = Subsystem("freezer")
+	//PidsSubsystem pids subsystem
+	PidsSubsystem = Subsystem("pids")
 
 	//CGroupBase base mount point
 	CGroupBase = "/sys/fs/cgroup"
@@ -42,10 +50,28 @@ const (
 var (
 	log        = logging.MustGetLogger("cgroups")
 	once       sync.Once
+	unified    bool
 	subsystems = map[Subsystem]mkg{
 		DevicesSubsystem: mkDevicesGroup,
 		CPUSetSubsystem:  mkCPUSetGroup,
 		MemorySubsystem:  mkMemoryGroup,
+		CPUSubsystem:     mkCPUGroup,
+		BlkioSubsystem:   mkBlkioGroup,
+		FreezerSubsystem: mkFreezerGroup,
+		PidsSubsystem:    mkPidsGroup,
+	}
+
+	//controllers mounted with a subsystem hierarchy, other than the subsystem itself
+	controllers = map[Subsystem]string{
+		CPUSubsystem: "cpu,cpuacct",
+	}
+
+	//requiredSubsystems the v1 hierarchies the node can't run without, the others are not available
+	//on all the kernels, they are dropped (with a warning) if they can't be mounted
+	requiredSubsystems = map[Subsystem]bool{
+		DevicesSubsystem: true,
+		CPUSetSubsystem:  true,
+		MemorySubsystem:  true,
 	}
 
 	//ErrDoesNotExist does not exist error
@@ -54,23 +80,20 @@ var (
 	ErrInvalidType = fmt.Errorf("cgroup of invalid type")
 )
 
-//Init Initialized the cgroup subsystem
+//Init Initialized the cgroup subsystem, the cgroup v2 unified hierarchy is used instead of the v1
+//hierarchies if the kernel requires it
 func Init() (err error) {
 	once.Do(func() {
-		os.MkdirAll(CGroupBase, 0755)
-		err = syscall.Mount("cgroup_root", CGroupBase, "tmpfs", 0, "")
-		if err != nil {
-			return
+		if unified = isUnified(); unified {
+			log.Infof("using cgroup v2 unified hierarchy")
+			subsystems = unifiedSubsystems
+			err = mountUnified()
+		} else {
+			err = mount()
 		}
 
-		for sub := range subsystems {
-			p := path.Join(CGroupBase, string(sub))
-			os.MkdirAll(p, 0755)
-
-			err = syscall.Mount(string(sub), p, "cgroup", 0, string(sub))
-			if err != nil {
-				return
-			}
+		if err != nil {
+			return
 		}
 
 		pm.RegisterBuiltIn("cgroup.list", list)
@@ -85,11 +108,62 @@ func Init() (err error) {
 
 		pm.RegisterBuiltIn("cgroup.cpuset.spec", cpusetSpec)
 		pm.RegisterBuiltIn("cgroup.memory.spec", memorySpec)
+		pm.RegisterBuiltIn("cgroup.cpu.spec", cpuSpec)
+		pm.RegisterBuiltIn("cgroup.blkio.spec", blkioSpec)
+		pm.RegisterBuiltIn("cgroup.pids.spec", pidsSpec)
+
+		//enforce the jobs limits
+		pm.AddHandle(&jobLimiter{})
 	})
 
 	return
 }
 
+//mount mounts a cgroup v1 hierarchy per subsystem
+func mount() error {
+	os.MkdirAll(CGroupBase, 0755)
+	if err := syscall.Mount("cgroup_root", CGroupBase, "tmpfs", 0, ""); err != nil {
+		return err
+	}
+
+	for sub := range subsystems {
+		p := path.Join(CGroupBase, string(sub))
+		os.MkdirAll(p, 0755)
+
+		options := string(sub)
+		if c, ok := controllers[sub]; ok {
+			options = c
+		}
+
+		if err := syscall.Mount(string(sub), p, "cgroup", 0, options); err != nil {
+			if requiredSubsystems[sub] {
+				return err
+			}
+
+			log.Warningf("cgroup subsystem '%s' is not available: %s", sub, err)
+			os.Remove(p)
+			delete(subsystems, sub)
+		}
+	}
+
+	return nil
+}
+
+//Unified returns true if the cgroup v2 unified hierarchy is in use, a process then belongs to a single
+//group, and the groups of the same name in all subsystems are the same group
+func Unified() bool {
+	return unified
+}
+
+//groupPath gets the directory of a group
+func groupPath(subsystem Subsystem, name string) string {
+	if unified {
+		return path.Join(CGroupBase, name)
+	}
+
+	return path.Join(CGroupBase, string(subsystem), name)
+}
+
 //GetGroup creaes a group if it does not exist
 func GetGroup(subsystem Subsystem, name string) (Group, error) {
 	mkg, ok := subsystems[subsystem]
@@ -97,7 +171,7 @@ func GetGroup(subsystem Subsystem, name string) (Group, error) {
 		return nil, fmt.Errorf("unknown subsystem '%s'", subsystem)
 	}
 
-	p := path.Join(CGroupBase, string(subsystem), name)
+	p := groupPath(subsystem, name)
 	if _, err := os.Stat(p); err == nil {
 		//group was created before
 		return mkg(name, subsystem), nil
@@ -125,11 +199,11 @@ func Get(subsystem Subsystem, name string) (Group, error) {
 func GetGroups() (map[Subsystem][]string, error) {
 	result := make(map[Subsystem][]string)
 	for sub := range subsystems {
-		// skip devices subsystem (only cpuset and memory)
+		// skip devices subsystem
 		if sub == DevicesSubsystem {
 			continue
 		}
-		info, err := ioutil.ReadDir(path.Join(CGroupBase, string(sub)))
+		info, err := ioutil.ReadDir(groupPath(sub, ""))
 		if err != nil {
 			return nil, err
 		}
@@ -150,6 +224,10 @@ func Remove(subsystem Subsystem, name string) error {
 		return nil
 	}
 
+	if unified && subsystem == DevicesSubsystem {
+		defer forgetDevices(name)
+	}
+
 	builder := subsystems[subsystem]
 	group := builder(name, subsystem)
 	tasks, err := group.Tasks()
@@ -158,7 +236,7 @@ func Remove(subsystem Subsystem, name string) error {
 	}
 
 	if len(tasks) == 0 {
-		return os.Remove(path.Join(CGroupBase, string(subsystem), name))
+		return os.Remove(groupPath(subsystem, name))
 	}
 
 	root := group.Root()
@@ -166,7 +244,7 @@ func Remove(subsystem Subsystem, name string) error {
 		root.Task(task)
 	}
 
-	return os.Remove(path.Join(CGroupBase, string(subsystem), name))
+	return os.Remove(groupPath(subsystem, name))
 }
 
 //Exists Check if a cgroup exists
@@ -176,7 +254,7 @@ func Exists(subsystem Subsystem, name string) bool {
 		return false
 	}
 
-	p := path.Join(CGroupBase, string(subsystem), name)
+	p := groupPath(subsystem, name)
 	info, err := os.Stat(p)
 	if err != nil {
 		return false
@@ -199,7 +277,7 @@ func (g *cgroup) Subsystem() Subsystem {
 }
 
 func (g *cgroup) base() string {
-	return path.Join(CGroupBase, string(g.subsys), g.name)
+	return groupPath(g.subsys, g.name)
 }
 
 func (g *cgroup) Task(pid int) error {
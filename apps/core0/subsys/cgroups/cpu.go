@@ -0,0 +1,146 @@
+package cgroups
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+const (
+	//CPUDefaultShares default cpu shares of a group
+	CPUDefaultShares = 1024
+	//CPUPeriod cfs period in microseconds used to compute the group quota
+	CPUPeriod = 100000
+)
+
+type CPUGroup interface {
+	Group
+	Shares(shares int) error
+	GetShares() (int, error)
+	//Quota sets the max cpu usage in percent of a single cpu, -1 means no limit
+	Quota(percent int) error
+	GetQuota() (int, error)
+	//Limit sets the cfs quota and period in microseconds, a quota of -1 means no limit and zero
+	//values are not changed
+	Limit(quota, period int) error
+	GetLimit() (int, int, error)
+	//Usage total cpu time consumed by the group tasks in nanoseconds (from cpuacct)
+	Usage() (uint64, error)
+}
+
+func mkCPUGroup(name string, subsys Subsystem) Group {
+	return &cpuCGroup{
+		cgroup{name: name, subsys: subsys},
+	}
+}
+
+type cpuCGroup struct {
+	cgroup
+}
+
+func (c *cpuCGroup) set(name string, value int) error {
+	return ioutil.WriteFile(path.Join(c.base(), name), []byte(fmt.Sprintf("%d", value)), 0644)
+}
+
+func (c *cpuCGroup) get(name string) (int, error) {
+	data, err := ioutil.ReadFile(path.Join(c.base(), name))
+	if err != nil {
+		return 0, err
+	}
+
+	var value int
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(data)), "%d", &value); err != nil {
+		return 0, err
+	}
+
+	return value, nil
+}
+
+func (c *cpuCGroup) Reset() {
+	c.Shares(CPUDefaultShares)
+	c.Quota(-1)
+}
+
+func (c *cpuCGroup) Shares(shares int) error {
+	return c.set("cpu.shares", shares)
+}
+
+func (c *cpuCGroup) GetShares() (int, error) {
+	return c.get("cpu.shares")
+}
+
+func (c *cpuCGroup) Quota(percent int) error {
+	if percent < 0 {
+		return c.set("cpu.cfs_quota_us", -1)
+	}
+
+	return c.Limit(percent*CPUPeriod/100, CPUPeriod)
+}
+
+func (c *cpuCGroup) Limit(quota, period int) error {
+	if period != 0 {
+		if err := c.set("cpu.cfs_period_us", period); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case quota == 0:
+		return nil
+	case quota < 0:
+		quota = -1
+	}
+
+	return c.set("cpu.cfs_quota_us", quota)
+}
+
+func (c *cpuCGroup) GetLimit() (int, int, error) {
+	quota, err := c.get("cpu.cfs_quota_us")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	period, err := c.get("cpu.cfs_period_us")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return quota, period, nil
+}
+
+func (c *cpuCGroup) Usage() (uint64, error) {
+	data, err := ioutil.ReadFile(path.Join(c.base(), "cpuacct.usage"))
+	if err != nil {
+		return 0, err
+	}
+
+	var usage uint64
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(data)), "%d", &usage); err != nil {
+		return 0, err
+	}
+
+	return usage, nil
+}
+
+func (c *cpuCGroup) GetQuota() (int, error) {
+	quota, err := c.get("cpu.cfs_quota_us")
+	if err != nil || quota < 0 {
+		return -1, err
+	}
+
+	period, err := c.get("cpu.cfs_period_us")
+	if err != nil {
+		return -1, err
+	}
+
+	return quota * 100 / period, nil
+}
+
+func (c *cpuCGroup) Root() Group {
+	return &cpuCGroup{
+		cgroup: cgroup{subsys: c.subsys},
+	}
+}
+
+var _ CPUGroup = &cpuCGroup{}
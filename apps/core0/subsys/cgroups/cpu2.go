@@ -0,0 +1,157 @@
+package cgroups
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+const (
+	//CPUDefaultWeight default cpu weight of a group in the unified hierarchy
+	CPUDefaultWeight = 100
+
+	cpuMinShares = 2
+	cpuMaxShares = 262144
+	cpuMaxWeight = 10000
+)
+
+func mkCPUGroupV2(name string, subsys Subsystem) Group {
+	return &cpuCGroupV2{
+		cgroup{name: name, subsys: subsys},
+	}
+}
+
+//cpuCGroupV2 cpu group of the unified hierarchy, the v1 shares [2-262144] are mapped to
+//the cpu weight [1-10000]
+type cpuCGroupV2 struct {
+	cgroup
+}
+
+//sharesToWeight converts v1 cpu shares to a v2 cpu weight
+func sharesToWeight(shares int) int {
+	switch {
+	case shares < cpuMinShares:
+		shares = cpuMinShares
+	case shares > cpuMaxShares:
+		shares = cpuMaxShares
+	}
+
+	return 1 + (shares-cpuMinShares)*(cpuMaxWeight-1)/(cpuMaxShares-cpuMinShares)
+}
+
+//weightToShares converts a v2 cpu weight to v1 cpu shares
+func weightToShares(weight int) int {
+	return cpuMinShares + (weight-1)*(cpuMaxShares-cpuMinShares)/(cpuMaxWeight-1)
+}
+
+//parseCPUMax parses the 'quota period' content of cpu.max, a quota of 'max' is returned as -1
+func parseCPUMax(content string) (int, int, error) {
+	var quota string
+	var period int
+	if _, err := fmt.Sscanf(strings.TrimSpace(content), "%s %d", &quota, &period); err != nil {
+		return 0, 0, err
+	}
+
+	if quota == "max" {
+		return -1, period, nil
+	}
+
+	var value int
+	if _, err := fmt.Sscanf(quota, "%d", &value); err != nil {
+		return 0, 0, err
+	}
+
+	return value, period, nil
+}
+
+func (c *cpuCGroupV2) Reset() {
+	writeValue(path.Join(c.base(), "cpu.weight"), CPUDefaultWeight)
+	c.Limit(-1, CPUPeriod)
+}
+
+func (c *cpuCGroupV2) Shares(shares int) error {
+	return writeValue(path.Join(c.base(), "cpu.weight"), sharesToWeight(shares))
+}
+
+func (c *cpuCGroupV2) GetShares() (int, error) {
+	weight, err := readValue(path.Join(c.base(), "cpu.weight"))
+	if err != nil {
+		return 0, err
+	}
+
+	return weightToShares(weight), nil
+}
+
+func (c *cpuCGroupV2) Quota(percent int) error {
+	if percent < 0 {
+		return c.Limit(-1, 0)
+	}
+
+	return c.Limit(percent*CPUPeriod/100, CPUPeriod)
+}
+
+func (c *cpuCGroupV2) GetQuota() (int, error) {
+	quota, period, err := c.GetLimit()
+	if err != nil || quota < 0 {
+		return -1, err
+	}
+
+	return quota * 100 / period, nil
+}
+
+func (c *cpuCGroupV2) Limit(quota, period int) error {
+	cquota, cperiod, err := c.GetLimit()
+	if err != nil {
+		return err
+	}
+
+	if period == 0 {
+		period = cperiod
+	}
+
+	switch {
+	case quota == 0:
+		quota = cquota
+	case quota < 0:
+		quota = -1
+	}
+
+	value := "max"
+	if quota >= 0 {
+		value = fmt.Sprint(quota)
+	}
+
+	return ioutil.WriteFile(path.Join(c.base(), "cpu.max"), []byte(fmt.Sprintf("%s %d", value, period)), 0644)
+}
+
+func (c *cpuCGroupV2) GetLimit() (int, int, error) {
+	data, err := ioutil.ReadFile(path.Join(c.base(), "cpu.max"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return parseCPUMax(string(data))
+}
+
+func (c *cpuCGroupV2) Usage() (uint64, error) {
+	stat, err := readKeyed(path.Join(c.base(), "cpu.stat"))
+	if err != nil {
+		return 0, err
+	}
+
+	usage, ok := stat["usage_usec"]
+	if !ok {
+		return 0, fmt.Errorf("cpu usage is not available")
+	}
+
+	return usage * 1000, nil
+}
+
+func (c *cpuCGroupV2) Root() Group {
+	return &cpuCGroupV2{
+		cgroup: cgroup{subsys: c.subsys},
+	}
+}
+
+var _ CPUGroup = &cpuCGroupV2{}
@@ -0,0 +1,59 @@
+package cgroups
+
+import (
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+func mkCPUSetGroupV2(name string, subsys Subsystem) Group {
+	return &cpusetCGroupV2{
+		cgroup{name: name, subsys: subsys},
+	}
+}
+
+//cpusetCGroupV2 cpuset group of the unified hierarchy, an empty spec means the group uses
+//the cpus and memory nodes of its parent
+type cpusetCGroupV2 struct {
+	cgroup
+}
+
+func (c *cpusetCGroupV2) Reset() {
+	c.Cpus("")
+	c.Mems("")
+}
+
+func (c *cpusetCGroupV2) Cpus(spec string) error {
+	log.Debugf("setting cpu specs to: '%s'", spec)
+	return ioutil.WriteFile(path.Join(c.base(), "cpuset.cpus"), []byte(spec), 0644)
+}
+
+func (c *cpusetCGroupV2) Mems(spec string) error {
+	return ioutil.WriteFile(path.Join(c.base(), "cpuset.mems"), []byte(spec), 0644)
+}
+
+//get reads the effective spec, so the inherited values are reported like in v1
+func (c *cpusetCGroupV2) get(name string) (string, error) {
+	data, err := ioutil.ReadFile(path.Join(c.base(), name+".effective"))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (c *cpusetCGroupV2) GetCpus() (string, error) {
+	return c.get("cpuset.cpus")
+}
+
+func (c *cpusetCGroupV2) GetMems() (string, error) {
+	return c.get("cpuset.mems")
+}
+
+func (c *cpusetCGroupV2) Root() Group {
+	return &cpusetCGroupV2{
+		cgroup: cgroup{subsys: c.subsys},
+	}
+}
+
+var _ CPUSetGroup = &cpusetCGroupV2{}
@@ -0,0 +1,310 @@
+package cgroups
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+var (
+	//the unified hierarchy has no devices files, the rules of the devices groups are kept
+	//in memory and enforced with an eBPF program attached to the cgroups of the group tasks
+	devices  = make(map[string]*deviceRules)
+	devicesM sync.Mutex
+)
+
+//deviceRule a devices rule in the v1 format 'type major:minor access', -1 major or minor
+//matches all the numbers
+type deviceRule struct {
+	kind   byte
+	major  int
+	minor  int
+	access string
+}
+
+func parseDeviceRule(spec string) (deviceRule, error) {
+	rule := deviceRule{kind: 'a', major: -1, minor: -1, access: "rwm"}
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields[0]) != 1 || !strings.Contains("abc", fields[0]) {
+		return rule, fmt.Errorf("invalid devices rule '%s'", spec)
+	}
+
+	rule.kind = fields[0][0]
+	if rule.kind == 'a' {
+		//'a' is all the devices with all the access
+		return rule, nil
+	}
+
+	if len(fields) != 3 {
+		return rule, fmt.Errorf("invalid devices rule '%s'", spec)
+	}
+
+	numbers := strings.SplitN(fields[1], ":", 2)
+	if len(numbers) != 2 {
+		return rule, fmt.Errorf("invalid devices rule '%s'", spec)
+	}
+
+	for i, target := range []*int{&rule.major, &rule.minor} {
+		if numbers[i] == "*" {
+			continue
+		}
+
+		if _, err := fmt.Sscanf(numbers[i], "%d", target); err != nil || *target < 0 {
+			return rule, fmt.Errorf("invalid devices rule '%s'", spec)
+		}
+	}
+
+	if len(strings.Trim(fields[2], "rwm")) != 0 {
+		return rule, fmt.Errorf("invalid devices rule '%s'", spec)
+	}
+
+	rule.access = fields[2]
+	return rule, nil
+}
+
+//mask the access of the rule as bpf_cgroup_dev_ctx access flags
+func (r deviceRule) mask() uint8 {
+	var mask uint8
+	for flag, access := range map[byte]uint8{'r': bpfDevAccRead, 'w': bpfDevAccWrite, 'm': bpfDevAccMknod} {
+		if strings.IndexByte(r.access, flag) >= 0 {
+			mask |= access
+		}
+	}
+
+	return mask
+}
+
+func (r deviceRule) String() string {
+	number := func(n int) string {
+		if n < 0 {
+			return "*"
+		}
+		return fmt.Sprint(n)
+	}
+
+	return fmt.Sprintf("%c %s:%s %s", r.kind, number(r.major), number(r.minor), r.access)
+}
+
+//deviceRules the default access of all the devices, and the rules with the opposite access
+type deviceRules struct {
+	allow bool
+	rules []deviceRule
+	dirs  map[string]struct{}
+}
+
+func newDeviceRules() *deviceRules {
+	return &deviceRules{allow: true, dirs: make(map[string]struct{})}
+}
+
+func (d *deviceRules) index(rule deviceRule) int {
+	for i, r := range d.rules {
+		if r == rule {
+			return i
+		}
+	}
+
+	return -1
+}
+
+//set applies an allow (or deny) rule the same way the v1 devices.allow (or devices.deny) file does
+func (d *deviceRules) set(spec string, allow bool) error {
+	rule, err := parseDeviceRule(spec)
+	if err != nil {
+		return err
+	}
+
+	if rule.kind == 'a' {
+		d.allow = allow
+		d.rules = nil
+		return nil
+	}
+
+	i := d.index(rule)
+	switch {
+	case d.allow != allow && i < 0:
+		d.rules = append(d.rules, rule)
+	case d.allow == allow && i >= 0:
+		d.rules = append(d.rules[:i], d.rules[i+1:]...)
+	}
+
+	return nil
+}
+
+//list lists the rules the same way the v1 devices.list file does
+func (d *deviceRules) list() []string {
+	if d.allow {
+		return []string{"a *:* rwm"}
+	}
+
+	var list []string
+	for _, rule := range d.rules {
+		list = append(list, rule.String())
+	}
+
+	return list
+}
+
+//cgroupOf gets the directory of the unified hierarchy cgroup of a process
+func cgroupOf(pid int) (string, error) {
+	file, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "0::") {
+			return path.Join(CGroupBase, strings.TrimPrefix(line, "0::")), nil
+		}
+	}
+
+	return "", fmt.Errorf("process %d is not in the unified hierarchy", pid)
+}
+
+func mkDevicesGroupV2(name string, subsys Subsystem) Group {
+	return &devicesCGroupV2{
+		cgroup{name: name, subsys: subsys},
+	}
+}
+
+//devicesCGroupV2 devices group of the unified hierarchy, adding a task to the group enforces the group
+//rules on the task's cgroup (and all the tasks in this cgroup)
+type devicesCGroupV2 struct {
+	cgroup
+}
+
+//rules gets the group rules, the caller must hold devicesM
+func (g *devicesCGroupV2) rules() *deviceRules {
+	rules, ok := devices[g.name]
+	if !ok {
+		rules = newDeviceRules()
+		if len(g.name) != 0 {
+			devices[g.name] = rules
+		}
+	}
+
+	return rules
+}
+
+//update applies the new rules to the cgroups of the group tasks
+func (g *devicesCGroupV2) update(spec string, allow bool) error {
+	devicesM.Lock()
+	defer devicesM.Unlock()
+
+	rules := g.rules()
+	if err := rules.set(spec, allow); err != nil {
+		return err
+	}
+
+	var dirs []string
+	for dir := range rules.dirs {
+		if _, err := os.Stat(dir); err != nil {
+			//the cgroup was removed
+			delete(rules.dirs, dir)
+			continue
+		}
+
+		dirs = append(dirs, dir)
+	}
+
+	if len(dirs) == 0 {
+		return nil
+	}
+
+	return attachDevices(rules, dirs...)
+}
+
+func (g *devicesCGroupV2) Deny(spec string) error {
+	return g.update(spec, false)
+}
+
+func (g *devicesCGroupV2) Allow(spec string) error {
+	return g.update(spec, true)
+}
+
+func (g *devicesCGroupV2) List() ([]string, error) {
+	devicesM.Lock()
+	defer devicesM.Unlock()
+
+	return g.rules().list(), nil
+}
+
+func (g *devicesCGroupV2) Task(pid int) error {
+	dir, err := cgroupOf(pid)
+	if err != nil {
+		return err
+	}
+
+	if dir == CGroupBase {
+		return fmt.Errorf("can't control the devices of the root cgroup")
+	}
+
+	devicesM.Lock()
+	defer devicesM.Unlock()
+
+	rules := g.rules()
+	if err := attachDevices(rules, dir); err != nil {
+		return err
+	}
+
+	//the root group allows all the devices, it doesn't track the cgroups
+	if len(g.name) != 0 {
+		rules.dirs[dir] = struct{}{}
+	}
+
+	for _, other := range devices {
+		if other != rules {
+			delete(other.dirs, dir)
+		}
+	}
+
+	return nil
+}
+
+func (g *devicesCGroupV2) Tasks() ([]int, error) {
+	devicesM.Lock()
+	var dirs []string
+	for dir := range g.rules().dirs {
+		dirs = append(dirs, dir)
+	}
+	devicesM.Unlock()
+
+	var pids []int
+	for _, dir := range dirs {
+		tasks, err := (&cgroup{name: strings.TrimPrefix(dir, CGroupBase)}).Tasks()
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		pids = append(pids, tasks...)
+	}
+
+	return pids, nil
+}
+
+func (g *devicesCGroupV2) Root() Group {
+	return &devicesCGroupV2{
+		cgroup: cgroup{subsys: g.subsys},
+	}
+}
+
+func (g *devicesCGroupV2) Reset() {
+
+}
+
+//forgetDevices drops the rules of a removed devices group
+func forgetDevices(name string) {
+	devicesM.Lock()
+	defer devicesM.Unlock()
+
+	delete(devices, name)
+}
+
+var _ DevicesGroup = &devicesCGroupV2{}
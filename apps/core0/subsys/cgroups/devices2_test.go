@@ -0,0 +1,167 @@
+package cgroups
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//run evaluates a devices program for the device request, it only knows the instructions
+//generated by devicesProgram
+func run(t *testing.T, prog []bpfInsn, kind, access, major, minor uint32) bool {
+	var regs [11]uint64
+	ctx := []uint32{access<<16 | kind, major, minor}
+
+	for pc := 0; pc < len(prog); pc++ {
+		ins := prog[pc]
+		dst, src := ins.regs&0xf, ins.regs>>4
+		switch ins.code {
+		case bpfLdxMemW:
+			if ok := assert.Equal(t, uint8(bpfRegCtx), src); !ok {
+				t.Fatal()
+			}
+			regs[dst] = uint64(ctx[ins.off/4])
+		case bpfAndK:
+			regs[dst] = uint64(uint32(regs[dst]) & uint32(ins.imm))
+		case bpfRshK:
+			regs[dst] = uint64(uint32(regs[dst]) >> uint32(ins.imm))
+		case bpfMovX:
+			regs[dst] = uint64(uint32(regs[src]))
+		case bpfMovK64:
+			regs[dst] = uint64(ins.imm)
+		case bpfJeqK:
+			if regs[dst] == uint64(ins.imm) {
+				pc += int(ins.off)
+			}
+		case bpfJneK:
+			if regs[dst] != uint64(ins.imm) {
+				pc += int(ins.off)
+			}
+		case bpfExit:
+			return regs[0] == 1
+		default:
+			t.Fatalf("unknown instruction %x", ins.code)
+		}
+	}
+
+	t.Fatal("program did not exit")
+	return false
+}
+
+func TestDeviceRuleParse(t *testing.T) {
+	rule, err := parseDeviceRule("c 136:* rwm")
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, deviceRule{kind: 'c', major: 136, minor: -1, access: "rwm"}, rule); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, "c 136:* rwm", rule.String()); !ok {
+		t.Error()
+	}
+
+	for _, spec := range []string{"", "x 1:2 r", "c 1:2", "c 1 r", "c 1:2 x", "b -1:2 r"} {
+		if _, err := parseDeviceRule(spec); err == nil {
+			t.Errorf("expected '%s' to fail", spec)
+		}
+	}
+}
+
+func TestDeviceRulesSet(t *testing.T) {
+	rules := newDeviceRules()
+	if ok := assert.Equal(t, []string{"a *:* rwm"}, rules.list()); !ok {
+		t.Error()
+	}
+
+	for _, spec := range []string{"c 1:5 rwm", "c *:* m", "c 1:5 rwm"} {
+		if err := rules.set(spec, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ok := assert.Equal(t, []string{"a *:* rwm"}, rules.list()); !ok {
+		t.Error()
+	}
+
+	if err := rules.set("a", false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, spec := range []string{"c 1:5 rwm", "c *:* m", "c 1:5 rwm"} {
+		if err := rules.set(spec, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ok := assert.Equal(t, []string{"c 1:5 rwm", "c *:* m"}, rules.list()); !ok {
+		t.Error()
+	}
+
+	if err := rules.set("c 1:5 rwm", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok := assert.Equal(t, []string{"c *:* m"}, rules.list()); !ok {
+		t.Error()
+	}
+}
+
+func TestDevicesProgramAllowList(t *testing.T) {
+	rules := newDeviceRules()
+	for _, spec := range []string{"a", "c 1:5 rwm", "c *:* m", "c 136:* rw", "b 8:0 r"} {
+		if err := rules.set(spec, spec != "a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	prog := devicesProgram(rules)
+	const rw = bpfDevAccRead | bpfDevAccWrite
+
+	for _, c := range []struct {
+		kind, access, major, minor uint32
+		allowed                    bool
+	}{
+		{bpfDevTypeChar, rw, 1, 5, true},
+		{bpfDevTypeChar, bpfDevAccMknod, 1, 5, true},
+		{bpfDevTypeChar, rw, 1, 3, false},
+		{bpfDevTypeChar, bpfDevAccMknod, 10, 200, true},
+		{bpfDevTypeBlock, bpfDevAccMknod, 8, 0, false},
+		{bpfDevTypeChar, rw, 136, 4, true},
+		{bpfDevTypeChar, rw | bpfDevAccMknod, 136, 4, false},
+		{bpfDevTypeBlock, bpfDevAccRead, 8, 0, true},
+		{bpfDevTypeBlock, rw, 8, 0, false},
+		{bpfDevTypeChar, bpfDevAccRead, 8, 0, false},
+	} {
+		if ok := assert.Equal(t, c.allowed, run(t, prog, c.kind, c.access, c.major, c.minor), "%+v", c); !ok {
+			t.Error()
+		}
+	}
+}
+
+func TestDevicesProgramDenyList(t *testing.T) {
+	rules := newDeviceRules()
+	for _, spec := range []string{"b 8:* w", "c 1:1 rwm"} {
+		if err := rules.set(spec, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	prog := devicesProgram(rules)
+
+	for _, c := range []struct {
+		kind, access, major, minor uint32
+		allowed                    bool
+	}{
+		{bpfDevTypeBlock, bpfDevAccRead, 8, 1, true},
+		{bpfDevTypeBlock, bpfDevAccRead | bpfDevAccWrite, 8, 1, false},
+		{bpfDevTypeBlock, bpfDevAccWrite, 9, 1, true},
+		{bpfDevTypeChar, bpfDevAccRead, 1, 1, false},
+		{bpfDevTypeChar, bpfDevAccRead, 1, 3, true},
+	} {
+		if ok := assert.Equal(t, c.allowed, run(t, prog, c.kind, c.access, c.major, c.minor), "%+v", c); !ok {
+			t.Error()
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package cgroups
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	//FreezerThawed the group tasks are running
+	FreezerThawed = FreezerState("THAWED")
+	//FreezerFreezing the group tasks are being frozen
+	FreezerFreezing = FreezerState("FREEZING")
+	//FreezerFrozen all the group tasks are frozen
+	FreezerFrozen = FreezerState("FROZEN")
+
+	//FreezeTimeout max time to wait for all the group tasks to freeze
+	FreezeTimeout = 10 * time.Second
+)
+
+type FreezerState string
+
+type FreezerGroup interface {
+	Group
+	//Freeze freezes all the group tasks, and waits until they are all frozen
+	Freeze() error
+	Thaw() error
+	State() (FreezerState, error)
+}
+
+func mkFreezerGroup(name string, subsys Subsystem) Group {
+	return &freezerCGroup{
+		cgroup{name: name, subsys: subsys},
+	}
+}
+
+type freezerCGroup struct {
+	cgroup
+}
+
+func (c *freezerCGroup) set(state FreezerState) error {
+	return ioutil.WriteFile(path.Join(c.base(), "freezer.state"), []byte(state), 0644)
+}
+
+func (c *freezerCGroup) State() (FreezerState, error) {
+	data, err := ioutil.ReadFile(path.Join(c.base(), "freezer.state"))
+	if err != nil {
+		return "", err
+	}
+
+	return FreezerState(strings.TrimSpace(string(data))), nil
+}
+
+func (c *freezerCGroup) Freeze() error {
+	if err := c.set(FreezerFrozen); err != nil {
+		return err
+	}
+
+	timeout := time.After(FreezeTimeout)
+	for {
+		state, err := c.State()
+		if err != nil {
+			return err
+		}
+
+		if state == FreezerFrozen {
+			return nil
+		}
+
+		select {
+		case <-timeout:
+			//tasks that can't be frozen (in uninterruptible sleep for example), don't leave
+			//the group half frozen
+			c.Thaw()
+			return fmt.Errorf("timeout while freezing cgroup '%s' (state: %s)", c.name, state)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (c *freezerCGroup) Thaw() error {
+	return c.set(FreezerThawed)
+}
+
+func (c *freezerCGroup) Reset() {
+	c.Thaw()
+}
+
+func (c *freezerCGroup) Root() Group {
+	return &freezerCGroup{
+		cgroup: cgroup{subsys: c.subsys},
+	}
+}
+
+var _ FreezerGroup = &freezerCGroup{}
@@ -0,0 +1,85 @@
+package cgroups
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"time"
+)
+
+func mkFreezerGroupV2(name string, subsys Subsystem) Group {
+	return &freezerCGroupV2{
+		cgroup{name: name, subsys: subsys},
+	}
+}
+
+//freezerCGroupV2 freezer of the unified hierarchy, it's built in every group (cgroup.freeze)
+type freezerCGroupV2 struct {
+	cgroup
+}
+
+func (c *freezerCGroupV2) set(frozen int) error {
+	return ioutil.WriteFile(path.Join(c.base(), "cgroup.freeze"), []byte(fmt.Sprint(frozen)), 0644)
+}
+
+func (c *freezerCGroupV2) State() (FreezerState, error) {
+	freeze, err := readValue(path.Join(c.base(), "cgroup.freeze"))
+	if err != nil {
+		return "", err
+	}
+
+	events, err := readKeyed(path.Join(c.base(), "cgroup.events"))
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case events["frozen"] == 1:
+		return FreezerFrozen, nil
+	case freeze == 1:
+		return FreezerFreezing, nil
+	default:
+		return FreezerThawed, nil
+	}
+}
+
+func (c *freezerCGroupV2) Freeze() error {
+	if err := c.set(1); err != nil {
+		return err
+	}
+
+	timeout := time.After(FreezeTimeout)
+	for {
+		state, err := c.State()
+		if err != nil {
+			return err
+		}
+
+		if state == FreezerFrozen {
+			return nil
+		}
+
+		select {
+		case <-timeout:
+			c.Thaw()
+			return fmt.Errorf("timeout while freezing cgroup '%s' (state: %s)", c.name, state)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (c *freezerCGroupV2) Thaw() error {
+	return c.set(0)
+}
+
+func (c *freezerCGroupV2) Reset() {
+	c.Thaw()
+}
+
+func (c *freezerCGroupV2) Root() Group {
+	return &freezerCGroupV2{
+		cgroup: cgroup{subsys: c.subsys},
+	}
+}
+
+var _ FreezerGroup = &freezerCGroupV2{}
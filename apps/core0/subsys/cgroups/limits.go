@@ -0,0 +1,204 @@
+package cgroups
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/threefoldtech/0-core/base/pm"
+)
+
+var (
+	limitSubsystems = []Subsystem{MemorySubsystem, CPUSubsystem, CPUSetSubsystem, BlkioSubsystem, PidsSubsystem}
+)
+
+//jobLimiter places the jobs processes in transient cgroups that enforce the job limits
+type jobLimiter struct{}
+
+func (l *jobLimiter) name(cmd *pm.Command) string {
+	return fmt.Sprintf("job-%s", strings.Replace(cmd.ID, "/", "_", -1))
+}
+
+func limitGroup(subsystem Subsystem, name string) (Group, error) {
+	group, err := GetGroup(subsystem, name)
+	if err != nil {
+		return nil, err
+	}
+
+	//a group left over by a previous run
+	group.Reset()
+	return group, nil
+}
+
+func limitMemory(name string, limits *pm.Limits, pid int) error {
+	group, err := limitGroup(MemorySubsystem, name)
+	if err != nil {
+		return err
+	}
+
+	if err := group.(MemoryGroup).Limit(int(limits.Memory), int(limits.Swap)); err != nil {
+		return err
+	}
+
+	return group.Task(pid)
+}
+
+func limitCPU(name string, limits *pm.Limits, pid int) error {
+	group, err := limitGroup(CPUSubsystem, name)
+	if err != nil {
+		return err
+	}
+
+	cpu := group.(CPUGroup)
+	if limits.CPUShares != 0 {
+		if err := cpu.Shares(limits.CPUShares); err != nil {
+			return err
+		}
+	}
+
+	if limits.CPUQuota != 0 {
+		if err := cpu.Quota(limits.CPUQuota); err != nil {
+			return err
+		}
+	}
+
+	return group.Task(pid)
+}
+
+func limitCPUSet(name string, limits *pm.Limits, pid int) error {
+	group, err := limitGroup(CPUSetSubsystem, name)
+	if err != nil {
+		return err
+	}
+
+	if err := group.(CPUSetGroup).Cpus(limits.CPUSet); err != nil {
+		return err
+	}
+
+	return group.Task(pid)
+}
+
+func limitBlkio(name string, limits *pm.Limits, pid int) error {
+	group, err := limitGroup(BlkioSubsystem, name)
+	if err != nil {
+		return err
+	}
+
+	blkio := group.(BlkioGroup)
+	if limits.BlkioWeight != 0 {
+		if err := blkio.Weight(limits.BlkioWeight); err != nil {
+			return err
+		}
+	}
+
+	for _, throttle := range limits.BlkioThrottle {
+		if err := blkio.Throttle(throttle); err != nil {
+			return err
+		}
+	}
+
+	return group.Task(pid)
+}
+
+func limitPids(name string, limits *pm.Limits, pid int) error {
+	group, err := limitGroup(PidsSubsystem, name)
+	if err != nil {
+		return err
+	}
+
+	if err := group.(PidsGroup).Max(limits.Pids); err != nil {
+		return err
+	}
+
+	return group.Task(pid)
+}
+
+//LimitSubsystems returns the subsystems of the cgroups Limit places the process in for the limits
+func LimitSubsystems(limits *pm.Limits) []Subsystem {
+	var subsystems []Subsystem
+	if limits.Memory != 0 {
+		subsystems = append(subsystems, MemorySubsystem)
+	}
+
+	if limits.CPUShares != 0 || limits.CPUQuota != 0 {
+		subsystems = append(subsystems, CPUSubsystem)
+	}
+
+	if len(limits.CPUSet) != 0 {
+		subsystems = append(subsystems, CPUSetSubsystem)
+	}
+
+	if limits.BlkioWeight != 0 || len(limits.BlkioThrottle) != 0 {
+		subsystems = append(subsystems, BlkioSubsystem)
+	}
+
+	if limits.Pids != 0 {
+		subsystems = append(subsystems, PidsSubsystem)
+	}
+
+	return subsystems
+}
+
+//Limit places the process in transient cgroups with the given name that enforce the limits, the
+//children of the process inherit the limits
+func Limit(name string, limits *pm.Limits, pid int) error {
+	if limits.Memory != 0 {
+		if err := limitMemory(name, limits, pid); err != nil {
+			return fmt.Errorf("memory: %s", err)
+		}
+	}
+
+	if limits.CPUShares != 0 || limits.CPUQuota != 0 {
+		if err := limitCPU(name, limits, pid); err != nil {
+			return fmt.Errorf("cpu: %s", err)
+		}
+	}
+
+	if len(limits.CPUSet) != 0 {
+		if err := limitCPUSet(name, limits, pid); err != nil {
+			return fmt.Errorf("cpuset: %s", err)
+		}
+	}
+
+	if limits.BlkioWeight != 0 || len(limits.BlkioThrottle) != 0 {
+		if err := limitBlkio(name, limits, pid); err != nil {
+			return fmt.Errorf("blkio: %s", err)
+		}
+	}
+
+	if limits.Pids != 0 {
+		if err := limitPids(name, limits, pid); err != nil {
+			return fmt.Errorf("pids: %s", err)
+		}
+	}
+
+	return nil
+}
+
+//Unlimit removes the transient cgroups created by Limit, it returns true if a process was killed
+//because it ran out of memory
+func Unlimit(name string) bool {
+	oom := false
+	if group, err := Get(MemorySubsystem, name); err == nil {
+		oom, _ = group.(MemoryGroup).OOMKilled()
+	}
+
+	for _, subsystem := range limitSubsystems {
+		if err := Remove(subsystem, name); err != nil {
+			log.Errorf("failed to remove cgroup %s/%s: %s", subsystem, name, err)
+		}
+	}
+
+	return oom
+}
+
+//Limit implements pm.LimitHandler
+func (l *jobLimiter) Limit(cmd *pm.Command, pid int) error {
+	return Limit(l.name(cmd), cmd.Limits, pid)
+}
+
+//Unlimit implements pm.LimitHandler
+func (l *jobLimiter) Unlimit(cmd *pm.Command) bool {
+	return Unlimit(l.name(cmd))
+}
+
+var _ pm.LimitHandler = &jobLimiter{}
@@ -11,6 +11,7 @@ type MemoryGroup interface {
 	Group
 	Limits() (int, int, error)
 	Limit(mem, swap int) error
+	OOMKilled() (bool, error)
 }
 
 func mkMemoryGroup(name string, subsys Subsystem) Group {
@@ -85,6 +86,30 @@ func (c *memoryCGroup) Limit(mem, swap int) error {
 	return nil
 }
 
+//OOMKilled checks if the oom killer killed a process of the group
+func (c *memoryCGroup) OOMKilled() (bool, error) {
+	data, err := ioutil.ReadFile(path.Join(c.base(), "memory.oom_control"))
+	if err != nil {
+		return false, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		var key string
+		var value int
+		if _, err := fmt.Sscanf(line, "%s %d", &key, &value); err != nil {
+			continue
+		}
+
+		//under_oom only means the group hit its limit, oom_kill (4.13+ kernels) counts the
+		//processes the oom killer actually killed
+		if key == "oom_kill" && value > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 func (c *memoryCGroup) Root() Group {
 	return &memoryCGroup{
 		cgroup: cgroup{subsys: c.subsys},
@@ -0,0 +1,69 @@
+package cgroups
+
+import (
+	"path"
+)
+
+func mkMemoryGroupV2(name string, subsys Subsystem) Group {
+	return &memoryCGroupV2{
+		cgroup{name: name, subsys: subsys},
+	}
+}
+
+//memoryCGroupV2 memory group of the unified hierarchy, unlike memsw in v1, the swap limit does not
+//include the memory
+type memoryCGroupV2 struct {
+	cgroup
+}
+
+func (c *memoryCGroupV2) Reset() {
+	writeValue(path.Join(c.base(), "memory.swap.max"), -1)
+	writeValue(path.Join(c.base(), "memory.max"), -1)
+}
+
+//Limits returns mem, mem+swap, err like the v1 group, -1 means no limit
+func (c *memoryCGroupV2) Limits() (int, int, error) {
+	mem, err := readValue(path.Join(c.base(), "memory.max"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	swap, err := readValue(path.Join(c.base(), "memory.swap.max"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if mem < 0 || swap < 0 {
+		return mem, -1, nil
+	}
+
+	return mem, mem + swap, nil
+}
+
+func (c *memoryCGroupV2) Limit(mem, swap int) error {
+	if mem != 0 {
+		if err := writeValue(path.Join(c.base(), "memory.max"), mem); err != nil {
+			return err
+		}
+	}
+
+	return writeValue(path.Join(c.base(), "memory.swap.max"), swap)
+}
+
+//OOMKilled checks if the oom killer killed a process of the group
+func (c *memoryCGroupV2) OOMKilled() (bool, error) {
+	events, err := readKeyed(path.Join(c.base(), "memory.events"))
+	if err != nil {
+		return false, err
+	}
+
+	return events["oom_kill"] > 0, nil
+}
+
+func (c *memoryCGroupV2) Root() Group {
+	return &memoryCGroupV2{
+		cgroup: cgroup{subsys: c.subsys},
+	}
+}
+
+var _ MemoryGroup = &memoryCGroupV2{}
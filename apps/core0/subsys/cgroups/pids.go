@@ -0,0 +1,84 @@
+package cgroups
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+const (
+	pidsUnlimited = "max"
+)
+
+type PidsGroup interface {
+	Group
+	//Max sets the max number of processes (and threads) of the group, -1 means no limit
+	Max(max int) error
+	GetMax() (int, error)
+	Current() (int, error)
+}
+
+func mkPidsGroup(name string, subsys Subsystem) Group {
+	return &pidsCGroup{
+		cgroup{name: name, subsys: subsys},
+	}
+}
+
+type pidsCGroup struct {
+	cgroup
+}
+
+func (c *pidsCGroup) Reset() {
+	c.Max(-1)
+}
+
+func (c *pidsCGroup) Max(max int) error {
+	value := pidsUnlimited
+	if max >= 0 {
+		value = fmt.Sprint(max)
+	}
+
+	return ioutil.WriteFile(path.Join(c.base(), "pids.max"), []byte(value), 0644)
+}
+
+func (c *pidsCGroup) GetMax() (int, error) {
+	data, err := ioutil.ReadFile(path.Join(c.base(), "pids.max"))
+	if err != nil {
+		return 0, err
+	}
+
+	value := strings.TrimSpace(string(data))
+	if value == pidsUnlimited {
+		return -1, nil
+	}
+
+	var max int
+	if _, err := fmt.Sscanf(value, "%d", &max); err != nil {
+		return 0, err
+	}
+
+	return max, nil
+}
+
+func (c *pidsCGroup) Current() (int, error) {
+	data, err := ioutil.ReadFile(path.Join(c.base(), "pids.current"))
+	if err != nil {
+		return 0, err
+	}
+
+	var current int
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(data)), "%d", &current); err != nil {
+		return 0, err
+	}
+
+	return current, nil
+}
+
+func (c *pidsCGroup) Root() Group {
+	return &pidsCGroup{
+		cgroup: cgroup{subsys: c.subsys},
+	}
+}
+
+var _ PidsGroup = &pidsCGroup{}
@@ -0,0 +1,135 @@
+package cgroups
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+
+	"github.com/threefoldtech/0-core/base/utils"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	//unifiedSubsystems implements the subsystems on top of the cgroup v2 unified hierarchy
+	unifiedSubsystems = map[Subsystem]mkg{
+		DevicesSubsystem: mkDevicesGroupV2,
+		CPUSetSubsystem:  mkCPUSetGroupV2,
+		MemorySubsystem:  mkMemoryGroupV2,
+		CPUSubsystem:     mkCPUGroupV2,
+		BlkioSubsystem:   mkBlkioGroupV2,
+		FreezerSubsystem: mkFreezerGroupV2,
+		PidsSubsystem:    mkPidsGroup,
+	}
+
+	//unifiedControllers the cgroup v2 controllers enabled for the groups, the freezer is built in
+	//the unified hierarchy, and the devices are controlled with eBPF programs
+	unifiedControllers = []string{"cpuset", "cpu", "io", "memory", "pids"}
+)
+
+//isUnified checks if the cgroup v2 unified hierarchy must be used, this is the case if the unified
+//hierarchy is already mounted, or if the kernel was booted with the v1 controllers disabled
+func isUnified() bool {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(CGroupBase, &stat); err == nil && stat.Type == unix.CGROUP2_SUPER_MAGIC {
+		return true
+	}
+
+	kernel := utils.GetKernelOptions()
+	if values, ok := kernel.Get("cgroup_no_v1"); ok && utils.InString(values, "all") {
+		return true
+	}
+
+	if values, ok := kernel.Get("systemd.unified_cgroup_hierarchy"); ok && utils.InString(values, "1") {
+		return true
+	}
+
+	return false
+}
+
+//mountUnified mounts the unified hierarchy (if not mounted yet) and enables the controllers for
+//the groups
+func mountUnified() error {
+	os.MkdirAll(CGroupBase, 0755)
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(CGroupBase, &stat); err != nil || stat.Type != unix.CGROUP2_SUPER_MAGIC {
+		if err := syscall.Mount("cgroup2", CGroupBase, "cgroup2", 0, ""); err != nil {
+			return err
+		}
+	}
+
+	data, err := ioutil.ReadFile(path.Join(CGroupBase, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+
+	available := strings.Fields(string(data))
+	for _, controller := range unifiedControllers {
+		if !utils.InString(available, controller) {
+			log.Warningf("cgroup controller '%s' is not available", controller)
+			continue
+		}
+
+		//controllers are enabled one by one, so a failure doesn't leave the others disabled
+		control := fmt.Sprintf("+%s", controller)
+		if err := ioutil.WriteFile(path.Join(CGroupBase, "cgroup.subtree_control"), []byte(control), 0644); err != nil {
+			log.Errorf("failed to enable cgroup controller '%s': %s", controller, err)
+		}
+	}
+
+	return nil
+}
+
+//readValue reads a single value file of the unified hierarchy, the unlimited value 'max' is returned as -1
+func readValue(name string) (int, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return -1, nil
+	}
+
+	var i int
+	if _, err := fmt.Sscanf(value, "%d", &i); err != nil {
+		return 0, err
+	}
+
+	return i, nil
+}
+
+//writeValue writes a single value file of the unified hierarchy, negative values are written as 'max'
+func writeValue(name string, value int) error {
+	data := "max"
+	if value >= 0 {
+		data = fmt.Sprint(value)
+	}
+
+	return ioutil.WriteFile(name, []byte(data), 0644)
+}
+
+//readKeyed reads a flat keyed file of the unified hierarchy (like memory.events or cpu.stat)
+func readKeyed(name string) (map[string]uint64, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(string(data), "\n") {
+		var key string
+		var value uint64
+		if _, err := fmt.Sscanf(line, "%s %d", &key, &value); err != nil {
+			continue
+		}
+
+		values[key] = value
+	}
+
+	return values, nil
+}
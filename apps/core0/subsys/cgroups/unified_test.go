@@ -0,0 +1,54 @@
+package cgroups
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-core/base/pm"
+)
+
+func TestCPUSharesToWeight(t *testing.T) {
+	for shares, weight := range map[int]int{0: 1, 2: 1, 1024: 39, 262144: 10000, 300000: 10000} {
+		if ok := assert.Equal(t, weight, sharesToWeight(shares), "shares: %d", shares); !ok {
+			t.Error()
+		}
+	}
+
+	if ok := assert.Equal(t, 262144, weightToShares(10000)); !ok {
+		t.Error()
+	}
+}
+
+func TestParseCPUMax(t *testing.T) {
+	quota, period, err := parseCPUMax("max 100000\n")
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, []int{-1, 100000}, []int{quota, period}); !ok {
+		t.Error()
+	}
+
+	quota, period, err = parseCPUMax("50000 100000\n")
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, []int{50000, 100000}, []int{quota, period}); !ok {
+		t.Error()
+	}
+}
+
+func TestParseIOMax(t *testing.T) {
+	throttles, err := parseIOMax("8:16 rbps=max wbps=1024 riops=max wiops=max\n8:0 rbps=2048 wbps=max riops=10 wiops=max\n")
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, []pm.BlkioThrottle{
+		{Device: "8:0", ReadBps: 2048, ReadIOps: 10},
+		{Device: "8:16", WriteBps: 1024},
+	}, throttles); !ok {
+		t.Error()
+	}
+}
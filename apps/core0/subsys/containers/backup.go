@@ -10,7 +10,6 @@ import (
 	"os"
 	"path"
 	"regexp"
-	"syscall"
 )
 
 const (
@@ -44,12 +43,14 @@ func (m *containerManager) backup(cmd *pm.Command) (interface{}, error) {
 		return nil, fmt.Errorf("container does not exist")
 	}
 
-	//pause container
-	//TODO: avoid race if cont has just started and pid is not set yet!
-	if cont.PID == 0 {
-		return nil, fmt.Errorf("container is not fully started yet")
+	//freeze the container, so the snapshot is consistent
+	release, err := cont.hold()
+	if err != nil {
+		return nil, err
 	}
 
+	defer release()
+
 	u, err := url.Parse(args.URL)
 	if err != nil {
 		return nil, err
@@ -87,7 +88,8 @@ func (m *containerManager) backup(cmd *pm.Command) (interface{}, error) {
 	cargs := cont.Args
 	var nics []*Nic
 	for _, n := range cargs.Nics {
-		if n.State == NicStateConfigured {
+		//the nics of a stopped container are configured again when it starts
+		if n.State == NicStateConfigured || (n.State == "" && cont.PID == 0) {
 			nics = append(nics, n)
 		}
 	}
@@ -125,10 +127,6 @@ func (m *containerManager) backup(cmd *pm.Command) (interface{}, error) {
 
 	restic = append(restic, files...)
 
-	//pause container
-	syscall.Kill(-cont.PID, syscall.SIGSTOP)
-	defer syscall.Kill(-cont.PID, syscall.SIGCONT)
-
 	job, err := pm.Run(
 		&pm.Command{
 			Command: pm.CommandSystem,
@@ -199,7 +197,7 @@ func (m *containerManager) restore(cmd *pm.Command) (interface{}, error) {
 	cargs.Root = fmt.Sprintf("restic:%s", args.URL)
 	cargs.Tags = cmd.Tags //override original tags
 
-	cont, err := m.createContainer(cargs)
+	cont, _, err := m.createContainer(cargs)
 	if err != nil {
 		return nil, err
 	}
@@ -21,6 +21,13 @@ type Message struct {
 	Payload json.RawMessage `json:"payload"`
 }
 
+//Input is sent to coreX in place of a command, it feeds the input of an interactive job without running
+//a job.stdin command, so no result is produced for each input
+type Input struct {
+	Stdin string    `json:"stdin"`
+	Input *pm.Input `json:"input"`
+}
+
 func (c *container) forward() {
 	log.Debugf("start commands forwarder for '%s'", c.name())
 	enc := json.NewEncoder(c.channel)
@@ -29,9 +36,21 @@ func (c *container) forward() {
 		log.Errorf("failed to send magic number: %s", err)
 	}
 
-	for cmd := range c.forwardChan {
-		if err := enc.Encode(cmd); err != nil {
-			log.Errorf("failed to forward command (%s) to container (%d)", cmd.ID, c.id)
+	commands, inputs := c.forwardChan, c.inputChan
+	for {
+		select {
+		case cmd, ok := <-commands:
+			if !ok {
+				return
+			}
+
+			if err := enc.Encode(cmd); err != nil {
+				log.Errorf("failed to forward command (%s) to container (%d)", cmd.ID, c.id)
+			}
+		case input := <-inputs:
+			if err := enc.Encode(input); err != nil {
+				log.Errorf("failed to forward input of job (%s) to container (%d)", input.Stdin, c.id)
+			}
 		}
 	}
 }
@@ -56,6 +75,7 @@ func (c *container) rewind() {
 				log.Errorf("failed to load container command result: %s", err)
 			}
 			result.Container = uint64(c.id)
+			c.detach(result.ID)
 			c.mgr.sink.Forward(&result)
 		case "log":
 			var msg stream.Message
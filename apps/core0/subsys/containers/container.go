@@ -9,6 +9,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/threefoldtech/0-core/apps/core0/helper/socat"
 	"github.com/threefoldtech/0-core/apps/core0/logger"
 	"github.com/threefoldtech/0-core/apps/core0/subsys/cgroups"
 	"github.com/threefoldtech/0-core/base/pm"
@@ -19,12 +20,31 @@ const (
 	OVSTag       = "ovs"
 	OVSBackPlane = "backplane"
 	OVSVXBackend = "vxbackend"
+
+	//DefaultStopTimeout grace period given to coreX to exit before it's killed
+	DefaultStopTimeout = 10 * time.Second
+)
+
+const (
+	//ContainerStateCreated the container is being created, coreX is not started yet
+	ContainerStateCreated = ContainerState("created")
+	//ContainerStateRunning coreX is running
+	ContainerStateRunning = ContainerState("running")
+	//ContainerStatePaused all the container processes are frozen
+	ContainerStatePaused = ContainerState("paused")
+	//ContainerStateStopped the container was stopped, the sandbox and the configuration are kept
+	ContainerStateStopped = ContainerState("stopped")
+	//ContainerStateExited coreX exited on its own, the sandbox and the configuration are kept
+	ContainerStateExited = ContainerState("exited")
 )
 
 var (
 	devicesToBind = []string{"random", "urandom", "null"}
 )
 
+//ContainerState is the lifecycle state of a container
+type ContainerState string
+
 type container struct {
 	id     uint16
 	runner pm.Job
@@ -32,22 +52,37 @@ type container struct {
 	Args   ContainerCreateArguments `json:"arguments"`
 	Root   string                   `json:"root"`
 	PID    int                      `json:"pid"`
+	State  ContainerState           `json:"state"`
 
-	zterr error
-	zto   sync.Once
+	zterr  error
+	zto    sync.Once
+	ztDone chan struct{}
 
 	channel     pm.Channel
 	forwardChan chan *pm.Command
+	inputChan   chan *Input
+
+	//attached interactive jobs, closed when the job result is received
+	attached  map[string]chan struct{}
+	attachedM sync.Mutex
 
+	//lifeM serializes the container start, stop and terminate, commands are dispatched under
+	//its read lock
+	lifeM       sync.RWMutex
+	stopping    bool
 	terminating bool
+	//exited is closed once the current coreX run has exited, the exit is handled under lifeM by
+	//stop or terminate if they are waiting for it, or by the run monitor otherwise
+	exited chan struct{}
 }
 
 func newContainer(mgr *containerManager, id uint16, args ContainerCreateArguments) *container {
 	c := &container{
-		mgr:         mgr,
-		id:          id,
-		Args:        args,
-		forwardChan: make(chan *pm.Command),
+		mgr:      mgr,
+		id:       id,
+		Args:     args,
+		State:    ContainerStateCreated,
+		attached: make(map[string]chan struct{}),
 	}
 	c.Root = c.root()
 	return c
@@ -58,6 +93,13 @@ func (c *container) ID() uint16 {
 }
 
 func (c *container) dispatch(cmd *pm.Command) error {
+	c.lifeM.RLock()
+	defer c.lifeM.RUnlock()
+
+	if c.State != ContainerStateRunning {
+		return pm.PreconditionFailedError(fmt.Errorf("container is not running"))
+	}
+
 	select {
 	case c.forwardChan <- cmd:
 	case <-time.After(5 * time.Second):
@@ -66,24 +108,89 @@ func (c *container) dispatch(cmd *pm.Command) error {
 	return nil
 }
 
+//feed sends the input of an attached interactive job to coreX. The input channel is never closed, so
+//unlike dispatch, feed doesn't hold lifeM while waiting for coreX
+func (c *container) feed(id string, input *pm.Input, done <-chan struct{}) error {
+	c.lifeM.RLock()
+	inputs := c.inputChan
+	running := c.State == ContainerStateRunning
+	c.lifeM.RUnlock()
+
+	if !running {
+		return pm.PreconditionFailedError(fmt.Errorf("container is not running"))
+	}
+
+	select {
+	case inputs <- &Input{Stdin: id, Input: input}:
+		return nil
+	case <-done:
+		return fmt.Errorf("job has exited")
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("failed to feed input to container, check system logs for errors")
+	}
+}
+
+//attach registers an interactive job, the returned channel is closed once the job exits
+func (c *container) attach(id string) <-chan struct{} {
+	c.attachedM.Lock()
+	defer c.attachedM.Unlock()
+
+	done := make(chan struct{})
+	c.attached[id] = done
+	return done
+}
+
+//detach releases an attached job
+func (c *container) detach(id string) {
+	c.attachedM.Lock()
+	defer c.attachedM.Unlock()
+
+	if done, ok := c.attached[id]; ok {
+		close(done)
+		delete(c.attached, id)
+	}
+}
+
 func (c *container) Arguments() ContainerCreateArguments {
 	return c.Args
 }
 
+//Start starts a created container, or a stopped (or exited) container in its existing sandbox
 func (c *container) Start() (runner pm.Job, err error) {
+	c.lifeM.Lock()
+	defer c.lifeM.Unlock()
+
+	return c.start()
+}
+
+func (c *container) start() (runner pm.Job, err error) {
 	coreID := fmt.Sprintf("core-%d", c.id)
 
-	defer func() {
-		if err != nil {
-			c.cleanup()
+	switch c.State {
+	case ContainerStateRunning:
+		return nil, pm.PreconditionFailedError(fmt.Errorf("container is already running"))
+	case ContainerStateCreated:
+		defer func() {
+			if err != nil {
+				c.cleanup()
+			}
+		}()
+
+		if err = c.sandbox(); err != nil {
+			log.Errorf("error in container mount: %s", err)
+			return
 		}
-	}()
-
-	if err = c.sandbox(); err != nil {
-		log.Errorf("error in container mount: %s", err)
-		return
+	default:
+		//the sandbox is kept, only the network is configured again
+		defer func() {
+			if err != nil {
+				c.teardown()
+			}
+		}()
 	}
 
+	c.forwardChan = make(chan *pm.Command)
+	c.inputChan = make(chan *Input)
 	if err = c.preStart(); err != nil {
 		log.Errorf("error in container prestart: %s", err)
 		return
@@ -121,12 +228,21 @@ func (c *container) Start() (runner pm.Job, err error) {
 		),
 	}
 
+	//the pid hook can run before RunFactory returns, it waits for the job of this run
+	started := make(chan pm.Job, 1)
 	onpid := &pm.PIDHook{
-		Action: c.onStart,
+		Action: func(pid int) {
+			c.onStart(<-started, pid)
+		},
 	}
 
+	//the exit hook can't take lifeM, stop and terminate hold it while waiting for the runner
+	exited := make(chan struct{})
 	onexit := &pm.ExitHook{
-		Action: c.onExit,
+		Action: func(state bool) {
+			log.Debugf("Container %v exited with state %v", c.id, state)
+			close(exited)
+		},
 	}
 
 	runner, err = pm.RunFactory(extCmd, pm.NewContainerProcess, onpid, onexit)
@@ -135,19 +251,217 @@ func (c *container) Start() (runner pm.Job, err error) {
 		return
 	}
 
+	started <- runner
 	c.runner = runner
+	c.exited = exited
+	c.State = ContainerStateRunning
+
+	go c.monitor(exited)
 	return
 }
 
+//monitor handles the exit of a coreX run that exited on its own
+func (c *container) monitor(exited chan struct{}) {
+	<-exited
+
+	c.lifeM.Lock()
+	defer c.lifeM.Unlock()
+
+	c.onExit(exited)
+}
+
+//Stop sends SIGTERM to coreX, and kills it if it didn't exit after the timeout. The network is
+//destroyed, but the sandbox and the configuration are kept so the container can be started again
+func (c *container) Stop(timeout time.Duration) error {
+	c.lifeM.Lock()
+	defer c.lifeM.Unlock()
+
+	return c.stop(timeout)
+}
+
+func (c *container) stop(timeout time.Duration) error {
+	if c.State == ContainerStatePaused {
+		//frozen processes don't handle the signals
+		if err := c.resume(); err != nil {
+			return err
+		}
+	}
+
+	if c.State != ContainerStateRunning {
+		return pm.PreconditionFailedError(fmt.Errorf("container is not running"))
+	}
+
+	c.stopping = true
+	defer func() {
+		c.stopping = false
+	}()
+
+	exited := c.exited
+	c.runner.Signal(syscall.SIGTERM)
+	select {
+	case <-exited:
+	case <-time.After(timeout):
+		log.Warningf("container %d did not stop after %s, killing", c.id, timeout)
+		c.runner.Signal(syscall.SIGKILL)
+		<-exited
+	}
+
+	c.onExit(exited)
+	return nil
+}
+
+//Restart stops the container (if running) and starts it again
+func (c *container) Restart(timeout time.Duration) error {
+	c.lifeM.Lock()
+	defer c.lifeM.Unlock()
+
+	if c.State == ContainerStateRunning || c.State == ContainerStatePaused {
+		if err := c.stop(timeout); err != nil {
+			return err
+		}
+	}
+
+	_, err := c.start()
+	return err
+}
+
 func (c *container) Terminate() error {
-	if c.runner == nil {
+	c.lifeM.Lock()
+	defer c.lifeM.Unlock()
+
+	if c.terminating {
+		return nil
+	}
+
+	if c.State == ContainerStatePaused {
+		//frozen processes don't handle the signals
+		if err := c.resume(); err != nil {
+			return err
+		}
+	}
+
+	switch c.State {
+	case ContainerStateRunning:
+		c.terminating = true
+		exited := c.exited
+		c.runner.Signal(syscall.SIGTERM)
+		<-exited
+		c.onExit(exited)
+	case ContainerStateStopped, ContainerStateExited:
+		c.terminating = true
+		c.cleanup()
+	default:
 		return fmt.Errorf("container was not started")
 	}
-	c.runner.Signal(syscall.SIGTERM)
-	c.runner.Wait()
+
 	return nil
 }
 
+//cgroupName is the name of the container own cgroups, the freezer and the limits cgroups
+func (c *container) cgroupName() string {
+	return fmt.Sprintf("corex-%d", c.id)
+}
+
+func (c *container) freezer() (cgroups.FreezerGroup, error) {
+	group, err := cgroups.GetGroup(cgroups.FreezerSubsystem, c.cgroupName())
+	if err != nil {
+		return nil, err
+	}
+
+	freezer, ok := group.(cgroups.FreezerGroup)
+	if !ok {
+		return nil, cgroups.ErrInvalidType
+	}
+
+	return freezer, nil
+}
+
+//Pause freezes all the container processes
+func (c *container) Pause() error {
+	c.lifeM.Lock()
+	defer c.lifeM.Unlock()
+
+	return c.pause()
+}
+
+func (c *container) pause() error {
+	if c.State != ContainerStateRunning {
+		return pm.PreconditionFailedError(fmt.Errorf("container is not running"))
+	}
+
+	//TODO: avoid race if cont has just started and pid is not set yet!
+	if c.PID == 0 {
+		return pm.PreconditionFailedError(fmt.Errorf("container is not fully started yet"))
+	}
+
+	freezer, err := c.freezer()
+	if err != nil {
+		return err
+	}
+
+	if err := freezer.Freeze(); err != nil {
+		return err
+	}
+
+	c.State = ContainerStatePaused
+	return nil
+}
+
+//Resume thaws the container processes
+func (c *container) Resume() error {
+	c.lifeM.Lock()
+	defer c.lifeM.Unlock()
+
+	return c.resume()
+}
+
+func (c *container) resume() error {
+	if c.State != ContainerStatePaused {
+		return pm.PreconditionFailedError(fmt.Errorf("container is not paused"))
+	}
+
+	freezer, err := c.freezer()
+	if err != nil {
+		return err
+	}
+
+	if err := freezer.Thaw(); err != nil {
+		return err
+	}
+
+	c.State = ContainerStateRunning
+	return nil
+}
+
+//hold freezes a running container until the returned release function is called, so its file
+//system is consistent. A paused, stopped or exited container is left as is
+func (c *container) hold() (func(), error) {
+	c.lifeM.Lock()
+	defer c.lifeM.Unlock()
+
+	switch c.State {
+	case ContainerStateRunning:
+		if err := c.pause(); err != nil {
+			return nil, err
+		}
+
+		return func() {
+			c.lifeM.Lock()
+			defer c.lifeM.Unlock()
+			//the container could have been resumed (or stopped) meanwhile
+			if c.State == ContainerStatePaused {
+				if err := c.resume(); err != nil {
+					log.Errorf("failed to resume container %d: %s", c.id, err)
+				}
+			}
+		}, nil
+	case ContainerStateCreated:
+		return nil, pm.PreconditionFailedError(fmt.Errorf("container is not fully started yet"))
+	}
+
+	return func() {}, nil
+}
+
 func (c *container) preStart() error {
 	if c.Args.HostNetwork {
 		return c.preStartHostNetworking()
@@ -160,30 +474,55 @@ func (c *container) preStart() error {
 	return nil
 }
 
-func (c *container) onStart(pid int) {
+func (c *container) onStart(runner pm.Job, pid int) {
 	//get channel
-	ps := c.runner.Process()
+	ps := runner.Process()
 	if ps, ok := ps.(pm.ContainerProcess); !ok {
 		log.Errorf("not a valid container process")
-		c.runner.Signal(syscall.SIGTERM)
+		runner.Signal(syscall.SIGTERM)
 		return
 	} else {
 		c.channel = ps.Channel()
 	}
 
 	c.PID = pid
-	if !c.Args.Privileged {
+	if !c.Args.Privileged && !c.hasCGroup(DevicesCGroup) {
 		c.Args.CGroups = append(c.Args.CGroups, DevicesCGroup)
 	}
 
+	//the freezer is used to pause the container
+	if freezer, err := c.freezer(); err != nil {
+		log.Errorf("failed to create container freezer: %s", err)
+	} else if err := freezer.Task(pid); err != nil {
+		log.Errorf("failed to add container to freezer: %s", err)
+	}
+
+	if c.Args.Limits != nil {
+		if err := cgroups.Limit(c.cgroupName(), c.Args.Limits, pid); err != nil {
+			//the container doesn't run without its limits
+			log.Errorf("failed to apply container %d limits: %s", c.id, err)
+			runner.Signal(syscall.SIGKILL)
+			return
+		}
+	}
+
+	//on the unified hierarchy the container stays in its own group, the devices rules are
+	//applied to this group
 	for _, cgroup := range c.Args.CGroups {
+		if cgroups.Unified() && cgroup.Subsystem() != cgroups.DevicesSubsystem {
+			log.Warningf("container %d: ignoring cgroup %s/%s on the unified hierarchy, use limits instead", c.id, cgroup.Subsystem(), cgroup.Name())
+			continue
+		}
+
 		group, err := cgroups.Get(cgroup.Subsystem(), cgroup.Name())
 		if err != nil {
 			log.Errorf("can't find cgroup %s", err)
 			continue
 		}
 
-		group.Task(pid)
+		if err := group.Task(pid); err != nil {
+			log.Errorf("failed to add container %d to cgroup %s/%s: %s", c.id, cgroup.Subsystem(), cgroup.Name(), err)
+		}
 	}
 
 	if err := c.postStart(); err != nil {
@@ -195,33 +534,120 @@ func (c *container) onStart(pid int) {
 	go c.forward()
 }
 
-func (c *container) onExit(state bool) {
-	c.terminating = true
-	log.Debugf("Container %v exited with state %v", c.id, state)
-	tags := strings.Join(c.Args.Tags, ".")
-	defer c.cleanup()
-	if len(tags) == 0 {
+func (c *container) hasCGroup(cgroup CGroup) bool {
+	for _, g := range c.Args.CGroups {
+		if g == cgroup {
+			return true
+		}
+	}
+
+	return false
+}
+
+//onExit handles the exit of a coreX run, it must be called with lifeM held. The exit is only handled
+//once, by the first of stop, terminate or the run monitor
+func (c *container) onExit(exited chan struct{}) {
+	if c.exited != exited {
 		return
 	}
-	logger.Current.LogRecord(&logger.LogRecord{
-		Command: fmt.Sprintf("container.%s", tags),
-		Message: &stream.Message{
-			Meta: stream.NewMeta(0, stream.ExitSuccessFlag),
-		},
-	})
+
+	c.exited = nil
+
+	defer func() {
+		if tags := strings.Join(c.Args.Tags, "."); len(tags) != 0 {
+			logger.Current.LogRecord(&logger.LogRecord{
+				Command: fmt.Sprintf("container.%s", tags),
+				Message: &stream.Message{
+					Meta: stream.NewMeta(0, stream.ExitSuccessFlag),
+				},
+			})
+		}
+	}()
+
+	switch {
+	case c.terminating:
+		c.cleanup()
+	case c.stopping:
+		c.teardown()
+		c.State = ContainerStateStopped
+	default:
+		c.teardown()
+		c.State = ContainerStateExited
+	}
 }
 
-func (c *container) cleanup() {
-	log.Debugf("cleaning up container-%d", c.id)
-	defer c.mgr.unsetContainer(c.id)
+//teardown releases what is only needed while coreX is running, the attached jobs, the coreX
+//channel and the network. The nics and port forwards configuration is kept, so the container
+//can be started again
+func (c *container) teardown() {
+	c.attachedM.Lock()
+	for id, done := range c.attached {
+		close(done)
+		delete(c.attached, id)
+	}
+	c.attachedM.Unlock()
+
+	if c.forwardChan != nil {
+		close(c.forwardChan)
+		c.forwardChan = nil
+		c.inputChan = nil
+	}
 
-	close(c.forwardChan)
 	if c.channel != nil {
 		c.channel.Close()
+		c.channel = nil
+	}
+
+	c.runner = nil
+
+	//the port forwards are only tracked by socat
+	if c.PID != 0 {
+		if ports, err := socat.List(c.forwardId()); err == nil {
+			c.Args.Port = ports
+		}
+	}
+
+	if c.ztDone != nil {
+		close(c.ztDone)
+		c.ztDone = nil
+	}
+
+	//nics removed with nic-remove are kept destroyed
+	removed := make([]bool, len(c.Args.Nics))
+	for i, nic := range c.Args.Nics {
+		removed[i] = nic.State == NicStateDestroyed
 	}
 
 	c.destroyNetwork()
 
+	for i, nic := range c.Args.Nics {
+		if !removed[i] {
+			nic.State = ""
+		}
+	}
+
+	//limits go first, on the unified hierarchy the freezer is the same group
+	if c.Args.Limits != nil && cgroups.Unlimit(c.cgroupName()) {
+		log.Warningf("container %d ran out of memory", c.id)
+	}
+
+	if err := cgroups.Remove(cgroups.FreezerSubsystem, c.cgroupName()); err != nil {
+		log.Errorf("failed to remove container freezer: %s", err)
+	}
+
+	c.zto = sync.Once{}
+	c.PID = 0
+}
+
+//cleanup releases all the container resources, the container is gone after cleanup
+func (c *container) cleanup() {
+	log.Debugf("cleaning up container-%d", c.id)
+	defer c.mgr.unsetContainer(c.id)
+
+	if c.State != ContainerStateStopped && c.State != ContainerStateExited {
+		c.teardown()
+	}
+
 	if err := c.unMountAll(); err != nil {
 		log.Errorf("unmounting container-%d was not clean", err)
 	}
@@ -255,3 +681,18 @@ func (c *container) postStart() error {
 
 	return nil
 }
+
+//stats of the coreX process, empty if the container is not running
+func (c *container) stats() pm.ProcessStats {
+	var stats pm.ProcessStats
+	job, ok := pm.JobOf(fmt.Sprintf("core-%d", c.id))
+	if !ok {
+		return stats
+	}
+
+	if stater, ok := job.Process().(pm.Stater); ok {
+		stats = *(stater.Stats())
+	}
+
+	return stats
+}
@@ -0,0 +1,152 @@
+package containers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-core/base/pm"
+)
+
+func TestContainerNotRunning(t *testing.T) {
+	m := &containerManager{
+		containers: make(map[uint16]*container),
+	}
+
+	for _, state := range []ContainerState{ContainerStateCreated, ContainerStateStopped, ContainerStateExited} {
+		c := newContainer(m, 1, ContainerCreateArguments{})
+		c.State = state
+
+		err := c.dispatch(&pm.Command{ID: "id", Command: "core.ping"})
+		if ok := assert.Error(t, err, "state %s", state); !ok {
+			t.Fatal()
+		}
+
+		if ok := assert.Equal(t, uint32(412), err.(pm.RunError).Code()); !ok {
+			t.Error()
+		}
+
+		if ok := assert.Error(t, c.Stop(time.Second), "state %s", state); !ok {
+			t.Error()
+		}
+
+		if ok := assert.Error(t, c.Pause(), "state %s", state); !ok {
+			t.Error()
+		}
+
+		if ok := assert.Error(t, c.Resume(), "state %s", state); !ok {
+			t.Error()
+		}
+	}
+}
+
+func TestContainerFeed(t *testing.T) {
+	m := &containerManager{
+		containers: make(map[uint16]*container),
+	}
+
+	c := newContainer(m, 1, ContainerCreateArguments{})
+	c.State = ContainerStateRunning
+	c.inputChan = make(chan *Input)
+
+	done := make(chan struct{})
+	fed := make(chan error)
+	go func() {
+		fed <- c.feed("id", &pm.Input{Data: []byte("ls\n")}, done)
+	}()
+
+	//the container life cycle is not blocked while the input waits for coreX
+	locked := make(chan struct{})
+	go func() {
+		c.lifeM.Lock()
+		c.lifeM.Unlock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("feed holds the container lock")
+	}
+
+	input := <-c.inputChan
+	if ok := assert.Equal(t, "id", input.Stdin); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, []byte("ls\n"), input.Input.Data); !ok {
+		t.Error()
+	}
+
+	if ok := assert.NoError(t, <-fed); !ok {
+		t.Error()
+	}
+
+	close(done)
+	if ok := assert.Error(t, c.feed("id", &pm.Input{EOF: true}, done)); !ok {
+		t.Error()
+	}
+}
+
+func TestContainerHold(t *testing.T) {
+	m := &containerManager{
+		containers: make(map[uint16]*container),
+	}
+
+	c := newContainer(m, 1, ContainerCreateArguments{})
+	if _, err := c.hold(); err == nil {
+		t.Error("created container hold")
+	}
+
+	//nothing to freeze
+	c.State = ContainerStateStopped
+	release, err := c.hold()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release()
+	if ok := assert.Equal(t, ContainerStateStopped, c.State); !ok {
+		t.Error()
+	}
+}
+
+func TestContainerStopTimeout(t *testing.T) {
+	args := ContainerStopArguments{}
+	if ok := assert.Equal(t, DefaultStopTimeout, args.timeout()); !ok {
+		t.Error()
+	}
+
+	args.Timeout = 30
+	if ok := assert.Equal(t, 30*time.Second, args.timeout()); !ok {
+		t.Error()
+	}
+}
+
+func TestContainerLimitsValidate(t *testing.T) {
+	args := ContainerCreateArguments{
+		Root:   "https://hub.gig.tech/ubuntu.flist",
+		Limits: &pm.Limits{Memory: 1024 * 1024, Pids: 100},
+	}
+
+	if ok := assert.NoError(t, args.Validate()); !ok {
+		t.Error()
+	}
+
+	args.Limits.Pids = -1
+	if ok := assert.Error(t, args.Validate()); !ok {
+		t.Error()
+	}
+
+	//the memory limits and a memory cgroup can't be both set
+	args.Limits.Pids = 100
+	args.CGroups = []CGroup{{"memory", "shared"}}
+	err := args.Validate()
+	if ok := assert.Error(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Contains(t, err.Error(), "conflicts"); !ok {
+		t.Error()
+	}
+}
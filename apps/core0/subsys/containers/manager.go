@@ -10,6 +10,7 @@ import (
 	"path"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/op/go-logging"
 	"github.com/pborman/uuid"
@@ -30,6 +31,11 @@ const (
 	cmdContainerGet               = "corex.get"
 	cmdContainerDispatch          = "corex.dispatch"
 	cmdContainerTerminate         = "corex.terminate"
+	cmdContainerStop              = "corex.stop"
+	cmdContainerStart             = "corex.start"
+	cmdContainerRestart           = "corex.restart"
+	cmdContainerPause             = "corex.pause"
+	cmdContainerResume            = "corex.resume"
 	cmdContainerFind              = "corex.find"
 	cmdContainerZerotierInfo      = "corex.zerotier.info"
 	cmdContainerZerotierList      = "corex.zerotier.list"
@@ -115,6 +121,8 @@ type ContainerCreateArguments struct {
 	Env         map[string]string `json:"env"`          //environment variables.
 	CGroups     []CGroup          `json:"cgroups"`      //container creation cgroups
 	Config      map[string]string `json:"config"`       //overrides container config (from flist)
+	Persist     bool              `json:"persist"`      //recreate the container (with the same id) after a reboot
+	Limits      *pm.Limits        `json:"limits"`       //resources limits of the container processes
 }
 
 type ContainerDispatchArguments struct {
@@ -232,6 +240,21 @@ func (c *ContainerCreateArguments) Validate() error {
 		}
 	}
 
+	if c.Limits != nil {
+		if err := c.Limits.Validate(); err != nil {
+			return err
+		}
+
+		//a process can only be in one group of a subsystem
+		for _, subsystem := range cgroups.LimitSubsystems(c.Limits) {
+			for _, cgroup := range c.CGroups {
+				if cgroup.Subsystem() == subsystem {
+					return fmt.Errorf("cgroup %v conflicts with the %s limits", cgroup, subsystem)
+				}
+			}
+		}
+	}
+
 	for _, cgroup := range c.CGroups {
 		if !cgroups.Exists(cgroup.Subsystem(), cgroup.Name()) {
 			return fmt.Errorf("invalid cgroup %v", cgroup)
@@ -248,6 +271,10 @@ type containerManager struct {
 	containers map[uint16]*container
 	conM       sync.RWMutex
 
+	//reserved ids of the persisted containers that are not recreated yet
+	reserved map[uint16]struct{}
+	pending  []definition
+
 	cell *screen.RowCell
 
 	sink *transport.Sink
@@ -272,12 +299,14 @@ type ContainerManager interface {
 	GetWithTags(tags ...string) []Container
 	GetOneWithTags(tags ...string) Container
 	Of(id uint16) Container
+	Recreate()
 }
 
 func ContainerSubsystem(sink *transport.Sink, cell *screen.RowCell) (ContainerManager, error) {
 
 	containerMgr := &containerManager{
 		containers: make(map[uint16]*container),
+		reserved:   make(map[uint16]struct{}),
 		sink:       sink,
 		cell:       cell,
 	}
@@ -291,12 +320,19 @@ func ContainerSubsystem(sink *transport.Sink, cell *screen.RowCell) (ContainerMa
 		return nil, err
 	}
 
+	sink.SetContainerResolver(containerMgr.tags)
+
 	pm.RegisterBuiltIn(cmdContainerCreate, containerMgr.create)
 	pm.RegisterBuiltIn(cmdContainerCreateSync, containerMgr.createSync)
 	pm.RegisterBuiltIn(cmdContainerList, containerMgr.list)
 	pm.RegisterBuiltIn(cmdContainerGet, containerMgr.get)
 	pm.RegisterBuiltIn(cmdContainerDispatch, containerMgr.dispatch)
 	pm.RegisterBuiltIn(cmdContainerTerminate, containerMgr.terminate)
+	pm.RegisterBuiltIn(cmdContainerStop, containerMgr.stop)
+	pm.RegisterBuiltIn(cmdContainerStart, containerMgr.start)
+	pm.RegisterBuiltIn(cmdContainerRestart, containerMgr.restart)
+	pm.RegisterBuiltIn(cmdContainerPause, containerMgr.pause)
+	pm.RegisterBuiltIn(cmdContainerResume, containerMgr.resume)
 	pm.RegisterBuiltIn(cmdContainerFind, containerMgr.find)
 	pm.RegisterBuiltIn(cmdContainerNicAdd, containerMgr.nicAdd)
 	pm.RegisterBuiltIn(cmdContainerNicRemove, containerMgr.nicRemove)
@@ -312,6 +348,8 @@ func ContainerSubsystem(sink *transport.Sink, cell *screen.RowCell) (ContainerMa
 	pm.RegisterBuiltIn(cmdContainerZerotierInfo, containerMgr.ztInfo)
 	pm.RegisterBuiltIn(cmdContainerZerotierList, containerMgr.ztList)
 
+	containerMgr.reserve()
+
 	return containerMgr, nil
 }
 
@@ -385,7 +423,9 @@ func (m *containerManager) getNextSequence() uint16 {
 	for {
 		m.sequence += 1
 		if m.sequence != 0 && m.sequence < math.MaxUint16 {
-			if _, ok := m.containers[m.sequence]; !ok {
+			_, used := m.containers[m.sequence]
+			_, reserved := m.reserved[m.sequence]
+			if !used && !reserved {
 				break
 			}
 		}
@@ -419,13 +459,19 @@ func (m *containerManager) nicAdd(cmd *pm.Command) (interface{}, error) {
 		return nil, pm.BadRequestError(err)
 	}
 
-	m.conM.RLock()
-	defer m.conM.RUnlock()
-	container, ok := m.containers[args.Container]
-	if !ok {
+	_, container := m.getByID(args.Container)
+	if container == nil {
 		return nil, pm.NotFoundError(fmt.Errorf("container does not exist"))
 	}
 
+	//the container can't be stopped while its network is changed
+	container.lifeM.RLock()
+	defer container.lifeM.RUnlock()
+
+	if container.State != ContainerStateRunning {
+		return nil, pm.PreconditionFailedError(fmt.Errorf("container is not running"))
+	}
+
 	if container.Args.HostNetwork {
 		return nil, pm.BadRequestError(fmt.Errorf("cannot add a nic in host network mode"))
 	}
@@ -449,6 +495,10 @@ func (m *containerManager) nicAdd(cmd *pm.Command) (interface{}, error) {
 		return nil, err
 	}
 
+	if err := m.persist(container); err != nil {
+		log.Errorf("failed to persist container %d: %s", container.id, err)
+	}
+
 	return nil, nil
 }
 
@@ -462,13 +512,19 @@ func (m *containerManager) nicRemove(cmd *pm.Command) (interface{}, error) {
 		return nil, pm.BadRequestError(err)
 	}
 
-	m.conM.RLock()
-	defer m.conM.RUnlock()
-	container, ok := m.containers[args.Container]
-	if !ok {
+	_, container := m.getByID(args.Container)
+	if container == nil {
 		return nil, pm.NotFoundError(fmt.Errorf("container does not exist"))
 	}
 
+	//the container can't be stopped while its network is changed
+	container.lifeM.RLock()
+	defer container.lifeM.RUnlock()
+
+	if container.State != ContainerStateRunning {
+		return nil, pm.PreconditionFailedError(fmt.Errorf("container is not running"))
+	}
+
 	if args.Index < 0 || args.Index >= len(container.Args.Nics) {
 		return nil, pm.BadRequestError(fmt.Errorf("nic index out of range"))
 	}
@@ -485,24 +541,31 @@ func (m *containerManager) nicRemove(cmd *pm.Command) (interface{}, error) {
 		}
 
 		nic.State = NicStateDestroyed
-		return nil, nil
-	}
+	} else if nic.Type == "macvlan" {
+		if err := container.unLink(args.Index, nic); err != nil {
+			return nil, err
+		}
+	} else {
+		var ovs Container
+		if nic.Type == "vlan" || nic.Type == "vxlan" {
+			ovs = m.GetOneWithTags("ovs")
+		}
 
-	if nic.Type == "macvlan" {
-		return nil, container.unLink(args.Index, nic)
+		if err := container.unBridge(args.Index, nic, ovs); err != nil {
+			return nil, err
+		}
 	}
 
-	var ovs Container
-	if nic.Type == "vlan" || nic.Type == "vxlan" {
-		ovs = m.GetOneWithTags("ovs")
+	if err := m.persist(container); err != nil {
+		log.Errorf("failed to persist container %d: %s", container.id, err)
 	}
 
-	return nil, container.unBridge(args.Index, nic, ovs)
+	return nil, nil
 }
 
-func (m *containerManager) createContainer(args ContainerCreateArguments) (*container, error) {
+func (m *containerManager) createContainer(args ContainerCreateArguments) (*container, pm.Job, error) {
 	if err := args.Validate(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	m.conM.RLock()
@@ -514,18 +577,27 @@ func (m *containerManager) createContainer(args ContainerCreateArguments) (*cont
 	}
 
 	if count >= limit {
-		return nil, pm.ServiceUnavailableError(fmt.Errorf("reached the hard limit of %d containers", count))
+		return nil, nil, pm.ServiceUnavailableError(fmt.Errorf("reached the hard limit of %d containers", count))
 	}
 
-	id := m.getNextSequence()
+	return m.startContainer(m.getNextSequence(), args)
+}
+
+//startContainer starts a (validated) container with the given id, it returns the container and its coreX job
+func (m *containerManager) startContainer(id uint16, args ContainerCreateArguments) (*container, pm.Job, error) {
 	c := newContainer(m, id, args)
 	m.setContainer(id, c)
 
-	if _, err := c.Start(); err != nil {
-		return nil, err
+	runner, err := c.Start()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return c, nil
+	if err := m.persist(c); err != nil {
+		log.Errorf("failed to persist container %d: %s", id, err)
+	}
+
+	return c, runner, nil
 }
 
 func (m *containerManager) createSync(cmd *pm.Command) (interface{}, error) {
@@ -536,14 +608,18 @@ func (m *containerManager) createSync(cmd *pm.Command) (interface{}, error) {
 	}
 
 	args.Tags = cmd.Tags
-	container, err := m.createContainer(args)
+	container, runner, err := m.createContainer(args)
 	if err != nil {
 		log.Errorf("failed to start container: %s", err)
 		return nil, err
 	}
 
 	//after waiting we probably need to return the full result!
-	return container.runner.Wait(), nil
+	result := runner.Wait()
+	//the exited container is not kept
+	container.Terminate()
+
+	return result, nil
 }
 
 func (m *containerManager) create(cmd *pm.Command) (interface{}, error) {
@@ -553,7 +629,7 @@ func (m *containerManager) create(cmd *pm.Command) (interface{}, error) {
 	}
 
 	args.Tags = cmd.Tags
-	container, err := m.createContainer(args)
+	container, _, err := m.createContainer(args)
 	if err != nil {
 		return nil, err
 	}
@@ -618,11 +694,16 @@ func (m *containerManager) get(cmd *pm.Command) (interface{}, error) {
 		return nil, nil
 	}
 
-	ports, err := socat.List(cont.forwardId())
-	if err != nil {
-		return nil, err
+	cont.lifeM.RLock()
+	defer cont.lifeM.RUnlock()
+
+	if cont.State == ContainerStateRunning {
+		ports, err := socat.List(cont.forwardId())
+		if err != nil {
+			return nil, err
+		}
+		cont.Args.Port = ports
 	}
-	cont.Args.Port = ports
 
 	response := struct {
 		Container *container `json:"container"`
@@ -643,24 +724,24 @@ func (m *containerManager) list(cmd *pm.Command) (interface{}, error) {
 		return nil, err
 	}
 
+	//the containers lock is not held while waiting for a container lock, a terminated container
+	//is removed under its lock
 	m.conM.RLock()
-	defer m.conM.RUnlock()
+	all := make(map[uint16]*container, len(m.containers))
 	for id, c := range m.containers {
-		c.Args.Port, _ = rules[c.forwardId()]
-		name := fmt.Sprintf("core-%d", id)
-		job, ok := pm.JobOf(name)
-		if !ok {
-			continue
-		}
-		ps := job.Process()
-		var state pm.ProcessStats
-		if ps != nil {
-			if stater, ok := ps.(pm.Stater); ok {
-				state = *(stater.Stats())
-			}
+		all[id] = c
+	}
+	m.conM.RUnlock()
+
+	for id, c := range all {
+		c.lifeM.RLock()
+		if c.State == ContainerStateRunning {
+			c.Args.Port, _ = rules[c.forwardId()]
 		}
+		c.lifeM.RUnlock()
+
 		containers[id] = ContainerInfo{
-			ProcessStats: state,
+			ProcessStats: c.stats(),
 			Container:    c,
 		}
 	}
@@ -673,8 +754,30 @@ func (m *containerManager) getCoreXQueue(id uint16) string {
 }
 
 func (m *containerManager) pushToContainer(container *container, cmd *pm.Command) error {
+	if cmd.Webhook != nil {
+		if err := cmd.Webhook.Validate(); err != nil {
+			return pm.BadRequestError(err)
+		}
+	}
+
 	m.sink.Flag(cmd.ID)
-	return container.dispatch(cmd)
+	m.sink.Watch(cmd)
+	if !pm.IsInteractive(cmd) {
+		return container.dispatch(cmd)
+	}
+
+	//the job input is pumped from the job stdin list to coreX as input messages
+	done := container.attach(cmd.ID)
+	if err := container.dispatch(cmd); err != nil {
+		container.detach(cmd.ID)
+		return err
+	}
+
+	go m.sink.Pump(cmd.ID, container.id, done, func(input *pm.Input) error {
+		return container.feed(cmd.ID, input, done)
+	})
+
+	return nil
 }
 
 func (m *containerManager) dispatch(cmd *pm.Command) (interface{}, error) {
@@ -742,9 +845,94 @@ func (m *containerManager) terminate(cmd *pm.Command) (interface{}, error) {
 		return nil, fmt.Errorf("no container with id '%d'", args.Container)
 	}
 
+	m.forget(args.Container)
 	return nil, container.Terminate()
 }
 
+type ContainerStopArguments struct {
+	Container uint16 `json:"container"`
+	Timeout   int    `json:"timeout"` //seconds to wait for coreX to exit before it's killed
+}
+
+func (a *ContainerStopArguments) timeout() time.Duration {
+	if a.Timeout <= 0 {
+		return DefaultStopTimeout
+	}
+
+	return time.Duration(a.Timeout) * time.Second
+}
+
+func (m *containerManager) stop(cmd *pm.Command) (interface{}, error) {
+	var args ContainerStopArguments
+	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
+		return nil, pm.BadRequestError(err)
+	}
+
+	_, container := m.getByID(args.Container)
+	if container == nil {
+		return nil, pm.NotFoundError(fmt.Errorf("container does not exist"))
+	}
+
+	return nil, container.Stop(args.timeout())
+}
+
+func (m *containerManager) start(cmd *pm.Command) (interface{}, error) {
+	var args ContainerArguments
+	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
+		return nil, pm.BadRequestError(err)
+	}
+
+	_, container := m.getByID(args.Container)
+	if container == nil {
+		return nil, pm.NotFoundError(fmt.Errorf("container does not exist"))
+	}
+
+	_, err := container.Start()
+	return nil, err
+}
+
+func (m *containerManager) restart(cmd *pm.Command) (interface{}, error) {
+	var args ContainerStopArguments
+	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
+		return nil, pm.BadRequestError(err)
+	}
+
+	_, container := m.getByID(args.Container)
+	if container == nil {
+		return nil, pm.NotFoundError(fmt.Errorf("container does not exist"))
+	}
+
+	return nil, container.Restart(args.timeout())
+}
+
+func (m *containerManager) pause(cmd *pm.Command) (interface{}, error) {
+	var args ContainerArguments
+	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
+		return nil, pm.BadRequestError(err)
+	}
+
+	_, container := m.getByID(args.Container)
+	if container == nil {
+		return nil, pm.NotFoundError(fmt.Errorf("container does not exist"))
+	}
+
+	return nil, container.Pause()
+}
+
+func (m *containerManager) resume(cmd *pm.Command) (interface{}, error) {
+	var args ContainerArguments
+	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
+		return nil, pm.BadRequestError(err)
+	}
+
+	_, container := m.getByID(args.Container)
+	if container == nil {
+		return nil, pm.NotFoundError(fmt.Errorf("container does not exist"))
+	}
+
+	return nil, container.Resume()
+}
+
 type ContainerFindArguments struct {
 	Tags []string `json:"tags"`
 }
@@ -758,21 +946,8 @@ func (m *containerManager) find(cmd *pm.Command) (interface{}, error) {
 	containers := m.GetWithTags(args.Tags...)
 	result := make(map[uint16]ContainerInfo)
 	for _, c := range containers {
-		name := fmt.Sprintf("core-%d", c.ID())
-		job, ok := pm.JobOf(name)
-		if !ok {
-			continue
-		}
-		ps := job.Process()
-		var state pm.ProcessStats
-		if ps != nil {
-			if stater, ok := ps.(pm.Stater); ok {
-				state = *(stater.Stats())
-			}
-		}
-
 		result[c.ID()] = ContainerInfo{
-			ProcessStats: state,
+			ProcessStats: c.(*container).stats(),
 			Container:    c,
 		}
 	}
@@ -814,19 +989,36 @@ func (m *containerManager) Of(id uint16) Container {
 	return cont
 }
 
+//tags resolves the container tags, to authorize the commands run on the container
+func (m *containerManager) tags(id uint16) (pm.Tags, bool) {
+	m.conM.RLock()
+	defer m.conM.RUnlock()
+	cont, ok := m.containers[id]
+	if !ok {
+		return nil, false
+	}
+
+	return cont.Args.Tags, true
+}
+
 func (m *containerManager) portforwardAdd(cmd *pm.Command) (interface{}, error) {
 	var args containerPortForward
 	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
 		return nil, pm.BadRequestError(err)
 	}
 
-	m.conM.RLock()
-	defer m.conM.RUnlock()
-
-	container, ok := m.containers[args.Container]
-	if !ok {
+	_, container := m.getByID(args.Container)
+	if container == nil {
 		return nil, pm.NotFoundError(fmt.Errorf("container does not exist"))
 	}
+
+	//the container can't be stopped while its network is changed
+	container.lifeM.RLock()
+	defer container.lifeM.RUnlock()
+
+	if container.State != ContainerStateRunning {
+		return nil, pm.PreconditionFailedError(fmt.Errorf("container is not running"))
+	}
 	var defaultNic bool
 	for _, nic := range container.Args.Nics {
 		if nic.Type == "default" {
@@ -842,6 +1034,10 @@ func (m *containerManager) portforwardAdd(cmd *pm.Command) (interface{}, error)
 		return nil, err
 	}
 
+	if err := m.persist(container); err != nil {
+		log.Errorf("failed to persist container %d: %s", container.id, err)
+	}
+
 	return nil, nil
 }
 
@@ -851,18 +1047,27 @@ func (m *containerManager) portforwardRemove(cmd *pm.Command) (interface{}, erro
 		return nil, pm.BadRequestError(err)
 	}
 
-	m.conM.RLock()
-	defer m.conM.RUnlock()
-
-	container, ok := m.containers[args.Container]
-	if !ok {
+	_, container := m.getByID(args.Container)
+	if container == nil {
 		return nil, pm.NotFoundError(fmt.Errorf("container does not exist"))
 	}
 
+	//the container can't be stopped while its network is changed
+	container.lifeM.RLock()
+	defer container.lifeM.RUnlock()
+
+	if container.State != ContainerStateRunning {
+		return nil, pm.PreconditionFailedError(fmt.Errorf("container is not running"))
+	}
+
 	if err := socat.RemovePortForward(container.forwardId(), args.HostPort, args.ContainerPort); err != nil {
 		return nil, err
 	}
 
+	if err := m.persist(container); err != nil {
+		log.Errorf("failed to persist container %d: %s", container.id, err)
+	}
+
 	return nil, nil
 }
 
@@ -97,14 +97,17 @@ func (c *container) startZerotier() (pm.Job, error) {
 	return job, err
 }
 
-func (c *container) watchZerotier(job pm.Job) {
+func (c *container) watchZerotier(job pm.Job, done <-chan struct{}) {
 	for {
 		if job != nil {
 			job.Wait()
 		}
 
-		if c.terminating {
+		select {
+		case <-done:
+			//the container network is destroyed
 			return
+		default:
 		}
 
 		var err error
@@ -119,8 +122,9 @@ func (c *container) watchZerotier(job pm.Job) {
 
 func (c *container) zerotierDaemon() error {
 	c.zto.Do(func() {
+		//the home is kept when the container is stopped, so it keeps the same zerotier identity
+		//when it starts again
 		home := c.zerotierHome()
-		os.RemoveAll(home)
 		os.MkdirAll(home, 0755)
 
 		if len(c.Args.Identity) > 0 {
@@ -141,7 +145,8 @@ func (c *container) zerotierDaemon() error {
 			log.Errorf("error while starting zerotier daemon for container: %d (%s): re-spawning", c.id, c.zterr)
 		}
 		//start the watcher anyway
-		go c.watchZerotier(job)
+		c.ztDone = make(chan struct{})
+		go c.watchZerotier(job, c.ztDone)
 	})
 
 	return c.zterr
@@ -669,6 +674,10 @@ func (c *container) postStartIsolatedNetworking() error {
 	}
 
 	for idx, network := range c.Args.Nics {
+		if network.State == NicStateDestroyed {
+			continue
+		}
+
 		if err := c.postStartNetwork(idx, network); err != nil {
 			log.Errorf("failed to initialize network '%v': %s", network, err)
 		}
@@ -706,6 +715,10 @@ func (c *container) preStartNetwork(idx int, network *Nic) (err error) {
 
 func (c *container) preStartIsolatedNetworking() error {
 	for idx, network := range c.Args.Nics {
+		if network.State == NicStateDestroyed {
+			continue
+		}
+
 		if err := c.preStartNetwork(idx, network); err != nil {
 			return err
 		}
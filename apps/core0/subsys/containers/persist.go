@@ -0,0 +1,304 @@
+package containers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/threefoldtech/0-core/apps/core0/helper/socat"
+	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/settings"
+)
+
+const (
+	//DefaultContainersStore default directory of the persisted containers definitions
+	DefaultContainersStore = "/var/cache/core0/containers"
+
+	persistExt = ".json"
+
+	//recreateRetries number of times a persisted container is tried to be recreated at boot
+	recreateRetries = 30
+	//recreateInterval interval between two recreate attempts
+	recreateInterval = 10 * time.Second
+)
+
+//definition is the persisted definition of a container
+type definition struct {
+	ID        uint16                   `json:"id"`
+	Arguments ContainerCreateArguments `json:"arguments"`
+}
+
+func (m *containerManager) storeDir() string {
+	if dir := settings.Settings.Containers.Store; len(dir) != 0 {
+		return dir
+	}
+
+	return DefaultContainersStore
+}
+
+func (m *containerManager) definitionPath(id uint16) string {
+	return path.Join(m.storeDir(), fmt.Sprintf("%d%s", id, persistExt))
+}
+
+//persist writes the container definition to disk (if the container is persisted), it must be called
+//each time the container arguments change
+func (m *containerManager) persist(c *container) error {
+	if !c.Args.Persist {
+		return nil
+	}
+
+	//the port forwards are only tracked by socat
+	ports, err := socat.List(c.forwardId())
+	if err != nil {
+		ports = c.Args.Port
+	}
+
+	return m.save(c.definition(ports))
+}
+
+//definition builds the container definition, so it can be created again from scratch
+func (c *container) definition(ports map[string]int) *definition {
+	args := c.Args
+	args.Port = ports
+
+	//the nics are configured again
+	args.Nics = nil
+	for _, nic := range c.Args.Nics {
+		if nic.State == NicStateDestroyed {
+			continue
+		}
+
+		n := *nic
+		n.State = ""
+		args.Nics = append(args.Nics, &n)
+	}
+
+	//the devices cgroup is added when the container starts
+	args.CGroups = nil
+	for _, cgroup := range c.Args.CGroups {
+		if !c.Args.Privileged && cgroup == DevicesCGroup {
+			continue
+		}
+		args.CGroups = append(args.CGroups, cgroup)
+	}
+
+	return &definition{ID: c.id, Arguments: args}
+}
+
+func (m *containerManager) save(def *definition) error {
+	data, err := json.Marshal(def)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.storeDir(), 0700); err != nil {
+		return err
+	}
+
+	name := m.definitionPath(def.ID)
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, name)
+}
+
+//forget removes the container definition, the container is not recreated on next boot
+func (m *containerManager) forget(id uint16) {
+	if err := os.Remove(m.definitionPath(id)); err != nil && !os.IsNotExist(err) {
+		log.Errorf("failed to remove definition of container %d: %s", id, err)
+	}
+}
+
+//definitions loads the persisted containers definitions
+func (m *containerManager) definitions() ([]definition, error) {
+	entries, err := ioutil.ReadDir(m.storeDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var defs []definition
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), persistExt) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(path.Join(m.storeDir(), entry.Name()))
+		if err != nil {
+			log.Errorf("failed to read container definition %s: %s", entry.Name(), err)
+			continue
+		}
+
+		var def definition
+		if err := json.Unmarshal(data, &def); err != nil || def.ID == 0 {
+			log.Errorf("invalid container definition %s: %v", entry.Name(), err)
+			continue
+		}
+
+		defs = append(defs, def)
+	}
+
+	return ordered(defs), nil
+}
+
+//dependsOn checks if the container a depends on the container b. A container with a vlan or vxlan nic
+//depends on the ovs container, and a container that mounts a path of another container depends on it
+func dependsOn(a, b *definition) bool {
+	if a.ID == b.ID {
+		return false
+	}
+
+	for _, tag := range b.Arguments.Tags {
+		if tag != OVSTag {
+			continue
+		}
+
+		for _, nic := range a.Arguments.Nics {
+			if nic.Type == "vlan" || nic.Type == "vxlan" {
+				return true
+			}
+		}
+	}
+
+	root := path.Join(ContainerBaseRootDir, fmt.Sprint(b.ID))
+	for host := range a.Arguments.Mount {
+		if host == root || strings.HasPrefix(host, root+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+//ordered sorts the definitions so the containers are created after the containers they depend on,
+//otherwise by id
+func ordered(defs []definition) []definition {
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].ID < defs[j].ID
+	})
+
+	var result []definition
+	done := make(map[uint16]bool)
+	for len(result) < len(defs) {
+		progress := false
+		for i := range defs {
+			def := &defs[i]
+			if done[def.ID] {
+				continue
+			}
+
+			ready := true
+			for j := range defs {
+				if !done[defs[j].ID] && dependsOn(def, &defs[j]) {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				result = append(result, *def)
+				done[def.ID] = true
+				progress = true
+			}
+		}
+
+		if !progress {
+			//dependency cycle, the rest is created by id
+			for _, def := range defs {
+				if !done[def.ID] {
+					result = append(result, def)
+					done[def.ID] = true
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+//reserve loads the persisted definitions, and reserves their ids so they are not used by new containers
+//until they are recreated
+func (m *containerManager) reserve() {
+	defs, err := m.definitions()
+	if err != nil {
+		log.Errorf("failed to load persisted containers: %s", err)
+		return
+	}
+
+	m.seqM.Lock()
+	defer m.seqM.Unlock()
+	for _, def := range defs {
+		m.reserved[def.ID] = struct{}{}
+	}
+
+	m.pending = defs
+}
+
+func (m *containerManager) release(id uint16) {
+	m.seqM.Lock()
+	defer m.seqM.Unlock()
+	delete(m.reserved, id)
+}
+
+func (m *containerManager) recreate(def *definition) error {
+	if err := def.Arguments.Validate(); err != nil {
+		return err
+	}
+
+	_, _, err := m.startContainer(def.ID, def.Arguments)
+	return err
+}
+
+//Recreate recreates the persisted containers with their ids, in dependency order. The containers that
+//fail to start (their bridge doesn't exist yet for example) are retried
+func (m *containerManager) Recreate() {
+	m.seqM.Lock()
+	pending := m.pending
+	m.pending = nil
+	m.seqM.Unlock()
+
+	for attempt := 0; len(pending) > 0 && attempt < recreateRetries; attempt++ {
+		if attempt > 0 {
+			<-time.After(recreateInterval)
+		}
+
+		var failed []definition
+		for i := range pending {
+			def := &pending[i]
+			if job, ok := pm.JobOf(fmt.Sprintf("core-%d", def.ID)); ok {
+				//the coreX of the container survived a core0 restart, it can't be managed without its
+				//channel, the id stays reserved until it exits so it's not given to a new container
+				log.Warningf("container %d survived a core0 restart, it is not managed until it exits", def.ID)
+				go func(id uint16) {
+					job.Wait()
+					m.release(id)
+				}(def.ID)
+				continue
+			}
+
+			if err := m.recreate(def); err != nil {
+				log.Errorf("failed to recreate container %d (attempt %d): %s", def.ID, attempt+1, err)
+				failed = append(failed, *def)
+				continue
+			}
+
+			log.Infof("container %d recreated", def.ID)
+			m.release(def.ID)
+		}
+
+		pending = failed
+	}
+
+	for _, def := range pending {
+		log.Errorf("giving up recreating container %d", def.ID)
+		m.release(def.ID)
+	}
+}
@@ -0,0 +1,172 @@
+package containers
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/settings"
+)
+
+func TestPersistOrder(t *testing.T) {
+	defs := []definition{
+		{ID: 1, Arguments: ContainerCreateArguments{Nics: []*Nic{{Type: "vlan"}}}},
+		{ID: 2, Arguments: ContainerCreateArguments{Mount: map[string]string{"/mnt/containers/4/data": "/data"}}},
+		{ID: 3},
+		{ID: 4, Arguments: ContainerCreateArguments{Tags: []string{OVSTag}}},
+	}
+
+	var ids []uint16
+	for _, def := range ordered(defs) {
+		ids = append(ids, def.ID)
+	}
+
+	if ok := assert.Equal(t, []uint16{3, 4, 1, 2}, ids); !ok {
+		t.Error()
+	}
+}
+
+func TestPersistOrderCycle(t *testing.T) {
+	defs := []definition{
+		{ID: 2, Arguments: ContainerCreateArguments{Mount: map[string]string{"/mnt/containers/1": "/one"}}},
+		{ID: 1, Arguments: ContainerCreateArguments{Mount: map[string]string{"/mnt/containers/2": "/two"}}},
+	}
+
+	if ok := assert.Len(t, ordered(defs), 2); !ok {
+		t.Error()
+	}
+}
+
+func TestPersistDefinitions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "containers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	settings.Settings.Containers.Store = dir
+	defer func() {
+		settings.Settings.Containers.Store = ""
+	}()
+
+	m := &containerManager{
+		containers: make(map[uint16]*container),
+		reserved:   make(map[uint16]struct{}),
+	}
+
+	persisted := newContainer(m, 5, ContainerCreateArguments{
+		Name:    "web",
+		Persist: true,
+		Nics: []*Nic{
+			{Type: "default", State: NicStateConfigured},
+			{Type: "zerotier", ID: "network", State: NicStateDestroyed},
+		},
+		CGroups: []CGroup{DevicesCGroup},
+	})
+
+	if err := m.save(persisted.definition(nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	//not persisted
+	if err := m.persist(newContainer(m, 6, ContainerCreateArguments{Name: "temp"})); err != nil {
+		t.Fatal(err)
+	}
+
+	m.reserve()
+	if ok := assert.Len(t, m.pending, 1); !ok {
+		t.Fatal()
+	}
+
+	def := m.pending[0]
+	if ok := assert.Equal(t, uint16(5), def.ID); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, "web", def.Arguments.Name); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, []*Nic{{Type: "default"}}, def.Arguments.Nics); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Empty(t, def.Arguments.CGroups); !ok {
+		t.Error()
+	}
+
+	//the reserved id is skipped
+	m.sequence = 4
+	if ok := assert.Equal(t, uint16(6), m.getNextSequence()); !ok {
+		t.Error()
+	}
+
+	m.forget(5)
+	m.pending = nil
+	m.reserve()
+	if ok := assert.Empty(t, m.pending); !ok {
+		t.Error()
+	}
+}
+
+func TestPersistRecreateRunning(t *testing.T) {
+	if pm.MaxJobs == 0 {
+		pm.MaxJobs = 100
+	}
+
+	pm.New()
+	pm.Start()
+
+	hold := make(chan struct{})
+	pm.RegisterBuiltIn("test.corex", func(cmd *pm.Command) (interface{}, error) {
+		<-hold
+		return nil, nil
+	})
+
+	//coreX of container 7 survived a core0 restart
+	job, err := pm.Run(&pm.Command{ID: "core-7", Command: "test.corex"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &containerManager{
+		containers: make(map[uint16]*container),
+		reserved:   map[uint16]struct{}{7: {}},
+		pending:    []definition{{ID: 7}},
+	}
+
+	m.Recreate()
+
+	if ok := assert.Empty(t, m.containers); !ok {
+		t.Error()
+	}
+
+	m.seqM.Lock()
+	_, reserved := m.reserved[7]
+	m.seqM.Unlock()
+
+	if ok := assert.True(t, reserved, "id of the running container is released"); !ok {
+		t.Fatal()
+	}
+
+	close(hold)
+	job.Wait()
+
+	for i := 0; i < 50; i++ {
+		m.seqM.Lock()
+		_, reserved = m.reserved[7]
+		m.seqM.Unlock()
+
+		if !reserved {
+			return
+		}
+
+		<-time.After(10 * time.Millisecond)
+	}
+
+	t.Error("id is not released once the container exited")
+}
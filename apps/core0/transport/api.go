@@ -0,0 +1,430 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/pborman/uuid"
+	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/pm/stream"
+)
+
+const (
+	//APIPrefix path prefix of the http api
+	APIPrefix = "/v1"
+
+	//DefaultAPIWait default seconds to wait for a job result
+	DefaultAPIWait = 60
+	//MaxAPIWait max seconds to wait for a job result
+	MaxAPIWait = ReturnExpire
+
+	//apiStreamBuffer max number of messages buffered for a slow stream reader, the extra messages are dropped
+	apiStreamBuffer = 1000
+)
+
+//API is an http+json transport to run and control jobs, with the same semantics as the redis transport
+//
+//	POST   /v1/jobs                 submit a command (?wait=<seconds> to wait for its result)
+//	GET    /v1/jobs                 list the running jobs
+//	GET    /v1/jobs/{id}            get a running job
+//	GET    /v1/jobs/{id}/result     wait for the job result (?timeout=<seconds>)
+//	GET    /v1/jobs/{id}/stream     stream the job output as json lines until the job exits
+//	POST   /v1/jobs/{id}/stdin      feed the job input
+//	POST   /v1/jobs/{id}/signal     signal the job ({"signal": 15})
+//	POST   /v1/jobs/{id}/unschedule unschedule a recurring job
+//	DELETE /v1/jobs/{id}            kill the job
+//
+//The clients of the tls port are authorized with the identity of their certificate, the result and
+//stream routes are authorized as the job.result and job.stream commands.
+type API struct {
+	sink *Sink
+	mux  *http.ServeMux
+}
+
+//NewAPI creates the http api on top of the sink
+func NewAPI(sink *Sink) *API {
+	api := &API{
+		sink: sink,
+		mux:  http.NewServeMux(),
+	}
+
+	api.mux.HandleFunc(APIPrefix+"/jobs", api.jobs)
+	api.mux.HandleFunc(APIPrefix+"/jobs/", api.job)
+
+	return api
+}
+
+//apiError is the body of the api error responses
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func reply(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+func fail(w http.ResponseWriter, code int, err interface{}) {
+	reply(w, code, apiError{Error: fmt.Sprint(err)})
+}
+
+//ServeHTTP implements http.Handler
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mux.ServeHTTP(w, r)
+}
+
+func seconds(r *http.Request, key string, def int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if len(value) == 0 {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s '%s'", key, value)
+	}
+
+	if n > MaxAPIWait {
+		n = MaxAPIWait
+	}
+
+	return n, nil
+}
+
+//identity gets the identity of the client from its certificate, the clients of the unix socket
+//are trusted (nil identity)
+func identity(r *http.Request) *Identity {
+	if r.TLS == nil {
+		return nil
+	}
+
+	id := &Identity{}
+	if len(r.TLS.PeerCertificates) != 0 {
+		subject := r.TLS.PeerCertificates[0].Subject
+		id.Subject = subject.CommonName
+		id.Organizations = subject.Organization
+	}
+
+	return id
+}
+
+//submit submits the command and maps the submission errors to http errors, it returns false if
+//the command was not started
+func (a *API) submit(w http.ResponseWriter, r *http.Request, cmd *pm.Command) bool {
+	switch err := a.sink.Submit(cmd, identity(r)); err {
+	case nil:
+		return true
+	case ErrUnauthorized:
+		fail(w, http.StatusForbidden, fmt.Errorf("not allowed to run '%s'", cmd.Command))
+	case ErrNoID:
+		fail(w, http.StatusBadRequest, err)
+	case pm.DuplicateIDErr:
+		fail(w, http.StatusConflict, err)
+	case pm.UnknownCommandErr:
+		fail(w, http.StatusNotFound, fmt.Errorf("unknown command '%s'", cmd.Command))
+	default:
+		fail(w, http.StatusBadRequest, err)
+	}
+
+	return false
+}
+
+//allowed authorizes the routes that don't run a command, as if they were running the command on the job
+func (a *API) allowed(w http.ResponseWriter, r *http.Request, command, id string) bool {
+	client := identity(r)
+	if client == nil {
+		return true
+	}
+
+	cmd := &pm.Command{
+		ID:        uuid.New(),
+		Command:   command,
+		Arguments: pm.MustArguments(pm.M{"id": id}),
+	}
+
+	if err := a.sink.Authorize(cmd, client); err != nil {
+		fail(w, http.StatusForbidden, fmt.Errorf("not allowed to run '%s'", command))
+		return false
+	}
+
+	return true
+}
+
+//result waits for the job result
+func (a *API) result(w http.ResponseWriter, id string, timeout int) {
+	result, err := a.sink.GetResult(id, timeout)
+	if err == redis.ErrNil {
+		fail(w, http.StatusRequestTimeout, fmt.Errorf("job '%s' didn't exit in %d seconds", id, timeout))
+		return
+	} else if err != nil {
+		fail(w, http.StatusNotFound, err)
+		return
+	}
+
+	reply(w, http.StatusOK, result)
+}
+
+//call runs a builtin command, and replies with its result data, or its error
+func (a *API) call(w http.ResponseWriter, r *http.Request, command string, args interface{}) {
+	cmd := &pm.Command{
+		ID:        uuid.New(),
+		Command:   command,
+		Arguments: pm.MustArguments(args),
+	}
+
+	if !a.submit(w, r, cmd) {
+		return
+	}
+
+	result, err := a.sink.GetResult(cmd.ID, MaxAPIWait)
+	if err != nil {
+		fail(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if result.State == pm.StateSuccess {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(result.Data))
+		return
+	}
+
+	var msg string
+	if err := json.Unmarshal([]byte(result.Data), &msg); err != nil {
+		msg = result.Data
+	}
+
+	code := int(result.Code)
+	if code < 400 || code > 599 {
+		code = http.StatusInternalServerError
+	}
+
+	fail(w, code, msg)
+}
+
+func (a *API) jobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.call(w, r, "job.list", pm.M{})
+	case http.MethodPost:
+		var cmd pm.Command
+		if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if cmd.Arguments == nil {
+			cmd.Arguments = pm.MustArguments(pm.M{})
+		}
+
+		if len(cmd.ID) == 0 {
+			cmd.ID = uuid.New()
+		}
+
+		wait, err := seconds(r, "wait", 0)
+		if err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if !a.submit(w, r, &cmd) {
+			return
+		}
+
+		if wait == 0 {
+			reply(w, http.StatusAccepted, pm.M{"id": cmd.ID})
+			return
+		}
+
+		a.result(w, cmd.ID, wait)
+	default:
+		fail(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+	}
+}
+
+func (a *API) job(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, APIPrefix+"/jobs/"), "/")
+	if len(parts) > 2 || len(parts[0]) == 0 {
+		fail(w, http.StatusNotFound, fmt.Errorf("not found"))
+		return
+	}
+
+	id := parts[0]
+	var action string
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	route := fmt.Sprintf("%s %s", r.Method, action)
+	switch route {
+	case "GET ":
+		a.call(w, r, "job.list", pm.M{"id": id})
+	case "DELETE ":
+		a.call(w, r, "job.kill", pm.M{"id": id})
+	case "GET result":
+		timeout, err := seconds(r, "timeout", DefaultAPIWait)
+		if err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		if a.allowed(w, r, "job.result", id) {
+			a.result(w, id, timeout)
+		}
+	case "GET stream":
+		if a.allowed(w, r, "job.stream", id) {
+			a.stream(w, r, id)
+		}
+	case "POST stdin":
+		var input pm.Input
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		a.call(w, r, "job.stdin", struct {
+			ID string `json:"id"`
+			pm.Input
+		}{id, input})
+	case "POST signal":
+		var args struct {
+			Signal syscall.Signal `json:"signal"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		a.call(w, r, "job.kill", pm.M{"id": id, "signal": args.Signal})
+	case "POST unschedule":
+		a.call(w, r, "job.unschedule", pm.M{"id": id})
+	default:
+		fail(w, http.StatusNotFound, fmt.Errorf("not found"))
+	}
+}
+
+//stream writes the job output messages as json lines until the job exits or the client goes away
+func (a *API) stream(w http.ResponseWriter, r *http.Request, id string) {
+	job, ok := pm.JobOf(id)
+	if !ok {
+		fail(w, http.StatusNotFound, fmt.Errorf("job '%s' is not running", id))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		fail(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	messages := make(chan *stream.Message, apiStreamBuffer)
+	unsubscribe := job.Subscribe(func(msg *stream.Message) {
+		select {
+		case messages <- msg:
+		default:
+			//slow reader
+		}
+	})
+
+	defer unsubscribe()
+
+	exited := make(chan struct{})
+	go func() {
+		job.WaitContext(r.Context())
+		close(exited)
+	}()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case msg := <-messages:
+			if err := encoder.Encode(msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-exited:
+			//drain what's left
+			for {
+				select {
+				case msg := <-messages:
+					encoder.Encode(msg)
+				default:
+					flusher.Flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+//ListenUnix serves the api on a unix socket
+func (a *API) ListenUnix(path string) error {
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+
+	return a.serve(listener)
+}
+
+//ListenTLS serves the api on a tls port, clients must authenticate with a certificate signed by clientCA
+func (a *API) ListenTLS(address, cert, key, clientCA string) error {
+	if len(clientCA) == 0 {
+		return fmt.Errorf("client ca is required")
+	}
+
+	certificate, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return err
+	}
+
+	pem, err := ioutil.ReadFile(clientCA)
+	if err != nil {
+		return err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("no certificates found in '%s'", clientCA)
+	}
+
+	listener, err := tls.Listen("tcp", address, &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return a.serve(listener)
+}
+
+func (a *API) serve(listener net.Listener) error {
+	server := &http.Server{
+		Handler:           a,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return server.Serve(listener)
+}
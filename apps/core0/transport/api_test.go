@@ -0,0 +1,72 @@
+package transport
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIErrors(t *testing.T) {
+	api := NewAPI(nil)
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{http.MethodPost, "/v1/jobs", "{invalid", http.StatusBadRequest},
+		{http.MethodPut, "/v1/jobs", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/v1/jobs?wait=abc", `{"command": "core.ping"}`, http.StatusBadRequest},
+		{http.MethodGet, "/v1/jobs/", "", http.StatusNotFound},
+		{http.MethodGet, "/v1/jobs/id/unknown", "", http.StatusNotFound},
+		{http.MethodGet, "/v1/jobs/id/result/extra", "", http.StatusNotFound},
+		{http.MethodGet, "/v1/jobs/id/result?timeout=-1", "", http.StatusBadRequest},
+		{http.MethodGet, "/v1/jobs/not-running/stream", "", http.StatusNotFound},
+		{http.MethodPost, "/v1/jobs/id/signal", "", http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		request := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		recorder := httptest.NewRecorder()
+		api.ServeHTTP(recorder, request)
+
+		if ok := assert.Equal(t, c.code, recorder.Code, "%s %s", c.method, c.path); !ok {
+			t.Error()
+		}
+
+		var body apiError
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+
+		if ok := assert.NotEmpty(t, body.Error); !ok {
+			t.Error()
+		}
+	}
+}
+
+func TestAPISeconds(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/v1/jobs/id/result?timeout=100000", nil)
+	timeout, err := seconds(request, "timeout", DefaultAPIWait)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, MaxAPIWait, timeout); !ok {
+		t.Error()
+	}
+
+	timeout, err = seconds(request, "wait", 0)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, 0, timeout); !ok {
+		t.Error()
+	}
+}
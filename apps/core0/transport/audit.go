@@ -0,0 +1,633 @@
+package transport
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/settings"
+)
+
+const (
+	//AuditSubmitted the command was allowed and started
+	AuditSubmitted = "submitted"
+	//AuditRejected the command was not allowed to run
+	AuditRejected = "rejected"
+	//AuditFinished the command job exited
+	AuditFinished = "finished"
+
+	//DefaultAuditDir default directory of the audit log
+	DefaultAuditDir = "/var/log/core0/audit"
+	//DefaultAuditMaxSize default size after which the audit log file is rotated
+	DefaultAuditMaxSize = 10 * 1024 * 1024
+
+	//SubjectLocal subject of the trusted local clients
+	SubjectLocal = "local"
+
+	auditCurrent = "current.log"
+	auditExt     = ".log"
+
+	//auditMaxValue max length of an argument string value in the audit log
+	auditMaxValue = 1024
+	auditRedacted = "***"
+
+	//DefaultAuditQueryLimit default max number of entries returned by audit.query
+	DefaultAuditQueryLimit = 100
+	//MaxAuditQueryLimit max number of entries returned by audit.query
+	MaxAuditQueryLimit = 10000
+)
+
+var (
+	//auditSensitive arguments with one of these in their name are redacted
+	auditSensitive = []string{"pass", "secret", "token", "key", "identity", "credential", "auth", "private"}
+	//auditInput arguments that hold the input of a job, they are redacted
+	auditInput = map[string]struct{}{"stdin": {}, "data": {}}
+)
+
+//AuditEntry is an entry of the audit log. Each entry holds the hash of the previous one, so changing or
+//removing an entry breaks the chain
+type AuditEntry struct {
+	Seq   uint64 `json:"seq"`
+	Event string `json:"event"`
+	//Time of the event (epoch milliseconds)
+	Time      int64            `json:"time"`
+	ID        string           `json:"id"`
+	Command   string           `json:"command"`
+	Arguments *json.RawMessage `json:"arguments,omitempty"`
+	Subject   string           `json:"subject"`
+	Identity  *Identity        `json:"identity,omitempty"`
+	Container uint16           `json:"container,omitempty"`
+	Dispatch  string           `json:"dispatch,omitempty"`
+	Rule      string           `json:"rule,omitempty"`
+	Reason    string           `json:"reason,omitempty"`
+	State     pm.JobState      `json:"state,omitempty"`
+	//StartTime of the job (epoch milliseconds)
+	StartTime int64  `json:"starttime,omitempty"`
+	Prev      string `json:"prev"`
+	Hash      string `json:"hash"`
+}
+
+//digest computes the entry hash
+func (e *AuditEntry) digest() (string, error) {
+	entry := *e
+	entry.Hash = ""
+	data, err := json.Marshal(&entry)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+//AuditQuery filter of the audit.query builtin
+type AuditQuery struct {
+	ID string `json:"id"`
+	//Command pattern of the commands names (ex: core.*)
+	Command   string      `json:"command"`
+	Subject   string      `json:"subject"`
+	Container uint16      `json:"container"`
+	Event     string      `json:"event"`
+	State     pm.JobState `json:"state"`
+	//Start, End time range (epoch seconds) of the returned entries
+	Start  int64 `json:"start"`
+	End    int64 `json:"end"`
+	Offset int   `json:"offset"`
+	Limit  int   `json:"limit"`
+}
+
+func (q *AuditQuery) match(entry *AuditEntry) bool {
+	if len(q.ID) != 0 && q.ID != entry.ID {
+		return false
+	}
+
+	if len(q.Command) != 0 && !match([]string{q.Command}, entry.Command) {
+		return false
+	}
+
+	if len(q.Subject) != 0 && q.Subject != entry.Subject {
+		return false
+	}
+
+	if q.Container != 0 && q.Container != entry.Container {
+		return false
+	}
+
+	if len(q.Event) != 0 && q.Event != entry.Event {
+		return false
+	}
+
+	if len(q.State) != 0 && q.State != entry.State {
+		return false
+	}
+
+	seconds := entry.Time / 1000
+	if seconds < q.Start || (q.End != 0 && seconds > q.End) {
+		return false
+	}
+
+	return true
+}
+
+//AuditQueryResult is a page of audit.query results
+type AuditQueryResult struct {
+	Entries []*AuditEntry `json:"entries"`
+	//Next offset of the next page
+	Next int `json:"next"`
+	//More is true if there are more entries after this page
+	More bool `json:"more"`
+}
+
+//AuditVerifyResult result of the audit.verify builtin
+type AuditVerifyResult struct {
+	//Entries number of verified entries
+	Entries uint64 `json:"entries"`
+	Valid   bool   `json:"valid"`
+	//Broken seq of the first entry that doesn't match the chain
+	Broken uint64 `json:"broken,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+//audited is a running command, so its result is audited with its submission details
+type audited struct {
+	identity  *Identity
+	container uint16
+	dispatch  string
+}
+
+//auditLog is an append-only log of all the commands submitted to the sink, and their results
+type auditLog struct {
+	dir     string
+	maxSize int64
+
+	file *os.File
+	size int64
+	seq  uint64
+	last string
+
+	running map[string]*audited
+	m       sync.Mutex
+}
+
+//newAuditLog opens the audit log
+func newAuditLog(config settings.Audit) (*auditLog, error) {
+	a := &auditLog{
+		dir:     config.Dir,
+		maxSize: config.MaxSize,
+		running: make(map[string]*audited),
+	}
+
+	if len(a.dir) == 0 {
+		a.dir = DefaultAuditDir
+	}
+
+	if a.maxSize <= 0 {
+		a.maxSize = DefaultAuditMaxSize
+	}
+
+	if err := os.MkdirAll(a.dir, 0700); err != nil {
+		return nil, err
+	}
+
+	if err := a.recover(); err != nil {
+		return nil, err
+	}
+
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+//files lists the audit log files, oldest first
+func (a *auditLog) files() ([]string, error) {
+	entries, err := ioutil.ReadDir(a.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == auditCurrent || !strings.HasSuffix(name, auditExt) {
+			continue
+		}
+
+		files = append(files, path.Join(a.dir, name))
+	}
+
+	sort.Strings(files)
+	return append(files, path.Join(a.dir, auditCurrent)), nil
+}
+
+//recover finds the last entry of the log, so the chain continues from it
+func (a *auditLog) recover() error {
+	files, err := a.files()
+	if err != nil {
+		return err
+	}
+
+	//the current file can be empty right after a rotation
+	for i := len(files) - 1; i >= 0; i-- {
+		var last *AuditEntry
+		if err := scanAudit(files[i], func(entry *AuditEntry) bool {
+			last = entry
+			return true
+		}); err != nil {
+			return err
+		}
+
+		if last != nil {
+			a.seq = last.Seq
+			a.last = last.Hash
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (a *auditLog) open() error {
+	name := path.Join(a.dir, auditCurrent)
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	a.file = file
+	a.size = info.Size()
+
+	//terminate a partially written entry (crash), so the next entry starts on its own line
+	if a.size > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, a.size-1); err == nil && last[0] != '\n' {
+			file.Write([]byte{'\n'})
+			a.size++
+		}
+	}
+
+	return nil
+}
+
+func (a *auditLog) rotate() error {
+	a.file.Close()
+	name := path.Join(a.dir, fmt.Sprintf("%020d%s", time.Now().UnixNano(), auditExt))
+	if err := os.Rename(path.Join(a.dir, auditCurrent), name); err != nil {
+		//keep appending to the current file
+		if err := a.open(); err != nil {
+			log.Errorf("failed to reopen audit log: %s", err)
+		}
+		return err
+	}
+
+	return a.open()
+}
+
+//append chains the entry to the log
+func (a *auditLog) append(entry *AuditEntry) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	entry.Seq = a.seq + 1
+	entry.Prev = a.last
+
+	hash, err := entry.digest()
+	if err != nil {
+		log.Errorf("failed to audit job %s: %s", entry.ID, err)
+		return
+	}
+
+	entry.Hash = hash
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Errorf("failed to audit job %s: %s", entry.ID, err)
+		return
+	}
+
+	if a.size >= a.maxSize {
+		if err := a.rotate(); err != nil {
+			log.Errorf("failed to rotate audit log: %s", err)
+		}
+	}
+
+	data = append(data, '\n')
+	if _, err := a.file.Write(data); err != nil {
+		log.Errorf("failed to audit job %s: %s", entry.ID, err)
+		return
+	}
+
+	a.file.Sync()
+	a.size += int64(len(data))
+	a.seq = entry.Seq
+	a.last = entry.Hash
+}
+
+//submitted audits a command submission, the decision is nil if the command was not authorized
+func (a *auditLog) submitted(cmd *pm.Command, identity *Identity, decision *Decision, t *target) {
+	if a == nil {
+		return
+	}
+
+	entry := &AuditEntry{
+		Event:     AuditSubmitted,
+		Time:      time.Now().UnixNano() / int64(time.Millisecond),
+		ID:        cmd.ID,
+		Command:   cmd.Command,
+		Arguments: sanitize(cmd.Arguments),
+		Subject:   SubjectLocal,
+		Identity:  identity,
+	}
+
+	if identity != nil {
+		entry.Subject = identity.String()
+	}
+
+	if t != nil {
+		entry.Container = t.container
+		entry.Dispatch = t.dispatch
+	}
+
+	if decision != nil {
+		entry.Rule = decision.Rule
+		if !decision.Allowed {
+			entry.Event = AuditRejected
+			entry.Reason = decision.Reason
+		}
+	}
+
+	if entry.Event == AuditSubmitted {
+		a.m.Lock()
+		a.running[cmd.ID] = &audited{
+			identity:  identity,
+			container: entry.Container,
+			dispatch:  entry.Dispatch,
+		}
+		a.m.Unlock()
+	}
+
+	a.append(entry)
+}
+
+//finished audits the result of a submitted command
+func (a *auditLog) finished(result *pm.JobResult) {
+	if a == nil {
+		return
+	}
+
+	a.m.Lock()
+	job, ok := a.running[result.ID]
+	delete(a.running, result.ID)
+	a.m.Unlock()
+
+	if !ok {
+		return
+	}
+
+	entry := &AuditEntry{
+		Event:     AuditFinished,
+		Time:      time.Now().UnixNano() / int64(time.Millisecond),
+		ID:        result.ID,
+		Command:   result.Command,
+		Subject:   SubjectLocal,
+		Identity:  job.identity,
+		Container: job.container,
+		Dispatch:  job.dispatch,
+		State:     result.State,
+		StartTime: result.StartTime,
+	}
+
+	if job.identity != nil {
+		entry.Subject = job.identity.String()
+	}
+
+	a.append(entry)
+}
+
+//sanitize redacts the sensitive arguments (passwords, keys, jobs input, ...) and truncates the long values
+func sanitize(args *json.RawMessage) *json.RawMessage {
+	if args == nil {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(*args, &value); err != nil {
+		return nil
+	}
+
+	return pm.MustArguments(redact(value))
+}
+
+func sensitive(name string) bool {
+	name = strings.ToLower(name)
+	if _, ok := auditInput[name]; ok {
+		return true
+	}
+
+	for _, word := range auditSensitive {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func redact(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, v := range value {
+			if sensitive(key) {
+				value[key] = auditRedacted
+			} else {
+				value[key] = redact(v)
+			}
+		}
+	case []interface{}:
+		for i, v := range value {
+			value[i] = redact(v)
+		}
+	case string:
+		if len(value) > auditMaxValue {
+			return value[:auditMaxValue] + "..."
+		}
+	}
+
+	return value
+}
+
+//scanAudit calls fn with each entry of the audit file, it stops when fn returns false
+func scanAudit(name string, fn func(*AuditEntry) bool) error {
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var entry AuditEntry
+			if json.Unmarshal(line, &entry) == nil {
+				if !fn(&entry) {
+					return nil
+				}
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+//scan calls fn with all the entries of the log, oldest first
+func (a *auditLog) scan(fn func(*AuditEntry) bool) error {
+	files, err := a.files()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		more := true
+		if err := scanAudit(file, func(entry *AuditEntry) bool {
+			more = fn(entry)
+			return more
+		}); err != nil {
+			return err
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (a *auditLog) query(cmd *pm.Command) (interface{}, error) {
+	var args AuditQuery
+	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
+		return nil, pm.BadRequestError(err)
+	}
+
+	if args.End != 0 && args.End < args.Start {
+		return nil, pm.BadRequestError(fmt.Errorf("end must be after start"))
+	}
+
+	if args.Offset < 0 {
+		return nil, pm.BadRequestError(fmt.Errorf("offset must be positive"))
+	}
+
+	if len(args.Command) != 0 {
+		if _, err := path.Match(args.Command, ""); err != nil {
+			return nil, pm.BadRequestError(fmt.Errorf("invalid command pattern: %s", err))
+		}
+	}
+
+	if args.Limit <= 0 {
+		args.Limit = DefaultAuditQueryLimit
+	} else if args.Limit > MaxAuditQueryLimit {
+		args.Limit = MaxAuditQueryLimit
+	}
+
+	result := AuditQueryResult{
+		Entries: []*AuditEntry{},
+		Next:    args.Offset,
+	}
+
+	skip := args.Offset
+	err := a.scan(func(entry *AuditEntry) bool {
+		if !args.match(entry) {
+			return true
+		}
+
+		if skip > 0 {
+			skip--
+			return true
+		}
+
+		if len(result.Entries) == args.Limit {
+			result.More = true
+			return false
+		}
+
+		result.Entries = append(result.Entries, entry)
+		result.Next++
+		return true
+	})
+
+	if err != nil {
+		return nil, pm.InternalError(err)
+	}
+
+	return result, nil
+}
+
+//check verifies the hash chain of the whole log
+func (a *auditLog) check() (*AuditVerifyResult, error) {
+	var result AuditVerifyResult
+	var prev string
+	var seq uint64
+
+	err := a.scan(func(entry *AuditEntry) bool {
+		hash, err := entry.digest()
+		switch {
+		case err != nil:
+			result.Reason = err.Error()
+		case entry.Seq != seq+1:
+			result.Reason = fmt.Sprintf("expected entry %d", seq+1)
+		case entry.Prev != prev:
+			result.Reason = "previous hash mismatch"
+		case entry.Hash != hash:
+			result.Reason = "hash mismatch"
+		default:
+			result.Entries++
+			prev = entry.Hash
+			seq = entry.Seq
+			return true
+		}
+
+		result.Broken = entry.Seq
+		return false
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	result.Valid = len(result.Reason) == 0
+	return &result, nil
+}
+
+func (a *auditLog) verify(cmd *pm.Command) (interface{}, error) {
+	result, err := a.check()
+	if err != nil {
+		return nil, pm.InternalError(err)
+	}
+
+	return result, nil
+}
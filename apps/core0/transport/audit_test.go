@@ -0,0 +1,190 @@
+package transport
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/settings"
+)
+
+func testAuditLog(t *testing.T, maxSize int64) (*auditLog, func()) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	audit, err := newAuditLog(settings.Audit{Enabled: true, Dir: dir, MaxSize: maxSize})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return audit, func() {
+		audit.file.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func testAuditCommands(audit *auditLog) {
+	user := &Identity{Subject: "user"}
+	for _, id := range []string{"a", "b", "c"} {
+		cmd := &pm.Command{
+			ID:      id,
+			Command: "core.system",
+			Arguments: pm.MustArguments(pm.M{
+				"name":     "ls",
+				"stdin":    "input",
+				"password": "secret",
+			}),
+		}
+
+		audit.submitted(cmd, user, nil, &target{})
+		result := pm.NewJobResult(cmd)
+		result.State = pm.StateSuccess
+		audit.finished(result)
+	}
+
+	audit.submitted(&pm.Command{ID: "d", Command: "core.poweroff"}, &Identity{}, &Decision{Reason: "denied"}, &target{})
+	//not submitted, not audited
+	audit.finished(&pm.JobResult{ID: "unknown"})
+}
+
+func TestAuditQuery(t *testing.T) {
+	audit, cleanup := testAuditLog(t, 0)
+	defer cleanup()
+
+	testAuditCommands(audit)
+
+	query := func(q AuditQuery) AuditQueryResult {
+		result, err := audit.query(&pm.Command{Arguments: pm.MustArguments(q)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.(AuditQueryResult)
+	}
+
+	result := query(AuditQuery{})
+	if ok := assert.Len(t, result.Entries, 7); !ok {
+		t.Fatal()
+	}
+
+	var args map[string]string
+	if err := json.Unmarshal(*result.Entries[0].Arguments, &args); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok := assert.Equal(t, map[string]string{"name": "ls", "stdin": auditRedacted, "password": auditRedacted}, args); !ok {
+		t.Error()
+	}
+
+	result = query(AuditQuery{Event: AuditFinished, Subject: "user", Limit: 2})
+	if ok := assert.Len(t, result.Entries, 2); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.True(t, result.More); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, pm.StateSuccess, result.Entries[0].State); !ok {
+		t.Error()
+	}
+
+	result = query(AuditQuery{Offset: result.Next, Event: AuditFinished})
+	if ok := assert.Len(t, result.Entries, 1); !ok {
+		t.Error()
+	}
+
+	result = query(AuditQuery{Command: "core.power*"})
+	if ok := assert.Len(t, result.Entries, 1); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, AuditRejected, result.Entries[0].Event); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, RuleAnonymous, result.Entries[0].Subject); !ok {
+		t.Error()
+	}
+}
+
+func TestAuditChain(t *testing.T) {
+	audit, cleanup := testAuditLog(t, 1024)
+	defer cleanup()
+
+	testAuditCommands(audit)
+
+	check, err := audit.check()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok := assert.Equal(t, &AuditVerifyResult{Entries: 7, Valid: true}, check); !ok {
+		t.Error()
+	}
+
+	files, err := audit.files()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the log was rotated
+	if ok := assert.True(t, len(files) > 1); !ok {
+		t.Fatal()
+	}
+
+	//reopening the log continues the chain
+	audit.file.Close()
+	reopened, err := newAuditLog(settings.Audit{Dir: audit.dir, MaxSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testAuditCommands(reopened)
+	reopened.file.Close()
+
+	check, err = reopened.check()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok := assert.Equal(t, &AuditVerifyResult{Entries: 14, Valid: true}, check); !ok {
+		t.Error()
+	}
+
+	//tamper with an entry
+	first := files[0]
+	data, err := ioutil.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := strings.Replace(string(data), "core.system", "core.ping", 1)
+	if err := ioutil.WriteFile(first, []byte(tampered), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	check, err = reopened.check()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok := assert.False(t, check.Valid); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, uint64(1), check.Broken); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, path.Join(audit.dir, auditCurrent), files[len(files)-1]); !ok {
+		t.Error()
+	}
+}
@@ -0,0 +1,418 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/settings"
+)
+
+const (
+	//RuleAnonymous name of the rule that allows a command to the clients with no identity
+	RuleAnonymous = "anonymous"
+
+	cmdContainerCreate     = "corex.create"
+	cmdContainerCreateSync = "corex.create-sync"
+	cmdContainerDispatch   = "corex.dispatch"
+	cmdBatch               = "core.batch"
+)
+
+var (
+	//ErrUnauthorized is returned when submitting a command the client is not allowed to run
+	ErrUnauthorized = errors.New("unauthorized")
+
+	//containerCommands the commands run on the container of their container argument, any other command
+	//has no container target, even if it has a container argument
+	containerCommands = map[string]struct{}{
+		cmdContainerDispatch:       {},
+		"corex.terminate":          {},
+		"corex.stop":               {},
+		"corex.start":              {},
+		"corex.restart":            {},
+		"corex.pause":              {},
+		"corex.resume":             {},
+		"corex.zerotier.info":      {},
+		"corex.zerotier.list":      {},
+		"corex.nic-add":            {},
+		"corex.nic-remove":         {},
+		"corex.backup":             {},
+		"corex.portforward-add":    {},
+		"corex.portforward-remove": {},
+		"corex.flist-layer":        {},
+		"corex.flist.create":       {},
+	}
+)
+
+//Identity of the client that submitted a command. It's set by the redis-proxy from the client JWT
+//(azp, scopes and the organizations of the user:memberof:<org> scopes), and by the http api from
+//the client certificate (common name and organizations)
+type Identity struct {
+	Subject       string   `json:"subject"`
+	Organizations []string `json:"organizations,omitempty"`
+	Scopes        []string `json:"scopes,omitempty"`
+}
+
+//Anonymous is true if the identity doesn't carry any information
+func (i *Identity) Anonymous() bool {
+	return len(i.Subject) == 0 && len(i.Organizations) == 0 && len(i.Scopes) == 0
+}
+
+func (i *Identity) String() string {
+	if i.Anonymous() {
+		return RuleAnonymous
+	}
+
+	return i.Subject
+}
+
+//ContainerResolver returns the tags of a container, and false if the container doesn't exist
+type ContainerResolver func(id uint16) (pm.Tags, bool)
+
+//Decision of the authorizer on a command
+type Decision struct {
+	Time      int64    `json:"time"`
+	ID        string   `json:"id"`
+	Command   string   `json:"command"`
+	Dispatch  string   `json:"dispatch,omitempty"`
+	Container uint16   `json:"container,omitempty"`
+	Subject   string   `json:"subject"`
+	Identity  Identity `json:"identity"`
+	Allowed   bool     `json:"allowed"`
+	Rule      string   `json:"rule,omitempty"`
+	Reason    string   `json:"reason,omitempty"`
+}
+
+//Auditor receives all the decisions of the authorizer
+type Auditor func(decision *Decision)
+
+//target is what a command is run on
+type target struct {
+	//container id, 0 if the command doesn't run on a container
+	container uint16
+	tags      pm.Tags
+	//dispatch the command dispatched to the container (corex.dispatch)
+	dispatch string
+}
+
+type rule struct {
+	name string
+	settings.AuthorizationRule
+}
+
+//member checks if the identity is granted the rule
+func (r *rule) member(identity *Identity) bool {
+	for _, subject := range r.Subjects {
+		if strings.EqualFold(subject, identity.Subject) {
+			return true
+		}
+	}
+
+	for _, org := range r.Organizations {
+		for _, o := range identity.Organizations {
+			if strings.EqualFold(org, o) {
+				return true
+			}
+		}
+	}
+
+	for _, scope := range r.Scopes {
+		for _, s := range identity.Scopes {
+			if scope == s {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//restricted is true if the rule only allows commands on some containers
+func (r *rule) restricted() bool {
+	return len(r.Containers) != 0 || len(r.Tags) != 0
+}
+
+func (r *rule) allows(command string, t *target) bool {
+	if !match(r.Commands, command) {
+		return false
+	}
+
+	if !r.restricted() {
+		return true
+	}
+
+	if t.container == 0 && t.tags == nil {
+		//the command doesn't run on a container
+		return false
+	}
+
+	for _, id := range r.Containers {
+		if id == t.container {
+			return true
+		}
+	}
+
+	for _, tag := range r.Tags {
+		for _, other := range t.tags {
+			if tag == other {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//match checks if the command matches one of the (path.Match) patterns
+func match(patterns []string, command string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, command); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+//authorizer decides which commands a client can run
+type authorizer struct {
+	enabled   bool
+	anonymous []string
+	rules     []rule
+
+	resolve ContainerResolver
+	audit   Auditor
+	m       sync.RWMutex
+}
+
+func newAuthorizer(config settings.Authorization) *authorizer {
+	a := &authorizer{
+		enabled:   config.Enabled,
+		anonymous: config.Anonymous,
+		audit:     logDecision,
+	}
+
+	for name, r := range config.Rule {
+		a.rules = append(a.rules, rule{name: name, AuthorizationRule: r})
+	}
+
+	//rules are evaluated in the same order all the time, so the audited rule is predictable
+	sort.Slice(a.rules, func(i, j int) bool {
+		return a.rules[i].name < a.rules[j].name
+	})
+
+	return a
+}
+
+func logDecision(decision *Decision) {
+	if decision.Allowed {
+		log.Infof("authorization: %s is allowed to run %s (rule: %s)", decision.Subject, decision.Command, decision.Rule)
+	} else {
+		log.Warningf("authorization: %s is not allowed to run %s: %s", decision.Subject, decision.Command, decision.Reason)
+	}
+}
+
+//target finds what the command is run on
+func (a *authorizer) target(cmd *pm.Command) (*target, error) {
+	var t target
+	if cmd.Arguments == nil {
+		return &t, nil
+	}
+
+	var args map[string]json.RawMessage
+	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
+		//arguments of the command are not an object, so there is no target
+		return &t, nil
+	}
+
+	switch cmd.Command {
+	case cmdContainerCreate, cmdContainerCreateSync:
+		//the tags of the container being created
+		t.tags = pm.Tags{}
+		if raw, ok := args["tags"]; ok {
+			if err := json.Unmarshal(raw, &t.tags); err != nil {
+				return nil, fmt.Errorf("invalid container tags: %s", err)
+			}
+		}
+		return &t, nil
+	}
+
+	if _, ok := containerCommands[cmd.Command]; !ok {
+		return &t, nil
+	}
+
+	raw, ok := args["container"]
+	if !ok {
+		return &t, nil
+	}
+
+	if err := json.Unmarshal(raw, &t.container); err != nil {
+		return nil, fmt.Errorf("invalid container id: %s", err)
+	}
+
+	a.m.RLock()
+	resolve := a.resolve
+	a.m.RUnlock()
+
+	if resolve != nil {
+		t.tags, _ = resolve(t.container)
+	}
+
+	if cmd.Command == cmdContainerDispatch {
+		var dispatch pm.Command
+		if raw, ok := args["command"]; ok {
+			if err := json.Unmarshal(raw, &dispatch); err != nil {
+				return nil, fmt.Errorf("invalid dispatched command: %s", err)
+			}
+		}
+
+		if len(dispatch.Command) == 0 {
+			return nil, fmt.Errorf("dispatched command is not set")
+		}
+
+		t.dispatch = dispatch.Command
+	}
+
+	return &t, nil
+}
+
+//check finds the rule that allows the identity to run the command. A command dispatched to a container
+//must be allowed (on the same container) by the rule that allows the corex.dispatch, and all the
+//commands of a batch must be allowed
+func (a *authorizer) check(cmd *pm.Command, identity *Identity) (string, *target, error) {
+	t, err := a.target(cmd)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var allowed string
+	if identity.Anonymous() {
+		if match(a.anonymous, cmd.Command) && (len(t.dispatch) == 0 || match(a.anonymous, t.dispatch)) {
+			allowed = RuleAnonymous
+		}
+	} else {
+		for i := range a.rules {
+			r := &a.rules[i]
+			if !r.member(identity) {
+				continue
+			}
+
+			if r.allows(cmd.Command, t) && (len(t.dispatch) == 0 || r.allows(t.dispatch, t)) {
+				allowed = r.name
+				break
+			}
+		}
+	}
+
+	if len(allowed) == 0 {
+		return "", t, fmt.Errorf("no rule allows '%s'", cmd.Command)
+	}
+
+	if cmd.Command != cmdBatch || cmd.Arguments == nil {
+		return allowed, t, nil
+	}
+
+	var batch struct {
+		Commands []pm.BatchCommand `json:"commands"`
+	}
+
+	if err := json.Unmarshal(*cmd.Arguments, &batch); err != nil {
+		return "", t, fmt.Errorf("invalid batch: %s", err)
+	}
+
+	for i := range batch.Commands {
+		if _, _, err := a.check(&batch.Commands[i].Command, identity); err != nil {
+			return "", t, fmt.Errorf("batch command '%s': %s", batch.Commands[i].ID, err)
+		}
+	}
+
+	return allowed, t, nil
+}
+
+//decide makes the authorization decision
+func (a *authorizer) decide(cmd *pm.Command, identity *Identity) *Decision {
+	decision := &Decision{
+		Time:     time.Now().Unix(),
+		ID:       cmd.ID,
+		Command:  cmd.Command,
+		Subject:  identity.String(),
+		Identity: *identity,
+	}
+
+	rule, t, err := a.check(cmd, identity)
+	if t != nil {
+		decision.Container = t.container
+		decision.Dispatch = t.dispatch
+	}
+
+	if err != nil {
+		decision.Reason = err.Error()
+		return decision
+	}
+
+	decision.Allowed = true
+	decision.Rule = rule
+	return decision
+}
+
+//authorize checks if the identity is allowed to run the command, and audits the decision. A nil
+//identity is a trusted (local) client that is always allowed, the decision is nil if the command
+//was not checked
+func (a *authorizer) authorize(cmd *pm.Command, identity *Identity) (*Decision, error) {
+	if !a.enabled || identity == nil {
+		return nil, nil
+	}
+
+	decision := a.decide(cmd, identity)
+
+	a.m.RLock()
+	audit := a.audit
+	a.m.RUnlock()
+
+	audit(decision)
+
+	if !decision.Allowed {
+		return decision, ErrUnauthorized
+	}
+
+	return decision, nil
+}
+
+//SetContainerResolver sets the resolver of the containers tags, it's needed to authorize the commands
+//run on containers by their tags
+func (sink *Sink) SetContainerResolver(resolver ContainerResolver) {
+	sink.authz.m.Lock()
+	defer sink.authz.m.Unlock()
+	sink.authz.resolve = resolver
+}
+
+//SetAuditor sets the receiver of the authorization decisions (they are logged by default)
+func (sink *Sink) SetAuditor(auditor Auditor) {
+	sink.authz.m.Lock()
+	defer sink.authz.m.Unlock()
+	sink.authz.audit = auditor
+}
+
+//Authorize checks if the identity is allowed to run the command, a nil identity is trusted
+func (sink *Sink) Authorize(cmd *pm.Command, identity *Identity) error {
+	_, err := sink.authz.authorize(cmd, identity)
+	return err
+}
+
+//unauthorized forwards the result of a rejected command
+func (sink *Sink) unauthorized(cmd *pm.Command) {
+	sink.Watch(cmd)
+	result := pm.NewJobResult(cmd)
+	result.State = pm.StateUnauthorized
+	result.Code = http.StatusForbidden
+	data, _ := json.Marshal(fmt.Sprintf("not allowed to run '%s'", cmd.Command))
+	result.Data = string(data)
+	sink.Forward(result)
+}
@@ -0,0 +1,175 @@
+package transport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/settings"
+)
+
+func testAuthorizer() *authorizer {
+	a := newAuthorizer(settings.Authorization{
+		Enabled:   true,
+		Anonymous: []string{"core.ping", "info.*"},
+		Rule: map[string]settings.AuthorizationRule{
+			"admins": {
+				Organizations: []string{"org.admins"},
+				Commands:      []string{"*"},
+			},
+			"team": {
+				Scopes:   []string{"user:memberof:org.team"},
+				Commands: []string{"corex.create", "corex.dispatch", "core.system", "job.*"},
+				Tags:     []string{"team"},
+			},
+			"ops": {
+				Subjects:   []string{"ops"},
+				Commands:   []string{"*"},
+				Containers: []uint16{5},
+			},
+		},
+	})
+
+	a.resolve = func(id uint16) (pm.Tags, bool) {
+		switch id {
+		case 1:
+			return pm.Tags{"team"}, true
+		case 2:
+			return pm.Tags{"other"}, true
+		}
+		return nil, false
+	}
+
+	return a
+}
+
+func TestAuthorize(t *testing.T) {
+	a := testAuthorizer()
+
+	var decisions []*Decision
+	a.audit = func(decision *Decision) {
+		decisions = append(decisions, decision)
+	}
+
+	admin := &Identity{Subject: "admin", Organizations: []string{"org.admins"}}
+	team := &Identity{Subject: "dev", Scopes: []string{"user:memberof:org.team"}}
+	ops := &Identity{Subject: "ops"}
+	anonymous := &Identity{}
+
+	cases := []struct {
+		identity *Identity
+		command  string
+		args     interface{}
+		allowed  bool
+	}{
+		{nil, "core.poweroff", nil, true},
+		{admin, "core.poweroff", nil, true},
+		{anonymous, "core.ping", nil, true},
+		{anonymous, "info.cpu", nil, true},
+		{anonymous, "core.system", nil, false},
+		{team, "core.system", nil, false},
+		{team, "corex.dispatch", pm.M{"container": 1, "command": pm.M{"command": "core.system"}}, true},
+		{team, "corex.dispatch", pm.M{"container": 2, "command": pm.M{"command": "core.system"}}, false},
+		{team, "corex.dispatch", pm.M{"container": 1, "command": pm.M{"command": "core.poweroff"}}, false},
+		{team, "corex.dispatch", pm.M{"container": "1", "command": pm.M{"command": "core.system"}}, false},
+		{team, "corex.create", pm.M{"root": "flist", "tags": []string{"team"}}, true},
+		{team, "corex.create", pm.M{"root": "flist"}, false},
+		{team, "kvm.destroy", pm.M{"uuid": "vm"}, false},
+		{admin, "core.batch", pm.M{"commands": []pm.M{{"id": "a", "command": "core.poweroff"}}}, true},
+		{team, "core.batch", pm.M{"commands": []pm.M{{"id": "a", "command": "core.system"}}}, false},
+		{ops, "corex.terminate", pm.M{"container": 5}, true},
+		{ops, "corex.terminate", pm.M{"container": 1}, false},
+		//a container argument doesn't make a host command run on the container
+		{ops, "core.system", pm.M{"name": "sh", "args": []string{"-c", "id"}, "container": 5}, false},
+		{ops, "kvm.destroy", pm.M{"uuid": "vm", "container": 5}, false},
+	}
+
+	for i, c := range cases {
+		cmd := &pm.Command{ID: "id", Command: c.command}
+		if c.args != nil {
+			cmd.Arguments = pm.MustArguments(c.args)
+		}
+
+		_, err := a.authorize(cmd, c.identity)
+		if c.allowed {
+			if ok := assert.NoError(t, err, "case %d", i); !ok {
+				t.Error()
+			}
+		} else {
+			if ok := assert.Equal(t, ErrUnauthorized, err, "case %d", i); !ok {
+				t.Error()
+			}
+		}
+	}
+
+	//all the decisions, but the trusted client's, are audited
+	if ok := assert.Len(t, decisions, len(cases)-1); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, "admins", decisions[0].Rule); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, uint16(2), decisions[6].Container); !ok {
+		t.Error()
+	}
+
+	if ok := assert.NotEmpty(t, decisions[6].Reason); !ok {
+		t.Error()
+	}
+}
+
+func TestAuthorizeDisabled(t *testing.T) {
+	a := newAuthorizer(settings.Authorization{})
+	a.audit = func(*Decision) {
+		t.Error("decision audited")
+	}
+
+	if _, err := a.authorize(&pm.Command{Command: "core.poweroff"}, &Identity{}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInputAllowed(t *testing.T) {
+	sink := &Sink{authz: testAuthorizer()}
+
+	var audited int
+	sink.authz.audit = func(*Decision) {
+		audited++
+	}
+
+	admin := &Identity{Subject: "admin", Organizations: []string{"org.admins"}}
+	team := &Identity{Subject: "dev", Scopes: []string{"user:memberof:org.team"}}
+
+	decisions := make(map[string]bool)
+	if ok := assert.True(t, sink.inputAllowed("job", 0, admin, decisions)); !ok {
+		t.Error()
+	}
+
+	//the decision is kept, and only audited once
+	if ok := assert.True(t, sink.inputAllowed("job", 0, admin, decisions)); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, 1, audited); !ok {
+		t.Error()
+	}
+
+	if ok := assert.False(t, sink.inputAllowed("job", 0, nil, decisions)); !ok {
+		t.Error()
+	}
+
+	//the team rule only allows the jobs of the team containers
+	if ok := assert.False(t, sink.inputAllowed("job", 0, team, make(map[string]bool))); !ok {
+		t.Error()
+	}
+
+	if ok := assert.True(t, sink.inputAllowed("job", 1, team, make(map[string]bool))); !ok {
+		t.Error()
+	}
+
+	if ok := assert.False(t, sink.inputAllowed("job", 2, team, make(map[string]bool))); !ok {
+		t.Error()
+	}
+}
@@ -36,8 +36,8 @@ func (cl *channel) String() string {
 	return "redis"
 }
 
-//GetNext gets the next available command
-func (cl *channel) GetNext(queue string, command *pm.Command) error {
+//GetNext gets the next available command, and decodes it into command
+func (cl *channel) GetNext(queue string, command interface{}) error {
 	conn := cl.pool.Get()
 	defer conn.Close()
 
@@ -90,6 +90,24 @@ func (cl *channel) Push(queue string, payload interface{}) error {
 	return nil
 }
 
+//Pop pops the next value from the queue, it returns redis.ErrNil if the queue is still empty
+//after timeout seconds
+func (cl *channel) Pop(queue string, timeout int) ([]byte, error) {
+	conn := cl.pool.Get()
+	defer conn.Close()
+
+	payload, err := redis.ByteSlices(conn.Do("BLPOP", queue, timeout))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload) < 2 {
+		return nil, redis.ErrNil
+	}
+
+	return payload[1], nil
+}
+
 func (cl *channel) cycle(queue string, timeout int) ([]byte, error) {
 	conn := cl.pool.Get()
 	defer conn.Close()
@@ -0,0 +1,242 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/settings"
+)
+
+const (
+	//ResultJobChannel pub/sub channel the results of a job are published on
+	ResultJobChannel = "results:job:%s"
+	//ResultTagChannel pub/sub channel the results of the jobs with a tag are published on
+	ResultTagChannel = "results:tag:%s"
+
+	//SignatureHeader webhook request header with the payload HMAC-SHA256 signature (sha256=<hex>)
+	SignatureHeader = "X-Core0-Signature"
+	//JobHeader webhook request header with the job id
+	JobHeader = "X-Core0-Job"
+
+	//DefaultWebhookTimeout time to wait for a webhook response
+	DefaultWebhookTimeout = 10 * time.Second
+	//DefaultWebhookRetries number of delivery retries of a webhook
+	DefaultWebhookRetries = 5
+
+	webhookMaxRetryInterval = 5 * time.Minute
+)
+
+//notifier publishes the jobs results on the results channels, and posts them to the jobs webhooks
+type notifier struct {
+	pool    *redis.Pool
+	secret  []byte
+	retries int
+	client  *http.Client
+	policy  *pm.WebhookPolicy
+
+	hooks map[string]*pm.Webhook
+	m     sync.Mutex
+}
+
+func newNotifier(pool *redis.Pool, config settings.Webhook) *notifier {
+	n := &notifier{
+		pool:    pool,
+		secret:  []byte(config.Secret),
+		retries: config.Retries,
+		policy:  pm.GetWebhookPolicy(),
+		client: &http.Client{
+			Timeout: time.Duration(config.Timeout) * time.Second,
+			//a redirect is not followed, since it would turn the POST into a GET
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		hooks: make(map[string]*pm.Webhook),
+	}
+
+	//the webhook host is checked again once resolved, so a name can't point the webhook to a
+	//denied address. There is no proxy, it would resolve the host on its own
+	n.client.Transport = &http.Transport{
+		DialContext:         n.dial,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+
+	if n.client.Timeout <= 0 {
+		n.client.Timeout = DefaultWebhookTimeout
+	}
+
+	if n.retries <= 0 {
+		n.retries = DefaultWebhookRetries
+	}
+
+	return n
+}
+
+//watch registers the command webhook, it's used when the command result is notified
+func (n *notifier) watch(cmd *pm.Command) {
+	if cmd.Webhook == nil {
+		return
+	}
+
+	n.m.Lock()
+	defer n.m.Unlock()
+	n.hooks[cmd.ID] = cmd.Webhook
+}
+
+func (n *notifier) notify(result *pm.JobResult) {
+	n.m.Lock()
+	hook, ok := n.hooks[result.ID]
+	delete(n.hooks, result.ID)
+	n.m.Unlock()
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		log.Errorf("failed to serialize result of job %s: %s", result.ID, err)
+		return
+	}
+
+	if err := n.publish(result, data); err != nil {
+		log.Errorf("failed to publish result of job %s: %s", result.ID, err)
+	}
+
+	if ok {
+		go n.deliver(hook, result.ID, data)
+	}
+}
+
+//publish publishes the result on the job channel, and the channels of the job tags
+func (n *notifier) publish(result *pm.JobResult, data []byte) error {
+	conn := n.pool.Get()
+	defer conn.Close()
+
+	conn.Send("PUBLISH", fmt.Sprintf(ResultJobChannel, result.ID), data)
+	for _, tag := range result.Tags {
+		conn.Send("PUBLISH", fmt.Sprintf(ResultTagChannel, tag), data)
+	}
+
+	//flush and wait for all the replies
+	_, err := conn.Do("")
+	return err
+}
+
+//deniedError the webhook destination is denied by the policy, the delivery is not retried
+type deniedError struct {
+	error
+}
+
+//dial connects to the first resolved address of the host, all the addresses must be allowed by the
+//webhook policy
+func (n *notifier) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, addr := range addrs {
+		if err := n.policy.Check(host, addr.IP); err != nil {
+			return nil, deniedError{err}
+		}
+	}
+
+	var dialer net.Dialer
+	for _, addr := range addrs {
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(addr.IP.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+
+	return nil, err
+}
+
+func (n *notifier) sign(data []byte) string {
+	mac := hmac.New(sha256.New, n.secret)
+	mac.Write(data)
+	return fmt.Sprintf("sha256=%s", hex.EncodeToString(mac.Sum(nil)))
+}
+
+//post posts the payload to the url, it returns true if the delivery can be retried
+func (n *notifier) post(address, id string, data []byte) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, address, bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(JobHeader, id)
+	if len(n.secret) != 0 {
+		request.Header.Set(SignatureHeader, n.sign(data))
+	}
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		if err, ok := err.(*url.Error); ok {
+			if _, denied := err.Err.(deniedError); denied {
+				return false, err
+			}
+		}
+
+		return true, err
+	}
+
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(response.Body, 4096))
+
+	switch code := response.StatusCode; {
+	case code >= 200 && code < 300:
+		return false, nil
+	case code >= 500, code == http.StatusRequestTimeout, code == http.StatusTooManyRequests:
+		return true, fmt.Errorf("webhook responded with '%s'", response.Status)
+	default:
+		return false, fmt.Errorf("webhook responded with '%s'", response.Status)
+	}
+}
+
+//deliver posts the result to the webhook, retrying (with exponential backoff) on failures
+func (n *notifier) deliver(hook *pm.Webhook, id string, data []byte) {
+	retries := hook.Retries
+	if retries == 0 {
+		retries = n.retries
+	}
+
+	wait := time.Second
+	for attempt := 0; ; attempt++ {
+		retry, err := n.post(hook.URL, id, data)
+		if err == nil {
+			return
+		}
+
+		if !retry || attempt >= retries {
+			log.Errorf("failed to deliver result of job %s to webhook: %s", id, err)
+			return
+		}
+
+		log.Warningf("failed to deliver result of job %s to webhook (retry in %s): %s", id, wait, err)
+		<-time.After(wait)
+
+		wait *= 2
+		if wait > webhookMaxRetryInterval {
+			wait = webhookMaxRetryInterval
+		}
+	}
+}
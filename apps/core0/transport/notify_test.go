@@ -0,0 +1,105 @@
+package transport
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/settings"
+)
+
+//testWebhookPolicy allows the test servers on the loopback interface
+var testWebhookPolicy = pm.MustWebhookPolicy(nil, []string{"169.254.0.0/16"})
+
+func TestNotifierDeliver(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		if ok := assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), r.Header.Get(SignatureHeader)); !ok {
+			t.Error()
+		}
+
+		if ok := assert.Equal(t, "job-id", r.Header.Get(JobHeader)); !ok {
+			t.Error()
+		}
+
+		if ok := assert.Equal(t, `{"id":"job-id"}`, string(body)); !ok {
+			t.Error()
+		}
+
+		//fail the first attempt
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	n := newNotifier(nil, settings.Webhook{Secret: "secret"})
+	n.policy = testWebhookPolicy
+	n.deliver(&pm.Webhook{URL: server.URL, Retries: 2}, "job-id", []byte(`{"id":"job-id"}`))
+
+	if ok := assert.Equal(t, int32(2), atomic.LoadInt32(&calls)); !ok {
+		t.Error()
+	}
+}
+
+func TestNotifierDeliverNoRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok := assert.Empty(t, r.Header.Get(SignatureHeader)); !ok {
+			t.Error()
+		}
+
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	n := newNotifier(nil, settings.Webhook{})
+	n.policy = testWebhookPolicy
+	n.deliver(&pm.Webhook{URL: server.URL}, "job-id", []byte(`{}`))
+
+	if ok := assert.Equal(t, int32(1), atomic.LoadInt32(&calls)); !ok {
+		t.Error()
+	}
+}
+
+func TestNotifierDeliverDenied(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+
+	//the host name is allowed, but it resolves to a denied address
+	n := newNotifier(nil, settings.Webhook{})
+	n.policy = pm.MustWebhookPolicy(nil, []string{"127.0.0.0/8", "::1"})
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	if ok := assert.NoError(t, n.policy.Check("localhost", nil)); !ok {
+		t.Fatal()
+	}
+
+	_, err := n.post(url, "job-id", []byte(`{}`))
+	if ok := assert.Error(t, err); !ok {
+		t.Error()
+	}
+
+	//the default policy denies the loopback addresses
+	n = newNotifier(nil, settings.Webhook{})
+	n.deliver(&pm.Webhook{URL: server.URL}, "job-id", []byte(`{}`))
+
+	if ok := assert.Equal(t, int32(0), atomic.LoadInt32(&calls)); !ok {
+		t.Error()
+	}
+}
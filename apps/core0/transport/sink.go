@@ -1,21 +1,41 @@
 package transport
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/garyburd/redigo/redis"
 	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/settings"
 )
 
 const (
 	SinkQueue = "core:default"
 	DBIndex   = 0
+
+	//StdinQueue is the list where the input of an interactive job is pushed
+	StdinQueue = "stdin:%s"
+)
+
+var (
+	//ErrNoID is returned when submitting a command with no id
+	ErrNoID = errors.New("command has no id")
 )
 
 type Sink struct {
-	ch   *channel
-	pool *redis.Pool
+	ch       *channel
+	pool     *redis.Pool
+	notifier *notifier
+	authz    *authorizer
+	audit    *auditLog
+}
+
+//request is a command pushed to the sink queue, with the identity of the client set by the redis-proxy
+type request struct {
+	pm.Command
+	Identity *Identity `json:"identity,omitempty"`
 }
 
 type SinkConfig struct {
@@ -29,8 +49,21 @@ func (c *SinkConfig) Local() string {
 func NewSink(c SinkConfig) (*Sink, error) {
 	pool := newPool()
 	sink := &Sink{
-		pool: newPool(),
-		ch:   newChannel(pool),
+		pool:     newPool(),
+		ch:       newChannel(pool),
+		notifier: newNotifier(pool, settings.Settings.Webhook),
+		authz:    newAuthorizer(settings.Settings.Authorization),
+	}
+
+	if settings.Settings.Audit.Enabled {
+		audit, err := newAuditLog(settings.Settings.Audit)
+		if err != nil {
+			log.Errorf("failed to open audit log: %s", err)
+		} else {
+			sink.audit = audit
+			pm.RegisterBuiltIn("audit.query", audit.query)
+			pm.RegisterBuiltIn("audit.verify", audit.verify)
+		}
 	}
 
 	pm.AddHandle(sink)
@@ -102,6 +135,7 @@ func (sink *Sink) LExpire(key string, duration int64) (int64, error) {
 
 //Result handler implementation
 func (sink *Sink) Result(cmd *pm.Command, result *pm.JobResult) {
+	sink.Watch(cmd)
 	if err := sink.Forward(result); err != nil {
 		log.Debugf("failed to forward result: %s", cmd.ID)
 	}
@@ -110,7 +144,7 @@ func (sink *Sink) Result(cmd *pm.Command, result *pm.JobResult) {
 func (sink *Sink) process() {
 
 	for {
-		var command pm.Command
+		var command request
 		err := sink.ch.GetNext(SinkQueue, &command)
 		if err == redis.ErrNil {
 			continue
@@ -120,34 +154,82 @@ func (sink *Sink) process() {
 			continue
 		}
 
-		if command.ID == "" {
-			log.Warningf("receiving a command with no ID, dropping")
-			continue
+		//a command with no identity was not pushed through the redis-proxy, or by an unauthenticated client
+		identity := command.Identity
+		if identity == nil {
+			identity = &Identity{}
 		}
-		if sink.ch.Flagged(command.ID) {
+
+		switch err := sink.Submit(&command.Command, identity); err {
+		case nil, pm.UnknownCommandErr, ErrUnauthorized:
+		case ErrNoID:
+			log.Warningf("receiving a command with no ID, dropping")
+		case pm.DuplicateIDErr:
 			log.Errorf("received a command with a duplicate ID(%v), dropping", command.ID)
-			continue
+		default:
+			log.Errorf("Unknown error while processing command (%s): %s", command, err)
 		}
+	}
+}
+
+//Submit starts the command, it's the common entry point of all the transports. The command result
+//is forwarded once the job exits (also if the command is unknown, or the identity is not allowed to
+//run it). A nil identity is a trusted local client
+func (sink *Sink) Submit(command *pm.Command, identity *Identity) error {
+	if command.ID == "" {
+		return ErrNoID
+	}
 
-		sink.ch.Flag(command.ID)
-		log.Debugf("Starting command %s", &command)
+	if sink.ch.Flagged(command.ID) {
+		return pm.DuplicateIDErr
+	}
 
-		_, err = pm.Run(&command)
+	sink.ch.Flag(command.ID)
 
-		if err == pm.UnknownCommandErr {
-			result := pm.NewJobResult(&command)
-			result.State = pm.StateUnknownCmd
-			sink.Forward(result)
-		} else if err != nil {
-			log.Errorf("Unknown error while processing command (%s): %s", command, err)
+	decision, err := sink.authz.authorize(command, identity)
+	if sink.audit != nil {
+		var t *target
+		if decision == nil {
+			//the target is only known if the command was authorized
+			t, _ = sink.authz.target(command)
+		} else {
+			t = &target{container: decision.Container, dispatch: decision.Dispatch}
 		}
+
+		sink.audit.submitted(command, identity, decision, t)
 	}
+
+	if err != nil {
+		sink.unauthorized(command)
+		return err
+	}
+
+	log.Debugf("Starting command %s", command)
+
+	_, err = pm.Run(command)
+	if err == pm.UnknownCommandErr {
+		sink.Watch(command)
+		result := pm.NewJobResult(command)
+		result.State = pm.StateUnknownCmd
+		sink.Forward(result)
+	}
+
+	return err
 }
 
-//Forward forwards job result
+//Forward forwards job result, the result is also published on the results channels and posted
+//to the job webhook (if registered with Watch)
 func (sink *Sink) Forward(result *pm.JobResult) error {
 	sink.ch.UnFlag(result.ID)
-	return sink.ch.Respond(result)
+	err := sink.ch.Respond(result)
+	sink.notifier.notify(result)
+	sink.audit.finished(result)
+	return err
+}
+
+//Watch registers the command webhook, so the command result is posted to it once forwarded
+func (sink *Sink) Watch(cmd *pm.Command) {
+	sink.notifier.watch(cmd)
 }
 
 //Flag marks a job ID as running
@@ -155,6 +237,96 @@ func (sink *Sink) Flag(id string) error {
 	return sink.ch.Flag(id)
 }
 
+//input is an input pushed to a job stdin list, with the identity of the client set by the redis-proxy
+type input struct {
+	pm.Input
+	Identity *Identity `json:"identity,omitempty"`
+}
+
+//inputAllowed checks if the identity that pushed an input is allowed to run job.stdin on the job (dispatched
+//to the container if not 0). The decisions are kept per identity while the job is attached, so they are
+//audited once
+func (sink *Sink) inputAllowed(id string, container uint16, identity *Identity, decisions map[string]bool) bool {
+	if identity == nil {
+		//an input with no identity was not pushed through the redis-proxy, or by an unauthenticated client
+		identity = &Identity{}
+	}
+
+	key, _ := json.Marshal(identity)
+	if allowed, ok := decisions[string(key)]; ok {
+		return allowed
+	}
+
+	cmd := &pm.Command{
+		ID:        id,
+		Command:   "job.stdin",
+		Arguments: pm.MustArguments(pm.M{"id": id}),
+	}
+
+	if container != 0 {
+		cmd = &pm.Command{
+			ID:        id,
+			Command:   cmdContainerDispatch,
+			Arguments: pm.MustArguments(pm.M{"container": container, "command": cmd}),
+		}
+	}
+
+	_, err := sink.authz.authorize(cmd, identity)
+	decisions[string(key)] = err == nil
+	return err == nil
+}
+
+//Pump feeds the input pushed to the job stdin list to feed until done is closed. The input of a job dispatched
+//to a container (not 0) must be allowed as a job.stdin dispatched to the container
+func (sink *Sink) Pump(id string, container uint16, done <-chan struct{}, feed func(*pm.Input) error) {
+	queue := fmt.Sprintf(StdinQueue, id)
+	defer sink.Del(queue)
+
+	decisions := make(map[string]bool)
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		payload, err := sink.ch.Pop(queue, 1)
+		if err == redis.ErrNil {
+			continue
+		} else if err != nil {
+			log.Errorf("failed to get input of job %s: %s", id, err)
+			select {
+			case <-done:
+				return
+			case <-time.After(200 * time.Millisecond):
+			}
+			continue
+		}
+
+		var entry input
+		if err := json.Unmarshal(payload, &entry); err != nil {
+			log.Errorf("invalid input to job %s: %s", id, err)
+			continue
+		}
+
+		if !sink.inputAllowed(id, container, entry.Identity, decisions) {
+			continue
+		}
+
+		if err := feed(&entry.Input); err != nil {
+			log.Errorf("failed to feed input to job %s: %s", id, err)
+		}
+	}
+}
+
+//Attach implements pm.InputHandler, feeds the job with the input pushed to its stdin list
+func (sink *Sink) Attach(id string, done <-chan struct{}) {
+	sink.Pump(id, 0, done, func(input *pm.Input) error {
+		return pm.Feed(id, input)
+	})
+}
+
 //Start sink
 func (sink *Sink) Start() {
 	go sink.process()
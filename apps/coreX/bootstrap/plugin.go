@@ -47,6 +47,7 @@ func (b *Bootstrap) pluginFactory(plugin *Plugin, fn string) pm.ProcessFactory {
 			MaxTime:         srcCmd.MaxTime,
 			MaxRestart:      srcCmd.MaxRestart,
 			RecurringPeriod: srcCmd.RecurringPeriod,
+			Schedule:        srcCmd.Schedule,
 			LogLevels:       srcCmd.LogLevels,
 			Tags:            srcCmd.Tags,
 		}
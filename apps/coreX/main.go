@@ -96,11 +96,29 @@ func main() {
 	handleSignal(bs)
 
 	for {
-		var cmd pm.Command
-		if err := dec.Decode(&cmd); err != nil {
+		var msg struct {
+			pm.Command
+			//Stdin is set to the job id if the message is the input of an interactive job
+			Stdin string    `json:"stdin"`
+			Input *pm.Input `json:"input"`
+		}
+
+		if err := dec.Decode(&msg); err != nil {
 			log.Errorf("failed to decode command message: %s", err)
 		}
 
+		if len(msg.Stdin) != 0 {
+			if msg.Input == nil {
+				continue
+			}
+
+			if err := pm.Feed(msg.Stdin, msg.Input); err != nil {
+				log.Errorf("failed to feed input to job %s: %s", msg.Stdin, err)
+			}
+			continue
+		}
+
+		cmd := msg.Command
 		_, err := pm.Run(&cmd)
 
 		if err == pm.UnknownCommandErr {
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+
+	"github.com/codegangsta/cli"
+	"github.com/garyburd/redigo/redis"
+	"github.com/threefoldtech/0-core/base/pm"
+	"github.com/threefoldtech/0-core/base/pm/stream"
+)
+
+type record struct {
+	Message *stream.Message `json:"message"`
+}
+
+type winsize struct {
+	Row    uint16
+	Col    uint16
+	Xpixel uint16
+	Ypixel uint16
+}
+
+func ioctl(fd uintptr, request uintptr, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg); errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+//makeRaw puts the terminal in raw mode, and returns a function that restores its original state
+func makeRaw(fd uintptr) (func(), error) {
+	var state syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, uintptr(unsafe.Pointer(&state))); err != nil {
+		return nil, err
+	}
+
+	raw := state
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	if err := ioctl(fd, syscall.TCSETS, uintptr(unsafe.Pointer(&raw))); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		ioctl(fd, syscall.TCSETS, uintptr(unsafe.Pointer(&state)))
+	}, nil
+}
+
+func terminalSize(fd uintptr) (uint16, uint16, error) {
+	var ws winsize
+	if err := ioctl(fd, syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws))); err != nil {
+		return 0, 0, err
+	}
+
+	return ws.Row, ws.Col, nil
+}
+
+//attacher feeds the local stdin to the job stdin list, and prints the job stream
+type attacher struct {
+	pool *redis.Pool
+	id   string
+}
+
+func (a *attacher) push(input *pm.Input) error {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	conn := a.pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("RPUSH", fmt.Sprintf("stdin:%s", a.id), data)
+	return err
+}
+
+func (a *attacher) resize() {
+	rows, cols, err := terminalSize(os.Stdin.Fd())
+	if err != nil {
+		return
+	}
+
+	if err := a.push(&pm.Input{Rows: rows, Cols: cols}); err != nil {
+		log.Errorf("failed to resize terminal: %s", err)
+	}
+}
+
+func (a *attacher) input() {
+	buffer := make([]byte, 4096)
+	for {
+		n, err := os.Stdin.Read(buffer)
+		if n > 0 {
+			if err := a.push(&pm.Input{Data: buffer[:n]}); err != nil {
+				log.Errorf("failed to send input: %s", err)
+				return
+			}
+		}
+
+		if err == io.EOF {
+			a.push(&pm.Input{EOF: true})
+			return
+		} else if err != nil {
+			return
+		}
+	}
+}
+
+//output prints the job output until it exits, and returns the job exit code
+func (a *attacher) output() (int, error) {
+	conn := a.pool.Get()
+	defer conn.Close()
+
+	queue := fmt.Sprintf("stream:%s", a.id)
+	for {
+		payload, err := redis.ByteSlices(conn.Do("BLPOP", queue, 0))
+		if err != nil {
+			return 0, err
+		}
+
+		var rec record
+		if err := json.Unmarshal(payload[1], &rec); err != nil || rec.Message == nil {
+			continue
+		}
+
+		msg := rec.Message
+		switch {
+		case msg.Meta.Is(stream.ExitSuccessFlag):
+			return 0, nil
+		case msg.Meta.Is(stream.ExitErrorFlag):
+			return 1, nil
+		case msg.Meta.Level() == stream.LevelStderr:
+			os.Stderr.WriteString(msg.Message)
+		default:
+			os.Stdout.WriteString(msg.Message)
+		}
+	}
+}
+
+func attachJob(socket string, id string) (int, error) {
+	a := &attacher{
+		id: id,
+		pool: &redis.Pool{
+			Dial: func() (redis.Conn, error) {
+				return redis.Dial("unix", socket)
+			},
+		},
+	}
+	defer a.pool.Close()
+
+	if restore, err := makeRaw(os.Stdin.Fd()); err == nil {
+		//stdin is a terminal, keep the job terminal size in sync
+		defer restore()
+		a.resize()
+
+		winch := make(chan os.Signal, 1)
+		signal.Notify(winch, syscall.SIGWINCH)
+		defer signal.Stop(winch)
+
+		go func() {
+			for range winch {
+				a.resize()
+			}
+		}()
+	}
+
+	go a.input()
+	return a.output()
+}
+
+func attach(c *cli.Context) {
+	id := c.Args().First()
+	if id == "" {
+		log.Fatal("wrong usage")
+	}
+
+	code, err := attachJob(c.GlobalString("redis"), id)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(code)
+}
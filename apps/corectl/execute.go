@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/codegangsta/cli"
 	"github.com/threefoldtech/0-core/base/pm"
 )
@@ -10,16 +12,28 @@ func system(t Transport, c *cli.Context) {
 		log.Fatalf("missing command to execute")
 		return
 	}
-	sync := !c.GlobalBool("async")
+	tty := c.GlobalBool("tty")
+	sync := !c.GlobalBool("async") && !tty
+	args := M{
+		"name": c.Args().First(),
+		"args": c.Args().Tail(),
+	}
+
+	if tty {
+		args["tty"] = true
+		if rows, cols, err := terminalSize(os.Stdin.Fd()); err == nil {
+			args["rows"] = rows
+			args["cols"] = cols
+		}
+	}
+
 	response, err := t.Run(Command{
 		Sync:      sync,
 		Container: c.GlobalString("container"),
 		Content: pm.Command{
-			Command: "core.system",
-			Arguments: pm.MustArguments(M{
-				"name": c.Args().First(),
-				"args": c.Args().Tail(),
-			}),
+			Command:   "core.system",
+			Arguments: pm.MustArguments(args),
+			Stream:    tty,
 		},
 	})
 
@@ -27,6 +41,14 @@ func system(t Transport, c *cli.Context) {
 		log.Fatal(err)
 	}
 
+	if tty {
+		code, err := attachJob(c.GlobalString("redis"), response.ID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(code)
+	}
+
 	if sync {
 		response.PrintStreams()
 		response.ValidateResultOrExit()
@@ -47,6 +47,15 @@ func main() {
 			Name:  "id",
 			Usage: "Speicify porcess id, if not given a random guid will be generated",
 		},
+		cli.BoolFlag{
+			Name:  "tty",
+			Usage: "Run command in a terminal and attach to it (only with execute)",
+		},
+		cli.StringFlag{
+			Name:  "redis",
+			Value: "/var/run/redis.sock",
+			Usage: "Path to redis socket (only with attach and --tty)",
+		},
 	}
 
 	app.Commands = []cli.Command{
@@ -63,6 +72,13 @@ func main() {
 			Action:          WithTransport(system),
 			SkipFlagParsing: true,
 		},
+		{
+			Name:        "attach",
+			Usage:       "attach to an interactive job with `id`",
+			Description: "forwards the local input to the job, and prints the job output until it exits. The job must be started with stream, and tty or interactive set",
+			ArgsUsage:   "id",
+			Action:      attach,
+		},
 		{
 			Name:      "stop",
 			Usage:     "stops a process with `id`",
@@ -7,6 +7,11 @@ import (
 	jwt "github.com/dgrijalva/jwt-go"
 )
 
+const (
+	//memberOf prefix of the organizations membership scopes
+	memberOf = "user:memberof:"
+)
+
 //In checks if s is in l
 func In(s string, l []string) bool {
 	for _, t := range l {
@@ -18,14 +23,44 @@ func In(s string, l []string) bool {
 	return false
 }
 
-//AuthMethod handles authorization of a user
-func AuthMethod(organizations []string, key string) (func(string) bool, error) {
+//Identity of an authenticated client, it's attached to the commands the client pushes to the core0
+//queue so core0 can authorize them
+type Identity struct {
+	Subject       string   `json:"subject"`
+	Organizations []string `json:"organizations,omitempty"`
+	Scopes        []string `json:"scopes,omitempty"`
+}
+
+//identity builds the client identity from the token claims, the organizations are the token azp
+//and the organizations of the user:memberof:<org> scopes
+func identity(claims jwt.MapClaims, scopes []string) *Identity {
+	id := &Identity{Scopes: scopes}
+
+	azp, _ := claims["azp"].(string)
+	if username, ok := claims["username"].(string); ok {
+		id.Subject = username
+	} else {
+		id.Subject = azp
+	}
+
+	if len(azp) != 0 {
+		id.Organizations = append(id.Organizations, azp)
+	}
+
+	for _, scope := range scopes {
+		if strings.HasPrefix(scope, memberOf) {
+			id.Organizations = append(id.Organizations, strings.TrimPrefix(scope, memberOf))
+		}
+	}
+
+	return id
+}
+
+//AuthMethod handles authorization of a user, it returns the identity of the authorized users
+func AuthMethod(organizations []string, key string) (func(string) (*Identity, bool), error) {
 	var scopes []string
 	for _, organization := range organizations {
-		scopes = append(
-			scopes,
-			fmt.Sprintf("user:memberof:%s", organization),
-		)
+		scopes = append(scopes, memberOf+organization)
 	}
 
 	pub, err := jwt.ParseECPublicKeyFromPEM([]byte(key))
@@ -33,7 +68,7 @@ func AuthMethod(organizations []string, key string) (func(string) bool, error) {
 		return nil, err
 	}
 
-	return func(token string) bool {
+	return func(token string) (*Identity, bool) {
 		log.Debugf("checking token: %s", token)
 		t, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
 			m, ok := t.Method.(*jwt.SigningMethodECDSA)
@@ -48,44 +83,41 @@ func AuthMethod(organizations []string, key string) (func(string) bool, error) {
 
 		if err != nil {
 			log.Errorf("JWT parse error: %s", err)
-			return false
+			return nil, false
 		}
 
 		if !t.Valid {
-			return false
+			return nil, false
 		}
 
 		claims := t.Claims.(jwt.MapClaims)
 
 		if err := claims.Valid(); err != nil {
 			log.Errorf("itsyouonline calim validation error: %s", err)
-			return false
+			return nil, false
 		}
 
-		if azp, ok := claims["azp"].(string); ok {
-			if In(azp, organizations) {
-				return true
+		var claimedScopes []string
+		if value, ok := claims["scope"].([]interface{}); ok {
+			for _, s := range value {
+				if s, ok := s.(string); ok {
+					claimedScopes = append(claimedScopes, s)
+				}
 			}
 		}
 
-		var claimedScopes []interface{}
-		if value, ok := claims["scope"]; ok {
-			if claimedScopes, ok = value.([]interface{}); !ok {
-				return false
+		if azp, ok := claims["azp"].(string); ok {
+			if In(azp, organizations) {
+				return identity(claims, claimedScopes), true
 			}
-		} else {
-			return false
 		}
 
 		for _, s := range claimedScopes {
-			switch s := s.(type) {
-			case string:
-				if In(s, scopes) {
-					return true
-				}
+			if In(s, scopes) {
+				return identity(claims, claimedScopes), true
 			}
 		}
 
-		return false
+		return nil, false
 	}, nil
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/redcon"
+)
+
+const (
+	//commandsQueue the core0 commands queue
+	commandsQueue = "core:default"
+	//stdinPrefix the prefix of the jobs stdin lists, core0 authorizes their entries as job.stdin
+	stdinPrefix = "stdin:"
+	//identityKey the command key core0 reads the client identity from
+	identityKey = "identity"
+)
+
+var (
+	//pushCommands the commands (or inputs) pushed with these to the protected lists get the client identity
+	pushCommands = map[string]bool{
+		"rpush": true, "lpush": true, "rpushx": true, "lpushx": true,
+	}
+
+	//readCommands can refer to the protected lists, since they don't change them
+	readCommands = map[string]bool{
+		"llen": true, "lrange": true, "lindex": true, "exists": true, "type": true, "ttl": true, "pttl": true,
+	}
+
+	//deniedCommands could push to the protected lists without being checked
+	deniedCommands = map[string]bool{
+		"eval": true, "evalsha": true, "script": true, "function": true, "fcall": true, "fcall_ro": true,
+	}
+)
+
+//stamp replaces the identity of a command with the client identity, so a client can't claim another
+//identity. The identity is removed if the client is not authenticated
+func stamp(payload []byte, identity *Identity) ([]byte, error) {
+	var command map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &command); err != nil {
+		return nil, fmt.Errorf("invalid payload: %s", err)
+	}
+
+	//json keys are matched case insensitively by core0
+	for key := range command {
+		if strings.EqualFold(key, identityKey) {
+			delete(command, key)
+		}
+	}
+
+	if identity != nil {
+		raw, err := json.Marshal(identity)
+		if err != nil {
+			return nil, err
+		}
+		command[identityKey] = raw
+	}
+
+	return json.Marshal(command)
+}
+
+//protected is true for the lists core0 reads with the client identity, the commands queue and the
+//jobs stdin lists
+func protected(key []byte) bool {
+	return string(key) == commandsQueue || strings.HasPrefix(string(key), stdinPrefix)
+}
+
+//guard checks a command before it's proxied. The commands (and inputs) pushed to the protected lists
+//get the client identity, and the other commands that could change these lists are rejected
+func guard(cmd redcon.Command, identity *Identity) error {
+	name := strings.ToLower(string(cmd.Args[0]))
+	if deniedCommands[name] {
+		return fmt.Errorf("command '%s' is not allowed", name)
+	}
+
+	if pushCommands[name] && len(cmd.Args) > 2 && protected(cmd.Args[1]) {
+		for i := 2; i < len(cmd.Args); i++ {
+			payload, err := stamp(cmd.Args[i], identity)
+			if err != nil {
+				return err
+			}
+			cmd.Args[i] = payload
+		}
+
+		return nil
+	}
+
+	if readCommands[name] {
+		return nil
+	}
+
+	for _, arg := range cmd.Args[1:] {
+		if protected(arg) {
+			return fmt.Errorf("command '%s' is not allowed on %s", name, arg)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/redcon"
+)
+
+func command(args ...string) redcon.Command {
+	var cmd redcon.Command
+	for _, arg := range args {
+		cmd.Args = append(cmd.Args, []byte(arg))
+	}
+
+	return cmd
+}
+
+func TestGuardStamp(t *testing.T) {
+	identity := &Identity{Subject: "dev", Scopes: []string{"user:memberof:org.team"}}
+
+	for _, key := range []string{commandsQueue, "stdin:job-id"} {
+		cmd := command("RPUSH", key, `{"id": "job", "Identity": {"subject": "admin"}}`)
+		if ok := assert.NoError(t, guard(cmd, identity)); !ok {
+			t.Fatal()
+		}
+
+		var payload map[string]json.RawMessage
+		if ok := assert.NoError(t, json.Unmarshal(cmd.Args[2], &payload)); !ok {
+			t.Fatal()
+		}
+
+		//the claimed identity is replaced by the client identity
+		if ok := assert.NotContains(t, payload, "Identity"); !ok {
+			t.Error()
+		}
+
+		var stamped Identity
+		if ok := assert.NoError(t, json.Unmarshal(payload[identityKey], &stamped)); !ok {
+			t.Fatal()
+		}
+
+		if ok := assert.Equal(t, "dev", stamped.Subject); !ok {
+			t.Error()
+		}
+	}
+
+	//an unauthenticated client has no identity
+	cmd := command("LPUSH", "stdin:job-id", `{"data": "", "identity": {"subject": "admin"}}`)
+	if ok := assert.NoError(t, guard(cmd, nil)); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.NotContains(t, string(cmd.Args[2]), "admin"); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Error(t, guard(command("RPUSH", "stdin:job-id", "not json"), identity)); !ok {
+		t.Error()
+	}
+}
+
+func TestGuardDenied(t *testing.T) {
+	denied := []redcon.Command{
+		command("EVAL", "return redis.call('rpush', KEYS[1], ARGV[1])", "1", commandsQueue, "{}"),
+		command("EVALSHA", "sha", "1", "stdin:job-id", "{}"),
+		command("SCRIPT", "LOAD", "return 1"),
+		//commands that move entries to the protected lists without the identity
+		command("RENAME", "list", commandsQueue),
+		command("RENAMENX", "list", "stdin:job-id"),
+		command("LMOVE", "list", commandsQueue, "LEFT", "RIGHT"),
+		command("BLMOVE", "list", "stdin:job-id", "LEFT", "RIGHT", "0"),
+		command("RPOPLPUSH", "list", commandsQueue),
+		command("LSET", commandsQueue, "0", "{}"),
+		command("LINSERT", "stdin:job-id", "BEFORE", "a", "{}"),
+		command("SORT", "list", "STORE", commandsQueue),
+		command("COPY", "list", "stdin:job-id"),
+		//or remove them
+		command("DEL", commandsQueue),
+		command("LPOP", "stdin:job-id"),
+	}
+
+	for _, cmd := range denied {
+		if ok := assert.Error(t, guard(cmd, nil), string(cmd.Args[0])); !ok {
+			t.Error()
+		}
+	}
+
+	allowed := []redcon.Command{
+		command("LLEN", commandsQueue),
+		command("LRANGE", "stdin:job-id", "0", "-1"),
+		command("RPUSH", "list", "{}"),
+		command("RENAME", "list", "other"),
+		command("BLPOP", "result:job-id", "10"),
+	}
+
+	for _, cmd := range allowed {
+		if ok := assert.NoError(t, guard(cmd, nil), string(cmd.Args[0])); !ok {
+			t.Error()
+		}
+	}
+}
@@ -26,14 +26,14 @@ func newPool(socket string) *redis.Pool {
 
 type redisProxy struct {
 	pool       *redis.Pool
-	authMethod func(string) bool
+	authMethod func(string) (*Identity, bool)
 	doAuth     bool
 }
 
 //Proxy start redis proxy
 func Proxy(listen, redis string, organizations []string) error {
-	authMethod := func(_ string) bool {
-		return true
+	authMethod := func(_ string) (*Identity, bool) {
+		return nil, true
 	}
 
 	doAuth := false
@@ -75,8 +75,11 @@ func (r *redisProxy) auth(conn redcon.Conn, cmd redcon.Command) {
 
 	password := string(cmd.Args[1])
 
-	if r.authMethod(password) {
-		conn.SetContext(true)
+	if identity, ok := r.authMethod(password); ok {
+		if identity == nil {
+			identity = &Identity{}
+		}
+		conn.SetContext(identity)
 		conn.WriteString("OK")
 	} else {
 		conn.WriteError("invalid jwt")
@@ -91,6 +94,12 @@ func (r *redisProxy) proxy(conn redcon.Conn, cmd redcon.Command) {
 		return
 	}
 
+	identity, _ := conn.Context().(*Identity)
+	if err := guard(cmd, identity); err != nil {
+		conn.WriteError(err.Error())
+		return
+	}
+
 	//proxy to underlying redis
 	local := r.pool.Get()
 	defer local.Close()
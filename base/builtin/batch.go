@@ -0,0 +1,30 @@
+package builtin
+
+import (
+	"encoding/json"
+
+	"github.com/threefoldtech/0-core/base/pm"
+)
+
+const (
+	cmdBatch = "core.batch"
+)
+
+func init() {
+	pm.RegisterBuiltInWithCtx(cmdBatch, batch)
+}
+
+type batchArguments struct {
+	Commands []pm.BatchCommand `json:"commands"`
+}
+
+//batch runs a set of commands honoring their depends_on edges, killing the batch job
+//stops all the batch commands
+func batch(ctx *pm.Context) (interface{}, error) {
+	var args batchArguments
+	if err := json.Unmarshal(*ctx.Command.Arguments, &args); err != nil {
+		return nil, pm.BadRequestError(err)
+	}
+
+	return pm.RunBatch(ctx.Command.ID, ctx.Command.Queue, args.Commands, ctx.Done())
+}
@@ -14,6 +14,11 @@ type aggregatedStatsMgr struct {
 	agent *psutil.Process
 }
 
+type aggregatedState struct {
+	pm.ProcessStats
+	Health map[string]pm.HealthStatus `json:"health,omitempty"`
+}
+
 func init() {
 	agent, err := psutil.NewProcess(int32(os.Getpid()))
 	if err != nil {
@@ -66,5 +71,8 @@ func (mgr *aggregatedStatsMgr) getAggregatedStats(cmd *pm.Command) (interface{},
 		}
 	}
 
-	return stat, nil
+	return aggregatedState{
+		ProcessStats: stat,
+		Health:       pm.Health(),
+	}, nil
 }
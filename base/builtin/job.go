@@ -13,6 +13,8 @@ const (
 	cmdJobKill       = "job.kill"
 	cmdJobKillAll    = "job.killall"
 	cmdJobUnschedule = "job.unschedule"
+	cmdJobQueueList  = "job.queue.list"
+	cmdJobStdin      = "job.stdin"
 )
 
 func init() {
@@ -20,6 +22,8 @@ func init() {
 	pm.RegisterBuiltIn(cmdJobKill, jobKill)
 	pm.RegisterBuiltIn(cmdJobKillAll, jobKillAll)
 	pm.RegisterBuiltIn(cmdJobUnschedule, jobUnschedule)
+	pm.RegisterBuiltIn(cmdJobQueueList, jobQueueList)
+	pm.RegisterBuiltIn(cmdJobStdin, jobStdin)
 }
 
 type jobArguments struct {
@@ -29,6 +33,8 @@ type jobArguments struct {
 type processData struct {
 	pm.ProcessStats
 	StartTime int64       `json:"starttime"`
+	NextRun   int64       `json:"nextrun,omitempty"`
+	Restarts  int         `json:"restarts"`
 	Cmd       *pm.Command `json:"cmd,omitempty"`
 	PID       int32       `json:"pid"`
 }
@@ -48,7 +54,7 @@ func jobList(cmd *pm.Command) (interface{}, error) {
 		job, ok := pm.JobOf(data.ID)
 
 		if !ok {
-			return nil, fmt.Errorf("Process with id '%s' doesn't exist", data.ID)
+			return nil, pm.NotFoundError(fmt.Errorf("Process with id '%s' doesn't exist", data.ID))
 		}
 
 		runners = []pm.Job{job}
@@ -62,6 +68,8 @@ func jobList(cmd *pm.Command) (interface{}, error) {
 		s := processData{
 			Cmd:       runner.Command(),
 			StartTime: runner.StartTime(),
+			NextRun:   runner.NextRun(),
+			Restarts:  runner.Restarts(),
 		}
 
 		ps := runner.Process()
@@ -138,3 +146,25 @@ func jobKillAll(cmd *pm.Command) (interface{}, error) {
 	pm.Killall()
 	return true, nil
 }
+
+func jobQueueList(cmd *pm.Command) (interface{}, error) {
+	return pm.Queues(), nil
+}
+
+type jobStdinArguments struct {
+	jobArguments
+	pm.Input
+}
+
+func jobStdin(cmd *pm.Command) (interface{}, error) {
+	var data jobStdinArguments
+	if err := json.Unmarshal(*cmd.Arguments, &data); err != nil {
+		return nil, pm.BadRequestError(err)
+	}
+
+	if err := pm.Feed(data.ID, &data.Input); err != nil {
+		return nil, err
+	}
+
+	return true, nil
+}
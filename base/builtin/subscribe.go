@@ -26,10 +26,12 @@ func subscribe(ctx *pm.Context) (interface{}, error) {
 		return nil, fmt.Errorf("job '%s' does not exist", args.ID)
 	}
 
-	job.Subscribe(func(msg *stream.Message) {
+	unsubscribe := job.Subscribe(func(msg *stream.Message) {
 		ctx.Message(msg)
 	})
 
+	defer unsubscribe()
+
 	job.Wait()
 	return nil, nil
 }
@@ -0,0 +1,123 @@
+package pm
+
+import (
+	"fmt"
+	"syscall"
+
+	psutils "github.com/shirou/gopsutil/process"
+	"github.com/threefoldtech/0-core/base/pm/stream"
+)
+
+/*
+adoptedProcess is a process that was started by a previous instance of core0 and
+that is still running. The process output is lost, but we can still signal it, collect
+its stats and wait for it to exit.
+*/
+type adoptedProcess struct {
+	cmd     *Command
+	pid     int
+	process *psutils.Process
+	oom     bool
+
+	table PIDTable
+}
+
+func newAdoptedProcess(table PIDTable, cmd *Command, pid int) Process {
+	return &adoptedProcess{
+		cmd:   cmd,
+		pid:   pid,
+		table: table,
+	}
+}
+
+func (p *adoptedProcess) Command() *Command {
+	return p.cmd
+}
+
+func (p *adoptedProcess) Stats() *ProcessStats {
+	stats := ProcessStats{}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Warningf("processUtils panic: %s", r)
+		}
+	}()
+
+	ps := p.process
+	if ps == nil {
+		return &stats
+	}
+
+	cpu, err := ps.Percent(0)
+	if err == nil {
+		stats.CPU = cpu
+	}
+
+	mem, err := ps.MemoryInfo()
+	if err == nil {
+		stats.RSS = mem.RSS
+		stats.VMS = mem.VMS
+		stats.Swap = mem.Swap
+	}
+
+	return &stats
+}
+
+func (p *adoptedProcess) GetPID() int32 {
+	return int32(p.pid)
+}
+
+//OOMKilled returns true if the process was killed because it exceeded its memory limit
+func (p *adoptedProcess) OOMKilled() bool {
+	return p.oom
+}
+
+func (p *adoptedProcess) Signal(sig syscall.Signal) error {
+	kill := p.pid
+	if !p.cmd.Flags.NoSetPGID {
+		gid, err := syscall.Getpgid(kill)
+		if err != nil {
+			return err
+		}
+		kill = -gid
+	}
+
+	return syscall.Kill(kill, sig)
+}
+
+func (p *adoptedProcess) Run() (<-chan *stream.Message, error) {
+	_, err := p.table.RegisterPID(func() (int, error) {
+		return p.pid, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	//the process might have been reaped before it was registered.
+	if err := syscall.Kill(p.pid, 0); err != nil {
+		return nil, fmt.Errorf("adopted process %d is gone: %s", p.pid, err)
+	}
+
+	p.process, _ = psutils.NewProcess(int32(p.pid))
+
+	channel := make(chan *stream.Message)
+	go func() {
+		defer close(channel)
+		state := p.table.WaitPID(p.pid)
+		p.oom = unlimit(p.cmd)
+		code := state.ExitStatus()
+		log.Debugf("Adopted process %s exited with state: %d", p.cmd, code)
+		if code == 0 {
+			channel <- &stream.Message{
+				Meta: stream.NewMeta(stream.LevelStdout, stream.ExitSuccessFlag),
+			}
+		} else {
+			channel <- &stream.Message{
+				Meta: stream.NewMetaWithCode(uint32(1000+code), stream.LevelStderr, stream.ExitErrorFlag),
+			}
+		}
+	}()
+
+	return channel, nil
+}
@@ -60,3 +60,8 @@ func (b *BackOff) Duration() (duration time.Duration) {
 	duration = b.Delay
 	return
 }
+
+//Reset resets the backoff, so next duration is the initial delay
+func (b *BackOff) Reset() {
+	b.lastDelay = 0
+}
@@ -0,0 +1,262 @@
+package pm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/threefoldtech/0-core/base/utils"
+)
+
+const (
+	batchStopTimeout = 10 * time.Second
+)
+
+//BatchCommand is a command in a batch. The command ID is its name inside the batch, the job
+//itself runs under the id `<batch-id>.<command-id>`
+type BatchCommand struct {
+	Command
+	//DependsOn ids of the batch commands that must succeed before this command runs. The string
+	//arguments of the command can refer to the output of a dependency with {<id>.stdout},
+	//{<id>.stderr} and {<id>.data}, trailing new lines of the output are removed
+	DependsOn []string `json:"depends_on,omitempty"`
+}
+
+//BatchResult is the result of a batch run
+type BatchResult struct {
+	//State SUCCESS if all the commands succeeded, KILLED if the batch was cancelled, ERROR otherwise
+	State JobState `json:"state"`
+	//Results of the batch commands, mapped by command id
+	Results map[string]*JobResult `json:"results"`
+}
+
+//validateBatch makes sure the batch commands ids are unique, and the dependencies exist and
+//have no cycles. The commands can't use the queue of the batch job, since the batch job holds its
+//queue slot until all its commands are done
+func validateBatch(queue string, cmds []BatchCommand) error {
+	deps := make(map[string][]string)
+	for _, cmd := range cmds {
+		if len(cmd.ID) == 0 {
+			return fmt.Errorf("batch command '%s' has no id", cmd.Command.Command)
+		}
+
+		if _, ok := deps[cmd.ID]; ok {
+			return fmt.Errorf("duplicate batch command id '%s'", cmd.ID)
+		}
+
+		if cmd.RecurringPeriod != 0 || cmd.Schedule != nil {
+			return fmt.Errorf("batch command '%s' can't be recurring or scheduled", cmd.ID)
+		}
+
+		if len(queue) != 0 && cmd.Queue == queue {
+			return fmt.Errorf("batch command '%s' can't run on the batch queue '%s'", cmd.ID, queue)
+		}
+
+		deps[cmd.ID] = cmd.DependsOn
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	marks := make(map[string]int)
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		switch marks[id] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, id), " -> "))
+		case visited:
+			return nil
+		}
+
+		marks[id] = visiting
+		for _, dep := range deps[id] {
+			if _, ok := deps[dep]; !ok {
+				return fmt.Errorf("batch command '%s' depends on unknown command '%s'", id, dep)
+			}
+
+			if err := visit(dep, append(path, id)); err != nil {
+				return err
+			}
+		}
+		marks[id] = visited
+
+		return nil
+	}
+
+	for _, cmd := range cmds {
+		if err := visit(cmd.ID, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//formatArgs replaces the {key} place holders in all the string values of the arguments
+func formatArgs(value interface{}, values map[string]interface{}) interface{} {
+	switch value := value.(type) {
+	case string:
+		return utils.Format(value, values)
+	case []interface{}:
+		for i, v := range value {
+			value[i] = formatArgs(v, values)
+		}
+	case map[string]interface{}:
+		for k, v := range value {
+			value[k] = formatArgs(v, values)
+		}
+	}
+
+	return value
+}
+
+type batch struct {
+	id      string
+	state   stateMachine
+	cancel  <-chan struct{}
+	values  map[string]interface{}
+	results map[string]*JobResult
+	m       sync.Mutex
+}
+
+func (b *batch) done(id string, result *JobResult, output *StreamHook) {
+	b.m.Lock()
+	b.results[id] = result
+	if output != nil {
+		//same as a shell command substitution, the trailing new lines are dropped
+		b.values[id+".stdout"] = strings.TrimRight(output.Stdout.String(), "\n")
+		b.values[id+".stderr"] = strings.TrimRight(output.Stderr.String(), "\n")
+		b.values[id+".data"] = result.Data
+	}
+	b.m.Unlock()
+
+	b.state.Release(id, result.State == StateSuccess)
+}
+
+func (b *batch) abort(cmd *Command, state JobState, reason string) {
+	result := NewJobResult(cmd)
+	result.State = state
+	result.Data = reason
+
+	b.done(cmd.ID, result, nil)
+}
+
+func (b *batch) command(cmd *BatchCommand) (*Command, error) {
+	c := cmd.Command
+	c.ID = fmt.Sprintf("%s.%s", b.id, cmd.ID)
+	c.Flags = JobFlags{}
+
+	if c.Arguments == nil {
+		return &c, nil
+	}
+
+	var args interface{}
+	if err := json.Unmarshal(*c.Arguments, &args); err != nil {
+		return nil, err
+	}
+
+	b.m.Lock()
+	args = formatArgs(args, b.values)
+	b.m.Unlock()
+
+	c.Arguments = MustArguments(args)
+	return &c, nil
+}
+
+func (b *batch) run(cmd *BatchCommand) {
+	if !b.state.Wait(cmd.DependsOn...) {
+		b.abort(&cmd.Command, StateSkipped, "one of the dependencies failed")
+		return
+	}
+
+	select {
+	case <-b.cancel:
+		b.abort(&cmd.Command, StateKilled, "batch was cancelled")
+		return
+	default:
+	}
+
+	c, err := b.command(cmd)
+	if err != nil {
+		b.abort(&cmd.Command, StateError, fmt.Sprintf("invalid arguments: %s", err))
+		return
+	}
+
+	var output StreamHook
+	job, err := Run(c, &output)
+	if err != nil {
+		b.abort(&cmd.Command, StateError, err.Error())
+		return
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		select {
+		case <-b.cancel:
+			log.Infof("batch %s cancelled, stopping %s", b.id, c.ID)
+			Stop(c.ID, batchStopTimeout)
+		case <-exited:
+		}
+	}()
+
+	result := job.Wait()
+	close(exited)
+
+	//the result keeps the id of the command in the batch
+	result.ID = cmd.ID
+	b.done(cmd.ID, result, &output)
+}
+
+//RunBatch runs a batch of commands honoring their dependencies, a command only runs if all
+//the commands it depends on succeeded. Closing cancel stops the running commands, and the
+//pending ones are not started. RunBatch returns once all the commands are done. queue is the
+//queue of the batch job itself.
+func RunBatch(id string, queue string, cmds []BatchCommand, cancel <-chan struct{}) (*BatchResult, error) {
+	if err := validateBatch(queue, cmds); err != nil {
+		return nil, BadRequestError(err)
+	}
+
+	var keys []string
+	for _, cmd := range cmds {
+		keys = append(keys, cmd.ID)
+	}
+
+	b := &batch{
+		id:      id,
+		state:   newStateMachine(keys...),
+		cancel:  cancel,
+		values:  make(map[string]interface{}),
+		results: make(map[string]*JobResult),
+	}
+
+	for i := range cmds {
+		go b.run(&cmds[i])
+	}
+
+	b.state.WaitAll()
+
+	result := &BatchResult{
+		State:   StateSuccess,
+		Results: b.results,
+	}
+
+	select {
+	case <-cancel:
+		result.State = StateKilled
+		return result, nil
+	default:
+	}
+
+	for _, r := range b.results {
+		if r.State != StateSuccess {
+			result.State = StateError
+			break
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,206 @@
+package pm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var batchTest sync.Once
+
+//startBatchTest registers the test builtins and starts the manager loop (without the process reaper)
+func startBatchTest() {
+	New()
+	batchTest.Do(func() {
+		if MaxJobs == 0 {
+			MaxJobs = 100
+		}
+
+		RegisterBuiltInWithCtx("test.batch.echo", func(ctx *Context) (interface{}, error) {
+			var args struct {
+				Value string `json:"value"`
+			}
+
+			if err := json.Unmarshal(*ctx.Command.Arguments, &args); err != nil {
+				return nil, err
+			}
+
+			ctx.Log(args.Value + "\n")
+			return args.Value, nil
+		})
+
+		RegisterBuiltIn("test.batch.fail", func(cmd *Command) (interface{}, error) {
+			return nil, fmt.Errorf("failed")
+		})
+
+		RegisterBuiltInWithCtx("test.batch.block", func(ctx *Context) (interface{}, error) {
+			<-ctx.Done()
+			return nil, fmt.Errorf("cancelled")
+		})
+
+		go loop()
+	})
+}
+
+func TestBatch(t *testing.T) {
+	startBatchTest()
+
+	cmds := []BatchCommand{
+		{
+			Command: Command{
+				ID:        "b",
+				Command:   "test.batch.echo",
+				Arguments: MustArguments(M{"value": "got {a.stdout}"}),
+			},
+			DependsOn: []string{"a"},
+		},
+		{
+			Command: Command{
+				ID:        "a",
+				Command:   "test.batch.echo",
+				Arguments: MustArguments(M{"value": "hello"}),
+			},
+		},
+		{
+			Command: Command{
+				ID:      "fail",
+				Command: "test.batch.fail",
+			},
+		},
+		{
+			Command: Command{
+				ID:        "c",
+				Command:   "test.batch.echo",
+				Arguments: MustArguments(M{"value": "never"}),
+			},
+			DependsOn: []string{"a", "fail"},
+		},
+	}
+
+	result, err := RunBatch("batch-test", "", cmds, nil)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, StateError, result.State); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Len(t, result.Results, 4); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, StateSuccess, result.Results["a"].State); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, StateSuccess, result.Results["b"].State); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, "got hello\n", result.Results["b"].Streams.Stdout()); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, StateError, result.Results["fail"].State); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, StateSkipped, result.Results["c"].State); !ok {
+		t.Error()
+	}
+}
+
+func TestBatchCancel(t *testing.T) {
+	startBatchTest()
+
+	cmds := []BatchCommand{
+		{
+			Command: Command{
+				ID:      "a",
+				Command: "test.batch.block",
+			},
+		},
+		{
+			Command: Command{
+				ID:        "b",
+				Command:   "test.batch.echo",
+				Arguments: MustArguments(M{"value": "never"}),
+			},
+			DependsOn: []string{"a"},
+		},
+	}
+
+	cancel := make(chan struct{})
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		close(cancel)
+	}()
+
+	done := make(chan *BatchResult)
+	go func() {
+		result, err := RunBatch("batch-cancel-test", "", cmds, cancel)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- result
+	}()
+
+	var result *BatchResult
+	select {
+	case result = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch was not cancelled")
+	}
+
+	if ok := assert.Equal(t, StateKilled, result.State); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, StateSkipped, result.Results["b"].State); !ok {
+		t.Error()
+	}
+}
+
+func TestBatchInvalid(t *testing.T) {
+	cases := [][]BatchCommand{
+		//no id
+		{
+			{Command: Command{Command: "a"}},
+		},
+		//duplicate id
+		{
+			{Command: Command{ID: "a"}},
+			{Command: Command{ID: "a"}},
+		},
+		//unknown dependency
+		{
+			{Command: Command{ID: "a"}, DependsOn: []string{"b"}},
+		},
+		//cycle
+		{
+			{Command: Command{ID: "a"}, DependsOn: []string{"c"}},
+			{Command: Command{ID: "b"}, DependsOn: []string{"a"}},
+			{Command: Command{ID: "c"}, DependsOn: []string{"b"}},
+		},
+		//recurring
+		{
+			{Command: Command{ID: "a", RecurringPeriod: 10}},
+		},
+		//same queue as the batch job
+		{
+			{Command: Command{ID: "a", Queue: "batch-queue"}},
+		},
+	}
+
+	for _, cmds := range cases {
+		_, err := RunBatch("batch-invalid", "batch-queue", cmds, nil)
+		if ok := assert.Error(t, err); !ok {
+			t.Error()
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"runtime/debug"
+	"sync"
 	"syscall"
 
 	"github.com/threefoldtech/0-core/base/pm/stream"
@@ -19,7 +20,23 @@ type RunnableWithCtx func(*Context) (interface{}, error)
 type Context struct {
 	Command *Command
 
-	ch chan *stream.Message
+	ch   chan *stream.Message
+	done chan struct{}
+	o    sync.Once
+}
+
+//Done returns a channel that is closed when the job is terminated (killed or timed out), long
+//running builtins should stop their work once it's closed
+func (c *Context) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Context) cancel() {
+	c.o.Do(func() {
+		if c.done != nil {
+			close(c.done)
+		}
+	})
 }
 
 func (c *Context) Message(msg *stream.Message) {
@@ -59,6 +76,7 @@ func NewInternalProcess(runnable Runnable) ProcessFactory {
 			runnable: runnable,
 			ctx: Context{
 				Command: cmd,
+				done:    make(chan struct{}),
 			},
 		}
 	}
@@ -72,6 +90,7 @@ func NewInternalProcessWithCtx(runnable RunnableWithCtx) ProcessFactory {
 			runnable: runnable,
 			ctx: Context{
 				Command: cmd,
+				done:    make(chan struct{}),
 			},
 		}
 	}
@@ -147,6 +166,15 @@ func (process *internalProcess) Run() (<-chan *stream.Message, error) {
 	return channel, nil
 }
 
+/*
+Signal cancels the internal process context on termination signals, it's up to the
+runnable to honor the cancellation
+*/
 func (process *internalProcess) Signal(sig syscall.Signal) error {
+	switch sig {
+	case syscall.SIGTERM, syscall.SIGKILL, syscall.SIGINT:
+		process.ctx.cancel()
+	}
+
 	return nil
 }
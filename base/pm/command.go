@@ -37,6 +37,13 @@ type Command struct {
 	MaxRestart int `json:"max_restart,omitempty"`
 	//RecurringPeriod for recurring commands, defines how long it should wait between each run
 	RecurringPeriod int `json:"recurring_period,omitempty"`
+	//Schedule runs the command on a cron like schedule, can't be used with RecurringPeriod
+	Schedule *Schedule `json:"schedule,omitempty"`
+	//RestartPolicy defines when and how fast the command is restarted after it exits, can't be used with
+	//RecurringPeriod or Schedule
+	RestartPolicy *RestartPolicy `json:"restart_policy,omitempty"`
+	//Limits resources limits of the job process
+	Limits *Limits `json:"limits,omitempty"`
 	//Stream if set to true, real time output of the process will get streamed over the output
 	//channel
 	Stream bool `json:"stream"`
@@ -44,6 +51,8 @@ type Command struct {
 	LogLevels []int `json:"log_levels,omitempty"`
 	//Tags custom user tags to be attached to the job
 	Tags Tags `json:"tags"`
+	//Webhook if set, the job result is posted to the webhook url when the job exits
+	Webhook *Webhook `json:"webhook,omitempty"`
 
 	//For internal use only, flags that can be set from inside the internal API
 	Flags JobFlags `json:"-"`
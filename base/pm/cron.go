@@ -0,0 +1,255 @@
+package pm
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	//jitter source, seeded so the jitter differs between boots
+	cronRand  = rand.New(rand.NewSource(time.Now().UnixNano()))
+	cronRandM sync.Mutex
+
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+
+	cronMonths = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+
+	cronDays = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+)
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var cronFields = []cronField{
+	{0, 59, nil},        //minute
+	{0, 23, nil},        //hour
+	{1, 31, nil},        //day of month
+	{1, 12, cronMonths}, //month
+	{0, 7, cronDays},    //day of week (0 and 7 are sunday)
+}
+
+//Schedule defines a cron like schedule for a command
+type Schedule struct {
+	//Cron expression in the standard 5 fields format (minute hour dom month dow) or one
+	//of the descriptors @yearly, @monthly, @weekly, @daily, @hourly
+	Cron string `json:"cron"`
+	//Jitter max random delay in seconds that is added to each fire time
+	Jitter int `json:"jitter,omitempty"`
+	//Timezone name the cron expression is evaluated in (default to UTC)
+	Timezone string `json:"timezone,omitempty"`
+}
+
+//CronSchedule is a parsed cron expression
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+
+	//if one of dom or dow is restricted, only that field is matched, otherwise
+	//a day matches if any of them matches (same as vixie cron)
+	domStar, dowStar bool
+
+	jitter   time.Duration
+	location *time.Location
+}
+
+func (f *cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value '%s'", s)
+	}
+
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value '%d' out of range [%d, %d]", v, f.min, f.max)
+	}
+
+	return v, nil
+}
+
+func (f *cronField) parse(spec string) (bits uint64, err error) {
+	for _, part := range strings.Split(spec, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i != -1 {
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step '%s'", part[i+1:])
+			}
+			part = part[:i]
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			if start, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if end, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid range '%s'", part)
+			}
+		default:
+			if start, err = f.value(part); err != nil {
+				return 0, err
+			}
+			if step == 1 {
+				end = start
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+//ParseCron parses a standard 5 fields cron expression
+func ParseCron(spec string) (*CronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression '%s': expecting %d fields", spec, len(cronFields))
+	}
+
+	var bits [5]uint64
+	for i, field := range fields {
+		v, err := cronFields[i].parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression '%s': %s", spec, err)
+		}
+		bits[i] = v
+	}
+
+	schedule := &CronSchedule{
+		minute:   bits[0],
+		hour:     bits[1],
+		dom:      bits[2],
+		month:    bits[3],
+		dow:      bits[4],
+		domStar:  fields[2] == "*",
+		dowStar:  fields[4] == "*",
+		location: time.UTC,
+	}
+
+	//sunday can be 0 or 7
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+
+	return schedule, nil
+}
+
+//Parse validates the schedule and returns the cron schedule object
+func (s *Schedule) Parse() (*CronSchedule, error) {
+	schedule, err := ParseCron(s.Cron)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Jitter < 0 {
+		return nil, fmt.Errorf("invalid jitter '%d'", s.Jitter)
+	}
+
+	schedule.jitter = time.Duration(s.Jitter) * time.Second
+
+	if len(s.Timezone) != 0 {
+		location, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone '%s': %s", s.Timezone, err)
+		}
+		schedule.location = location
+	}
+
+	return schedule, nil
+}
+
+func (c *CronSchedule) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+//Next returns the next fire time after t (jitter is not included)
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	t = t.In(c.location).Truncate(time.Minute).Add(time.Minute)
+	//a valid expression must match at least once in 5 years (leap years included)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.location)
+			continue
+		}
+
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.location)
+			continue
+		}
+
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.location)
+			continue
+		}
+
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+//Delay returns how long to wait from now until the next fire time, with jitter applied
+func (c *CronSchedule) Delay(now time.Time) (time.Duration, error) {
+	next := c.Next(now)
+	if next.IsZero() {
+		return 0, fmt.Errorf("cron expression never fires")
+	}
+
+	delay := next.Sub(now)
+	if c.jitter > 0 {
+		cronRandM.Lock()
+		delay += time.Duration(cronRand.Int63n(int64(c.jitter)))
+		cronRandM.Unlock()
+	}
+
+	return delay, nil
+}
@@ -0,0 +1,99 @@
+package pm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronNext(t *testing.T) {
+	now := time.Date(2018, time.March, 14, 10, 30, 20, 0, time.UTC) //wednesday
+
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2018, time.March, 14, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2018, time.March, 14, 10, 45, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2018, time.March, 15, 3, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2018, time.March, 14, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2018, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * sun", time.Date(2018, time.March, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2018, time.March, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1-5 jan-mar *", time.Date(2019, time.January, 1, 12, 0, 0, 0, time.UTC)},
+		//dom and dow restricted, any of them matches
+		{"0 0 20 * mon", time.Date(2018, time.March, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		schedule, err := ParseCron(c.spec)
+		if ok := assert.NoError(t, err, c.spec); !ok {
+			t.Fatal()
+		}
+
+		if ok := assert.Equal(t, c.next, schedule.Next(now), c.spec); !ok {
+			t.Error()
+		}
+	}
+}
+
+func TestCronInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * abc *",
+	} {
+		_, err := ParseCron(spec)
+		if ok := assert.Error(t, err, spec); !ok {
+			t.Error()
+		}
+	}
+
+	//never fires
+	schedule, err := ParseCron("0 0 31 2 *")
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	_, err = schedule.Delay(time.Now())
+	if ok := assert.Error(t, err); !ok {
+		t.Error()
+	}
+}
+
+func TestScheduleTimezone(t *testing.T) {
+	s := Schedule{Cron: "0 3 * * *", Timezone: "Asia/Tokyo"}
+	schedule, err := s.Parse()
+	if err != nil {
+		t.Skip("timezone database not available:", err)
+	}
+
+	now := time.Date(2018, time.March, 14, 10, 0, 0, 0, time.UTC)
+	//03:00 in Tokyo is 18:00 UTC
+	if ok := assert.True(t, schedule.Next(now).Equal(time.Date(2018, time.March, 14, 18, 0, 0, 0, time.UTC))); !ok {
+		t.Error()
+	}
+
+	s = Schedule{Cron: "0 3 * * *", Jitter: 60}
+	schedule, err = s.Parse()
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	delay, err := schedule.Delay(now)
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.True(t, delay >= 17*time.Hour && delay < 17*time.Hour+time.Minute); !ok {
+		t.Error()
+	}
+}
@@ -40,6 +40,27 @@ func extensionProcessFactory(exe string, dir string, args []string, env map[stri
 			delete(input, "stdin")
 		}
 
+		//terminal options are passed to the extension process and not to its arguments
+		if tty, ok := input["tty"].(bool); ok {
+			sysargs.Tty = tty
+			delete(input, "tty")
+		}
+
+		if interactive, ok := input["interactive"].(bool); ok {
+			sysargs.Interactive = interactive
+			delete(input, "interactive")
+		}
+
+		if rows, ok := input["rows"].(float64); ok {
+			sysargs.Rows = uint16(rows)
+			delete(input, "rows")
+		}
+
+		if cols, ok := input["cols"].(float64); ok {
+			sysargs.Cols = uint16(cols)
+			delete(input, "cols")
+		}
+
 		for _, arg := range args {
 			sysargs.Args = append(sysargs.Args, utils.Format(arg, input))
 		}
@@ -49,6 +70,7 @@ func extensionProcessFactory(exe string, dir string, args []string, env map[stri
 			Command:   CommandSystem,
 			Arguments: MustArguments(sysargs),
 			Tags:      cmd.Tags,
+			Limits:    cmd.Limits,
 		}
 
 		return &extensionProcess{
@@ -76,6 +98,14 @@ func (process *extensionProcess) Signal(sig syscall.Signal) error {
 	return fmt.Errorf("not supported")
 }
 
+func (process *extensionProcess) OOMKilled() bool {
+	if sys, ok := process.system.(Limited); ok {
+		return sys.OOMKilled()
+	}
+
+	return false
+}
+
 func (process *extensionProcess) Stats() *ProcessStats {
 	if sys, ok := process.system.(Stater); ok {
 		return sys.Stats()
@@ -83,3 +113,27 @@ func (process *extensionProcess) Stats() *ProcessStats {
 
 	return nil
 }
+
+func (process *extensionProcess) Write(data []byte) (int, error) {
+	if sys, ok := process.system.(Interactive); ok {
+		return sys.Write(data)
+	}
+
+	return 0, fmt.Errorf("not supported")
+}
+
+func (process *extensionProcess) CloseInput() error {
+	if sys, ok := process.system.(Interactive); ok {
+		return sys.CloseInput()
+	}
+
+	return fmt.Errorf("not supported")
+}
+
+func (process *extensionProcess) Resize(rows, cols uint16) error {
+	if sys, ok := process.system.(Interactive); ok {
+		return sys.Resize(rows, cols)
+	}
+
+	return fmt.Errorf("not supported")
+}
@@ -0,0 +1,117 @@
+package pm
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path"
+	"sync"
+)
+
+//fileJournal is a Journal that appends json encoded entries to a local file, one entry per line
+type fileJournal struct {
+	name string
+	file *os.File
+	m    sync.Mutex
+}
+
+//NewFileJournal creates a journal backed by a local file
+func NewFileJournal(name string) (Journal, error) {
+	if err := os.MkdirAll(path.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileJournal{
+		name: name,
+		file: file,
+	}, nil
+}
+
+func (j *fileJournal) Append(entry *JournalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	_, err = j.file.Write(append(data, '\n'))
+	return err
+}
+
+func (j *fileJournal) Entries() ([]*JournalEntry, error) {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	file, err := os.Open(j.name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var entries []*JournalEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 10*1024*1024) //commands arguments can be big
+	for scanner.Scan() {
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			//a partially written entry (core0 died while writing) is skipped
+			log.Warningf("skipping corrupted journal entry: %s", err)
+			continue
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+func (j *fileJournal) Rewrite(entries []*JournalEntry) error {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	tmp := j.name + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	enc := json.NewEncoder(writer)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, j.name); err != nil {
+		return err
+	}
+
+	file, err = os.OpenFile(j.name, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	j.file.Close()
+	j.file = file
+
+	return nil
+}
@@ -22,6 +22,23 @@ type StatsHandler interface {
 	Stats(operation string, key string, value float64, id string, tags ...Tag)
 }
 
+//LimitHandler enforces the command limits on the job process
+type LimitHandler interface {
+	//Limit is called with the job process pid right after it's started, the process doesn't
+	//execute the command before Limit returns
+	Limit(cmd *Command, pid int) error
+	//Unlimit is called once the job process has exited, it returns true if the process
+	//was killed because it exceeded its memory limit
+	Unlimit(cmd *Command) bool
+}
+
+//InputHandler feeds the input of interactive jobs
+type InputHandler interface {
+	//Attach is called once an interactive job is started, the handler should feed the job input
+	//(with Feed) until done is closed
+	Attach(id string, done <-chan struct{})
+}
+
 //PreHandler is called with the commands before exectution
 type PreHandler interface {
 	Pre(cmd *Command)
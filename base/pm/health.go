@@ -0,0 +1,286 @@
+package pm
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+)
+
+const (
+	//HealthStarting the health of the job is not known yet
+	HealthStarting = "starting"
+	//HealthHealthy the health check passes
+	HealthHealthy = "healthy"
+	//HealthUnhealthy the health check failed more than the allowed retries
+	HealthUnhealthy = "unhealthy"
+	//HealthStopped the job process is not running
+	HealthStopped = "stopped"
+
+	defaultHealthInterval = 10 * time.Second
+	defaultHealthTimeout  = 5 * time.Second
+	defaultHealthRetries  = 3
+)
+
+//HealthCheck defines how to probe a job health, only one of the probes (TCP, Unix, HTTP or Exec) can be set
+type HealthCheck struct {
+	//TCP address (host:port) that must accept connections
+	TCP string `json:"tcp,omitempty"`
+	//Unix socket path that must accept connections
+	Unix string `json:"unix,omitempty"`
+	//HTTP url that must answer a GET request with a status code < 400
+	HTTP string `json:"http,omitempty"`
+	//Exec command (and its arguments) that must exit with success
+	Exec []string `json:"exec,omitempty"`
+
+	//Interval in seconds between probes (default 10)
+	Interval int `json:"interval,omitempty"`
+	//Timeout of a single probe in seconds (default 5)
+	Timeout int `json:"timeout,omitempty"`
+	//Retries number of consecutive failed probes before the job is considered unhealthy (default 3)
+	Retries int `json:"retries,omitempty"`
+	//StartPeriod seconds after the job start during which failed probes are not counted
+	StartPeriod int `json:"start_period,omitempty"`
+}
+
+//HealthStatus is the health of a job
+type HealthStatus struct {
+	Status    string `json:"status"`
+	Failures  int    `json:"failures"`
+	LastCheck int64  `json:"lastcheck"`
+	Error     string `json:"error,omitempty"`
+}
+
+var (
+	health  = make(map[string]HealthStatus)
+	healthM sync.RWMutex
+)
+
+//Health returns the health status of all the jobs with a health check
+func Health() map[string]HealthStatus {
+	healthM.RLock()
+	defer healthM.RUnlock()
+
+	res := make(map[string]HealthStatus)
+	for id, status := range health {
+		res[id] = status
+	}
+
+	return res
+}
+
+func setHealth(id string, status HealthStatus) {
+	healthM.Lock()
+	defer healthM.Unlock()
+
+	health[id] = status
+}
+
+func removeHealth(id string) {
+	healthM.Lock()
+	defer healthM.Unlock()
+
+	delete(health, id)
+}
+
+//Validate checks the health check values
+func (h *HealthCheck) Validate() error {
+	probes := 0
+	for _, set := range []bool{len(h.TCP) != 0, len(h.Unix) != 0, len(h.HTTP) != 0, len(h.Exec) != 0} {
+		if set {
+			probes++
+		}
+	}
+
+	if probes != 1 {
+		return fmt.Errorf("health check must define exactly one of tcp, unix, http or exec")
+	}
+
+	if h.Interval < 0 || h.Timeout < 0 || h.Retries < 0 || h.StartPeriod < 0 {
+		return fmt.Errorf("health check interval, timeout, retries and start_period can't be negative")
+	}
+
+	return nil
+}
+
+func (h *HealthCheck) interval() time.Duration {
+	if h.Interval == 0 {
+		return defaultHealthInterval
+	}
+
+	return time.Duration(h.Interval) * time.Second
+}
+
+func (h *HealthCheck) timeout() time.Duration {
+	if h.Timeout == 0 {
+		return defaultHealthTimeout
+	}
+
+	return time.Duration(h.Timeout) * time.Second
+}
+
+func (h *HealthCheck) retries() int {
+	if h.Retries == 0 {
+		return defaultHealthRetries
+	}
+
+	return h.Retries
+}
+
+func (h *HealthCheck) dial(network, address string) error {
+	con, err := net.DialTimeout(network, address, h.timeout())
+	if err != nil {
+		return err
+	}
+
+	return con.Close()
+}
+
+func (h *HealthCheck) get() error {
+	client := http.Client{Timeout: h.timeout()}
+	response, err := client.Get(h.HTTP)
+	if err != nil {
+		return err
+	}
+
+	response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s returned status %s", h.HTTP, response.Status)
+	}
+
+	return nil
+}
+
+func (h *HealthCheck) exec() error {
+	job, err := Run(&Command{
+		ID:      uuid.New(),
+		Command: CommandSystem,
+		MaxTime: int(h.timeout() / time.Second),
+		Arguments: MustArguments(
+			SystemCommandArguments{
+				Name: h.Exec[0],
+				Args: h.Exec[1:],
+			},
+		),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	result := job.Wait()
+	if result.State != StateSuccess {
+		return fmt.Errorf("(%s): %s", result.State, result.Streams.Stderr())
+	}
+
+	return nil
+}
+
+//Probe runs the health check once, and returns an error if the check fails
+func (h *HealthCheck) Probe() error {
+	switch {
+	case len(h.TCP) != 0:
+		return h.dial("tcp", h.TCP)
+	case len(h.Unix) != 0:
+		return h.dial("unix", h.Unix)
+	case len(h.HTTP) != 0:
+		return h.get()
+	case len(h.Exec) != 0:
+		return h.exec()
+	}
+
+	return fmt.Errorf("no health probe defined")
+}
+
+//HealthHook probes the job health periodically while the job process is running. The job
+//health is published under the job ID, see Health()
+type HealthHook struct {
+	NOOPHook
+	ID    string
+	Check *HealthCheck
+
+	//Healthy is called the first time the health check passes
+	Healthy func()
+	//Unhealthy is called each time the job turns unhealthy
+	Unhealthy func()
+
+	m      sync.Mutex
+	o      sync.Once
+	busy   bool
+	gen    uint64 //incremented on exit, so a probe of a previous run is dropped
+	next   time.Duration
+	status HealthStatus
+}
+
+func (h *HealthHook) Tick(delay time.Duration) {
+	h.m.Lock()
+	if h.busy || delay < h.next {
+		h.m.Unlock()
+		return
+	}
+
+	h.busy = true
+	h.next = delay + h.Check.interval()
+	gen := h.gen
+	h.m.Unlock()
+
+	err := h.Check.Probe()
+
+	h.m.Lock()
+	defer h.m.Unlock()
+	if gen != h.gen {
+		//the job exited while probing
+		return
+	}
+
+	h.busy = false
+
+	if h.status.Status == "" || h.status.Status == HealthStopped {
+		h.status.Status = HealthStarting
+	}
+
+	h.status.LastCheck = int64(time.Duration(time.Now().UnixNano()) / time.Millisecond)
+	if err == nil {
+		h.status.Status = HealthHealthy
+		h.status.Failures = 0
+		h.status.Error = ""
+		setHealth(h.ID, h.status)
+
+		if h.Healthy != nil {
+			go h.o.Do(h.Healthy)
+		}
+		return
+	}
+
+	h.status.Error = err.Error()
+	if delay < time.Duration(h.Check.StartPeriod)*time.Second {
+		setHealth(h.ID, h.status)
+		return
+	}
+
+	h.status.Failures++
+	if h.status.Failures >= h.Check.retries() && h.status.Status != HealthUnhealthy {
+		log.Warningf("job %s is unhealthy: %s", h.ID, err)
+		h.status.Status = HealthUnhealthy
+		if h.Unhealthy != nil {
+			go h.Unhealthy()
+		}
+	}
+
+	setHealth(h.ID, h.status)
+}
+
+func (h *HealthHook) Exit(state JobState) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.gen++
+	h.busy = false
+	h.next = 0
+	h.status.Status = HealthStopped
+	h.status.Failures = 0
+	setHealth(h.ID, h.status)
+}
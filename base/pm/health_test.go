@@ -0,0 +1,134 @@
+package pm
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthCheckValidate(t *testing.T) {
+	check := HealthCheck{}
+	if ok := assert.Error(t, check.Validate()); !ok {
+		t.Error()
+	}
+
+	check = HealthCheck{TCP: "127.0.0.1:80", HTTP: "http://127.0.0.1/"}
+	if ok := assert.Error(t, check.Validate()); !ok {
+		t.Error()
+	}
+
+	check = HealthCheck{TCP: "127.0.0.1:80"}
+	if ok := assert.NoError(t, check.Validate()); !ok {
+		t.Error()
+	}
+}
+
+func TestHealthHook(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	healthy := make(chan struct{})
+	unhealthy := make(chan struct{})
+
+	hook := HealthHook{
+		ID: "health-test",
+		Check: &HealthCheck{
+			TCP:      listener.Addr().String(),
+			Interval: 1,
+			Retries:  2,
+		},
+		Healthy: func() {
+			close(healthy)
+		},
+		Unhealthy: func() {
+			close(unhealthy)
+		},
+	}
+
+	defer removeHealth(hook.ID)
+
+	hook.Tick(1 * time.Second)
+	select {
+	case <-healthy:
+	case <-time.After(1 * time.Second):
+		t.Fatal("job was not reported healthy")
+	}
+
+	if ok := assert.Equal(t, HealthHealthy, Health()[hook.ID].Status); !ok {
+		t.Error()
+	}
+
+	listener.Close()
+
+	//probe is not due yet
+	hook.Tick(1500 * time.Millisecond)
+	if ok := assert.Equal(t, 0, Health()[hook.ID].Failures); !ok {
+		t.Error()
+	}
+
+	hook.Tick(2 * time.Second)
+	hook.Tick(3 * time.Second)
+
+	select {
+	case <-unhealthy:
+	case <-time.After(1 * time.Second):
+		t.Fatal("job was not reported unhealthy")
+	}
+
+	status := Health()[hook.ID]
+	if ok := assert.Equal(t, HealthUnhealthy, status.Status); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, 2, status.Failures); !ok {
+		t.Error()
+	}
+
+	hook.Exit(StateKilled)
+	if ok := assert.Equal(t, HealthStopped, Health()[hook.ID].Status); !ok {
+		t.Error()
+	}
+}
+
+func TestHealthHookExitWhileProbing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	hook := HealthHook{
+		ID: "health-exit-test",
+		Check: &HealthCheck{
+			HTTP:     server.URL,
+			Interval: 1,
+		},
+	}
+
+	defer removeHealth(hook.ID)
+
+	done := make(chan struct{})
+	go func() {
+		hook.Tick(1 * time.Second)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	hook.Exit(StateKilled)
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("probe did not finish")
+	}
+
+	//the probe finished after the exit, it must not override the stopped status
+	if ok := assert.Equal(t, HealthStopped, Health()[hook.ID].Status); !ok {
+		t.Error()
+	}
+}
@@ -0,0 +1,77 @@
+package pm
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+//Input is a chunk of input to an interactive job
+type Input struct {
+	//Data to write to the job stdin (base64 encoded in json)
+	Data []byte `json:"data,omitempty"`
+	//EOF closes the job stdin (in tty mode an end of transmission is sent)
+	EOF bool `json:"eof,omitempty"`
+	//Rows and Cols resizes the job terminal if both are set (tty mode only)
+	Rows uint16 `json:"rows,omitempty"`
+	Cols uint16 `json:"cols,omitempty"`
+}
+
+//IsInteractive returns true if the command runs an interactive process (interactive or tty set)
+func IsInteractive(cmd *Command) bool {
+	if cmd.Arguments == nil {
+		return false
+	}
+
+	var args struct {
+		Interactive bool `json:"interactive"`
+		Tty         bool `json:"tty"`
+	}
+
+	if err := json.Unmarshal(*cmd.Arguments, &args); err != nil {
+		return false
+	}
+
+	return args.Interactive || args.Tty
+}
+
+//Feed feeds the input to the running interactive job with the given id
+func Feed(id string, input *Input) error {
+	job, ok := JobOf(id)
+	if !ok {
+		return NotFoundError(fmt.Errorf("job '%s' not found", id))
+	}
+
+	ps, ok := job.Process().(Interactive)
+	if !ok {
+		return BadRequestError(fmt.Errorf("job '%s' is not interactive", id))
+	}
+
+	if len(input.Data) != 0 {
+		if _, err := ps.Write(input.Data); err != nil {
+			return InternalError(err)
+		}
+	}
+
+	if input.Rows != 0 && input.Cols != 0 {
+		if err := ps.Resize(input.Rows, input.Cols); err != nil {
+			return BadRequestError(err)
+		}
+	}
+
+	if input.EOF {
+		if err := ps.CloseInput(); err != nil {
+			return InternalError(err)
+		}
+	}
+
+	return nil
+}
+
+//attach notifies the input handlers that an interactive job has started
+func attach(id string, done <-chan struct{}) {
+	for _, handler := range handlers {
+		if handler, ok := handler.(InputHandler); ok {
+			go handler.Attach(id, done)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import "C"
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -27,11 +28,22 @@ type Job interface {
 	Wait() *JobResult
 	WaitContext(ctx context.Context) *JobResult
 	StartTime() int64
-	Subscribe(stream.MessageHandler)
+	//Subscribe forwards the job messages to the handler (starting with the messages backlog) until
+	//the returned unsubscribe function is called
+	Subscribe(stream.MessageHandler) func()
 	Unschedule()
+	NextRun() int64
+	Restarts() int
 }
 
 type jobImb struct {
+	//next scheduled run time in nano seconds, 0 if not waiting. The 64bit fields must be first
+	//for atomic access on 32bit platforms
+	next int64
+	//number of failed runs, or restarts if a restart policy is set (for MaxRestart). It's read
+	//by job.list while the job runs, so it's only accessed atomically
+	runs int64
+
 	command        *Command
 	factory        ProcessFactory
 	signal         chan syscall.Signal
@@ -40,9 +52,11 @@ type jobImb struct {
 
 	process     Process
 	hooks       []RunnerHook
+	hooksM      sync.RWMutex
 	startTime   time.Time
-	backlog     *stream.Buffer
-	subscribers []stream.MessageHandler
+	backlog      *stream.Buffer
+	subscribers  []*subscriber
+	subscribersM sync.RWMutex
 
 	o      sync.Once
 	result *JobResult
@@ -53,6 +67,11 @@ type jobImb struct {
 	running     int32
 
 	backoff BackOff
+
+	schedule *CronSchedule
+
+	adopt int           //pid of a running process to adopt on first run (restored from journal)
+	delay time.Duration //delay before first run (restored from journal)
 }
 
 /*
@@ -90,6 +109,10 @@ func newJob(command *Command, factory ProcessFactory, hooks ...RunnerHook) *jobI
 		},
 	}
 
+	if command.RestartPolicy != nil {
+		command.RestartPolicy.apply(&job.backoff)
+	}
+
 	job.wg.Add(1)
 	return job
 }
@@ -152,7 +175,27 @@ func (r *jobImb) setUnprivileged() {
 	}
 }
 
-func (r *jobImb) Subscribe(listener stream.MessageHandler) {
+//hook adds hooks to the job, they get the job events from now on
+func (r *jobImb) hook(hooks ...RunnerHook) {
+	r.hooksM.Lock()
+	defer r.hooksM.Unlock()
+
+	r.hooks = append(r.hooks, hooks...)
+}
+
+func (r *jobImb) runnerHooks() []RunnerHook {
+	r.hooksM.RLock()
+	defer r.hooksM.RUnlock()
+
+	return r.hooks
+}
+
+//subscriber wraps a subscribed handler, so it can be found on unsubscribe
+type subscriber struct {
+	handler stream.MessageHandler
+}
+
+func (r *jobImb) Subscribe(listener stream.MessageHandler) func() {
 	//TODO: a race condition might happen here because, while we send the backlog
 	//a new message might arrive and missed by this listener
 	for l := r.backlog.Front(); l != nil; l = l.Next() {
@@ -161,7 +204,27 @@ func (r *jobImb) Subscribe(listener stream.MessageHandler) {
 			listener(v)
 		}
 	}
-	r.subscribers = append(r.subscribers, listener)
+
+	sub := &subscriber{handler: listener}
+	r.subscribersM.Lock()
+	r.subscribers = append(r.subscribers, sub)
+	r.subscribersM.Unlock()
+
+	return func() {
+		r.unsubscribe(sub)
+	}
+}
+
+func (r *jobImb) unsubscribe(sub *subscriber) {
+	r.subscribersM.Lock()
+	defer r.subscribersM.Unlock()
+
+	for i, s := range r.subscribers {
+		if s == sub {
+			r.subscribers = append(r.subscribers[:i:i], r.subscribers[i+1:]...)
+			return
+		}
+	}
 }
 
 func (r *jobImb) callback(msg *stream.Message) {
@@ -174,8 +237,13 @@ func (r *jobImb) callback(msg *stream.Message) {
 
 	//check subscribers here.
 	msgCallback(r.command, msg)
-	for _, sub := range r.subscribers {
-		sub(msg)
+
+	r.subscribersM.RLock()
+	subscribers := r.subscribers
+	r.subscribersM.RUnlock()
+
+	for _, sub := range subscribers {
+		sub.handler(msg)
 	}
 }
 
@@ -196,7 +264,12 @@ func (r *jobImb) run(unprivileged bool) (jobresult *JobResult) {
 		}
 	}()
 
-	r.process = r.factory(r, r.command)
+	if r.adopt != 0 {
+		r.process = newAdoptedProcess(r, r.command, r.adopt)
+		r.adopt = 0
+	} else {
+		r.process = r.factory(r, r.command)
+	}
 
 	ps := r.process
 	runtime.LockOSThread()
@@ -243,7 +316,7 @@ loop:
 			}
 		case <-handlersTicker.C:
 			d := time.Now().Sub(r.startTime)
-			for _, hook := range r.hooks {
+			for _, hook := range r.runnerHooks() {
 				go hook.Tick(d)
 			}
 		case message := <-channel:
@@ -265,7 +338,7 @@ loop:
 				critical = message.Message
 			}
 
-			for _, hook := range r.hooks {
+			for _, hook := range r.runnerHooks() {
 				hook.Message(message)
 			}
 
@@ -291,6 +364,10 @@ loop:
 		//noop.
 	}
 
+	if ps, ok := ps.(Limited); ok && jobresult.State != StateSuccess && ps.OOMKilled() {
+		jobresult.State = StateOOM
+	}
+
 	if result != nil {
 		jobresult.Level = result.Meta.Level()
 		jobresult.Data = result.Message
@@ -306,10 +383,104 @@ loop:
 	return jobresult
 }
 
+//validate checks that the command scheduling options are valid and can be used together
+func (r *jobImb) validate() error {
+	cmd := r.command
+	if cmd.Schedule != nil {
+		if cmd.RecurringPeriod > 0 {
+			return fmt.Errorf("recurring_period and schedule can't be used together")
+		}
+
+		if r.schedule == nil {
+			if err := r.parseSchedule(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if cmd.Limits != nil {
+		if err := cmd.Limits.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if cmd.Webhook != nil {
+		if err := cmd.Webhook.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if cmd.RestartPolicy != nil {
+		if cmd.RecurringPeriod > 0 || cmd.Schedule != nil {
+			return fmt.Errorf("restart_policy can't be used with recurring_period or schedule")
+		}
+
+		if err := cmd.RestartPolicy.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//parseSchedule parses the command schedule, it's called when the job is submitted so an
+//invalid schedule is rejected right away
+func (r *jobImb) parseSchedule() (err error) {
+	if r.command.Schedule != nil {
+		r.schedule, err = r.command.Schedule.Parse()
+	}
+
+	return
+}
+
+//park waits for the next scheduled run like wait, but it gives up the job named queue slot and
+//MaxJobs slot while waiting, so a scheduled job doesn't block the other jobs between its runs
+func (r *jobImb) park(d time.Duration) bool {
+	queue.Notify(r)
+
+	jobsCond.L.Lock()
+	parked++
+	jobsCond.L.Unlock()
+	jobsCond.Broadcast()
+
+	ok := r.wait(d)
+
+	jobsCond.L.Lock()
+	parked--
+	for ok && active() > MaxJobs {
+		jobsCond.Wait()
+	}
+	jobsCond.L.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	select {
+	case <-queue.Acquire(r):
+		return true
+	case <-r.unschedule:
+		return false
+	}
+}
+
+//wait sleeps for the given duration unless the job is unscheduled first, it returns false
+//if the job was unscheduled
+func (r *jobImb) wait(d time.Duration) bool {
+	atomic.StoreInt64(&r.next, time.Now().Add(d).UnixNano())
+	defer atomic.StoreInt64(&r.next, 0)
+
+	select {
+	case <-time.After(d):
+		return true
+	case <-r.unschedule:
+		return false
+	}
+}
+
 func (r *jobImb) start(unprivileged bool) {
 	atomic.StoreInt32(&r.running, 1)
 
-	runs := 0
 	var result *JobResult
 	defer func() {
 		atomic.StoreInt32(&r.running, 0)
@@ -319,23 +490,105 @@ func (r *jobImb) start(unprivileged bool) {
 		if result != nil {
 			r.result = result
 			callback(r.command, result)
+			journalAppend(&JournalEntry{
+				Type:   JournalResult,
+				ID:     r.command.ID,
+				Result: result,
+			})
+		}
 
+		//clean up before releasing the waiters, so once a job is waited, its
+		//ID can be reused
+		cleanUp(r)
+
+		if result != nil {
 			r.o.Do(func() {
 				r.wg.Done()
 			})
 		}
-
-		cleanUp(r)
 	}()
 
+	//unscheduled before the first run
+	unscheduled := func() {
+		result = NewJobResult(r.command)
+		result.State = StateKilled
+	}
+
+	if err := r.validate(); err != nil {
+		result = NewJobResult(r.command)
+		result.State = StateError
+		result.Code = http.StatusBadRequest
+		result.Data = err.Error()
+		return
+	}
+
+	schedule := r.schedule
+	policy := r.command.RestartPolicy
+
+	if r.delay > 0 {
+		log.Debugf("delaying '%s' for %s", r.command, r.delay)
+		if !r.wait(r.delay) {
+			unscheduled()
+			return
+		}
+	}
+
+	retry := false
 loop:
 	for {
+		//an adopted process is already running, it's monitored before waiting for the next schedule
+		if schedule != nil && !retry && r.adopt == 0 {
+			delay, err := schedule.Delay(time.Now())
+			if err != nil {
+				result = NewJobResult(r.command)
+				result.State = StateError
+				result.Data = err.Error()
+				return
+			}
+
+			log.Debugf("scheduled '%s' in %s", r.command, delay)
+			if !r.park(delay) {
+				if result == nil {
+					unscheduled()
+				}
+				return
+			}
+		}
+
+		journalAppend(&JournalEntry{
+			Type: JournalStart,
+			ID:   r.command.ID,
+		})
+
 		result = r.run(unprivileged)
 
-		for _, hook := range r.hooks {
+		for _, hook := range r.runnerHooks() {
 			hook.Exit(result.State)
 		}
 
+		var restartIn time.Duration
+		restart := false
+		if policy != nil {
+			if policy.Reset(time.Since(r.startTime)) {
+				r.setRuns(0)
+				r.backoff.Reset()
+			}
+
+			if policy.Restart(result.State) && (r.command.MaxRestart <= 0 || r.Restarts()+1 < r.command.MaxRestart) {
+				r.setRuns(r.Restarts() + 1)
+				restart = true
+				restartIn = r.backoff.Duration()
+			}
+		} else if result.State != StateSuccess {
+			r.setRuns(r.Restarts() + 1)
+		}
+
+		journalAppend(&JournalEntry{
+			Type: JournalExit,
+			ID:   r.command.ID,
+			Runs: r.Restarts(),
+		})
+
 		if r.command.Flags.Protected {
 			//immediate restart
 			delay := r.backoff.Duration()
@@ -344,14 +597,22 @@ loop:
 			continue
 		}
 
+		if restart {
+			log.Debugf("Restarting '%s' (%s), restarts: %d in %s", r.command, policy.Policy, r.Restarts(), restartIn)
+			if !r.wait(restartIn) {
+				break loop
+			}
+			continue
+		}
+
 		restarting := false
-		var restartIn time.Duration
 
-		if result.State != StateSuccess && r.command.MaxRestart > 0 {
-			runs++
-			if runs < r.command.MaxRestart {
-				log.Debugf("Restarting '%s' due to abnormal exit status, trials: %d/%d", r.command, runs+1, r.command.MaxRestart)
+		retry = false
+		if policy == nil && result.State != StateSuccess && r.command.MaxRestart > 0 {
+			if r.Restarts() < r.command.MaxRestart {
+				log.Debugf("Restarting '%s' due to abnormal exit status, trials: %d/%d", r.command, r.Restarts()+1, r.command.MaxRestart)
 				restarting = true
+				retry = true
 				restartIn = 1 * time.Second
 			}
 		}
@@ -363,12 +624,10 @@ loop:
 
 		if restarting {
 			log.Debugf("recurring '%s' in %s", r.command, restartIn)
-			select {
-			case <-time.After(restartIn):
-			case <-r.unschedule:
+			if !r.wait(restartIn) {
 				break loop
 			}
-		} else {
+		} else if schedule == nil {
 			break
 		}
 	}
@@ -438,7 +697,9 @@ func (r *jobImb) RegisterPID(g GetPID) (int, error) {
 		return 0, err
 	}
 
-	for _, hook := range r.hooks {
+	journalPID(r.command, pid)
+
+	for _, hook := range r.runnerHooks() {
 		go hook.PID(pid)
 	}
 
@@ -452,3 +713,19 @@ func (r *jobImb) WaitPID(pid int) syscall.WaitStatus {
 func (r *jobImb) StartTime() int64 {
 	return int64(time.Duration(r.startTime.UnixNano()) / time.Millisecond)
 }
+
+//NextRun returns the time (in milliseconds) of the next scheduled run, or 0 if the job
+//is not waiting for a run
+func (r *jobImb) NextRun() int64 {
+	next := atomic.LoadInt64(&r.next)
+	return int64(time.Duration(next) / time.Millisecond)
+}
+
+func (r *jobImb) setRuns(runs int) {
+	atomic.StoreInt64(&r.runs, int64(runs))
+}
+
+//Restarts returns how many times the job was restarted
+func (r *jobImb) Restarts() int {
+	return int(atomic.LoadInt64(&r.runs))
+}
@@ -97,6 +97,146 @@ func TestJobMaxRestart(t *testing.T) {
 	}
 }
 
+func TestJobRestartPolicyOnFailure(t *testing.T) {
+	New()
+
+	var counter int
+	var action = func(cmd *Command) (interface{}, error) {
+		counter++
+		return nil, fmt.Errorf("error")
+	}
+
+	cmd := Command{
+		MaxRestart: 3,
+		RestartPolicy: &RestartPolicy{
+			Policy:     RestartOnFailure,
+			Delay:      1,
+			Multiplier: 1,
+		},
+	}
+
+	job := newTestJob(&cmd, NewInternalProcess(action))
+
+	now := time.Now()
+	job.start(false)
+
+	result := job.Wait()
+	if ok := assert.Equal(t, StateError, result.State); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, 3, counter); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, 2, job.Restarts()); !ok {
+		t.Error()
+	}
+
+	if ok := assert.InDelta(t, float64(2*time.Second), float64(time.Since(now)), float64(500*time.Millisecond)); !ok {
+		t.Error()
+	}
+}
+
+func TestJobRestartPolicyAlways(t *testing.T) {
+	New()
+
+	var counter int
+	var action = func(cmd *Command) (interface{}, error) {
+		counter++
+		return nil, nil
+	}
+
+	cmd := Command{
+		RestartPolicy: &RestartPolicy{
+			Policy: RestartAlways,
+			Delay:  1,
+		},
+	}
+
+	job := newTestJob(&cmd, NewInternalProcess(action))
+
+	go func() {
+		time.Sleep(1500 * time.Millisecond)
+		if ok := assert.NotZero(t, job.NextRun()); !ok {
+			t.Error()
+		}
+		job.Unschedule()
+	}()
+
+	job.start(false)
+
+	result := job.Wait()
+	if ok := assert.Equal(t, StateSuccess, result.State); !ok {
+		t.Error()
+	}
+
+	//first run, then restarted after 1 second, then unscheduled while waiting for the next restart
+	if ok := assert.Equal(t, 2, counter); !ok {
+		t.Error()
+	}
+}
+
+func TestJobRestartPolicyNever(t *testing.T) {
+	New()
+
+	var counter int
+	var action = func(cmd *Command) (interface{}, error) {
+		counter++
+		return nil, fmt.Errorf("error")
+	}
+
+	cmd := Command{
+		MaxRestart: 3,
+		RestartPolicy: &RestartPolicy{
+			Policy: RestartNever,
+		},
+	}
+
+	job := newTestJob(&cmd, NewInternalProcess(action))
+	job.start(false)
+
+	result := job.Wait()
+	if ok := assert.Equal(t, StateError, result.State); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, 1, counter); !ok {
+		t.Error()
+	}
+}
+
+func TestJobRestartPolicyInvalid(t *testing.T) {
+	New()
+
+	cmd := Command{
+		RecurringPeriod: 10,
+		RestartPolicy: &RestartPolicy{
+			Policy: RestartAlways,
+		},
+	}
+
+	job := newTestJob(&cmd, NewInternalProcess(func(cmd *Command) (interface{}, error) {
+		return nil, nil
+	}))
+	job.start(false)
+
+	result := job.Wait()
+	if ok := assert.Equal(t, StateError, result.State); !ok {
+		t.Error()
+	}
+
+	cmd = Command{
+		RestartPolicy: &RestartPolicy{
+			Policy: "sometimes",
+		},
+	}
+
+	if ok := assert.Error(t, cmd.RestartPolicy.Validate()); !ok {
+		t.Error()
+	}
+}
+
 func TestJobMessages(t *testing.T) {
 	New()
 
@@ -151,6 +291,43 @@ func TestJobMessages(t *testing.T) {
 	}
 }
 
+func TestJobUnsubscribe(t *testing.T) {
+	New()
+
+	var action = func(ctx *Context) (interface{}, error) {
+		ctx.Log("stdout message", stream.LevelStdout)
+		return nil, nil
+	}
+
+	cmd := Command{}
+
+	job := newTestJob(&cmd, NewInternalProcessWithCtx(action))
+
+	var kept, removed []*stream.Message
+	job.Subscribe(func(msg *stream.Message) {
+		kept = append(kept, msg)
+	})
+
+	unsubscribe := job.Subscribe(func(msg *stream.Message) {
+		removed = append(removed, msg)
+	})
+
+	unsubscribe()
+	//unsubscribe can be called more than once
+	unsubscribe()
+
+	job.start(false)
+	job.Wait()
+
+	if ok := assert.NotEmpty(t, kept); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Empty(t, removed); !ok {
+		t.Error()
+	}
+}
+
 func TestJobTimeout(t *testing.T) {
 	New()
 
@@ -0,0 +1,419 @@
+package pm
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+
+	psutils "github.com/shirou/gopsutil/process"
+)
+
+const (
+	//JournalSubmit a command was submitted to the process manager
+	JournalSubmit = "submit"
+	//JournalStart a job run has started
+	JournalStart = "start"
+	//JournalPID a job run got a process id
+	JournalPID = "pid"
+	//JournalExit a job run has exited, the job may still be rescheduled
+	JournalExit = "exit"
+	//JournalResult a job has reached its terminal state
+	JournalResult = "result"
+
+	//JournalCompactThreshold number of finished jobs after which the journal is compacted
+	JournalCompactThreshold = 1000
+)
+
+//JournalEntry is a single record in the job journal
+type JournalEntry struct {
+	Type    string     `json:"type"`
+	ID      string     `json:"id"`
+	Time    int64      `json:"time"`
+	Command *Command   `json:"command,omitempty"`
+	Flags   *JobFlags  `json:"flags,omitempty"`
+	PID     int        `json:"pid,omitempty"`
+	Created int64      `json:"created,omitempty"` //process creation time, to detect pid reuse
+	Runs    int        `json:"runs,omitempty"`
+	Result  *JobResult `json:"result,omitempty"`
+}
+
+//Journal is a write-ahead log of the process manager jobs. It is used to restore
+//the jobs state after core0 restarts.
+type Journal interface {
+	//Append appends an entry to the journal
+	Append(entry *JournalEntry) error
+	//Entries returns all the entries in the journal in the order they were appended
+	Entries() ([]*JournalEntry, error)
+	//Rewrite replaces the full journal content with the given entries
+	Rewrite(entries []*JournalEntry) error
+}
+
+//journalState is the folded state of a single job from the journal entries
+type journalState struct {
+	command  *Command
+	flags    JobFlags
+	running  bool
+	pid      int
+	created  int64
+	runs     int
+	started  int64
+	exited   int64
+	finished bool
+}
+
+var (
+	journal    Journal
+	journalM   sync.Mutex
+	journalFin int
+
+	//replaying the unfinished journaled jobs that are not restored yet
+	replaying []*journalState
+	replayM   sync.Mutex
+	replayO   sync.Once
+)
+
+//SetJournal sets the job journal. Must be called before Replay.
+func SetJournal(j Journal) {
+	journal = j
+}
+
+func journalAppend(entry *JournalEntry) {
+	if journal == nil {
+		return
+	}
+
+	journalM.Lock()
+	defer journalM.Unlock()
+
+	entry.Time = time.Now().Unix()
+	if err := journal.Append(entry); err != nil {
+		log.Errorf("failed to write journal entry for job %s: %s", entry.ID, err)
+		return
+	}
+
+	if entry.Type != JournalResult {
+		return
+	}
+
+	journalFin++
+	if journalFin < JournalCompactThreshold {
+		return
+	}
+
+	if err := journalCompact(); err != nil {
+		log.Errorf("failed to compact jobs journal: %s", err)
+	}
+}
+
+//journalCompact drops all finished jobs from the journal, must be called with journalM held
+func journalCompact() error {
+	entries, err := journal.Entries()
+	if err != nil {
+		return err
+	}
+
+	ids, states := journalFold(entries)
+	journalFin = 0
+
+	return journal.Rewrite(journalEntries(ids, states))
+}
+
+//journalFold folds the journal entries into a state per job, it also returns the job ids
+//in the order they were submitted
+func journalFold(entries []*JournalEntry) ([]string, map[string]*journalState) {
+	var ids []string
+	states := make(map[string]*journalState)
+
+	for _, entry := range entries {
+		state, ok := states[entry.ID]
+		if entry.Type == JournalSubmit {
+			if entry.Command == nil {
+				continue
+			}
+
+			if !ok {
+				ids = append(ids, entry.ID)
+			}
+
+			state = &journalState{command: entry.Command}
+			if entry.Flags != nil {
+				state.flags = *entry.Flags
+			}
+			states[entry.ID] = state
+			continue
+		}
+
+		if !ok {
+			//entry of a job that was never submitted (or already compacted)
+			continue
+		}
+
+		switch entry.Type {
+		case JournalStart:
+			state.running = true
+			state.started = entry.Time
+		case JournalPID:
+			state.pid = entry.PID
+			state.created = entry.Created
+		case JournalExit:
+			state.running = false
+			state.started = 0
+			state.pid = 0
+			state.created = 0
+			state.runs = entry.Runs
+			state.exited = entry.Time
+		case JournalResult:
+			state.finished = true
+		}
+	}
+
+	return ids, states
+}
+
+//journalEntries builds the minimal set of entries that reproduces the state of the unfinished jobs
+func journalEntries(ids []string, states map[string]*journalState) []*JournalEntry {
+	var entries []*JournalEntry
+	for _, id := range ids {
+		state := states[id]
+		if state.finished {
+			continue
+		}
+
+		flags := state.flags
+		entries = append(entries, &JournalEntry{
+			Type:    JournalSubmit,
+			ID:      id,
+			Command: state.command,
+			Flags:   &flags,
+		})
+
+		if state.exited != 0 {
+			entries = append(entries, &JournalEntry{
+				Type: JournalExit,
+				ID:   id,
+				Time: state.exited,
+				Runs: state.runs,
+			})
+		}
+
+		if !state.running {
+			continue
+		}
+
+		entries = append(entries, &JournalEntry{
+			Type: JournalStart,
+			ID:   id,
+			Time: state.started,
+		})
+
+		if state.pid != 0 {
+			entries = append(entries, &JournalEntry{
+				Type:    JournalPID,
+				ID:      id,
+				PID:     state.pid,
+				Created: state.created,
+			})
+		}
+	}
+
+	return entries
+}
+
+func journalSubmitEntry(cmd *Command) *JournalEntry {
+	flags := cmd.Flags
+	return &JournalEntry{
+		Type:    JournalSubmit,
+		ID:      cmd.ID,
+		Command: cmd,
+		Flags:   &flags,
+	}
+}
+
+func journalPID(cmd *Command, pid int) {
+	if journal == nil {
+		return
+	}
+
+	entry := &JournalEntry{
+		Type: JournalPID,
+		ID:   cmd.ID,
+		PID:  pid,
+	}
+
+	if ps, err := psutils.NewProcess(int32(pid)); err == nil {
+		entry.Created, _ = ps.CreateTime()
+	}
+
+	journalAppend(entry)
+}
+
+//alive checks if the process with pid is still the same process that was journaled
+func (s *journalState) alive() bool {
+	if s.pid <= 0 {
+		return false
+	}
+
+	if err := syscall.Kill(s.pid, 0); err != nil {
+		return false
+	}
+
+	ps, err := psutils.NewProcess(int32(s.pid))
+	if err != nil {
+		return false
+	}
+
+	created, err := ps.CreateTime()
+	if err != nil {
+		return false
+	}
+
+	return s.created == 0 || created == s.created
+}
+
+//lost reports a job that can't be restored as killed, so clients waiting on it get a result
+func (s *journalState) lost(reason string) {
+	log.Warningf("job %s can not be restored: %s", s.command, reason)
+	s.finished = true
+
+	result := NewJobResult(s.command)
+	result.State = StateKilled
+	result.Critical = fmt.Sprintf("job lost on core0 restart: %s", reason)
+	result.StartTime = s.started * 1000
+
+	callback(s.command, result)
+	journalAppend(&JournalEntry{
+		Type:   JournalResult,
+		ID:     s.command.ID,
+		Result: result,
+	})
+}
+
+//restore builds a job from the journaled state, or nil if the job can't be restored
+func (s *journalState) restore() *jobImb {
+	cmd := s.command
+	cmd.Flags = s.flags
+
+	factory := GetProcessFactory(cmd)
+	if factory == nil {
+		s.lost("unknown command")
+		return nil
+	}
+
+	job := newJob(cmd, factory)
+	if err := job.parseSchedule(); err != nil {
+		s.lost(err.Error())
+		return nil
+	}
+
+	job.setRuns(s.runs)
+
+	if !s.running {
+		if s.exited != 0 && cmd.RecurringPeriod > 0 {
+			//waiting for the next recurring run
+			next := time.Unix(s.exited, 0).Add(time.Duration(cmd.RecurringPeriod) * time.Second)
+			job.delay = time.Until(next)
+		}
+
+		return job
+	}
+
+	if s.alive() {
+		log.Infof("re-adopting job %s with pid %d", cmd, s.pid)
+		job.adopt = s.pid
+		return job
+	}
+
+	//the job was running but we lost it while core0 was down, act as if it exited abnormally
+	if cmd.Flags.Protected || cmd.RecurringPeriod > 0 || cmd.Schedule != nil {
+		return job
+	}
+
+	if policy := cmd.RestartPolicy; policy != nil {
+		if policy.Restart(StateError) && (cmd.MaxRestart <= 0 || job.Restarts()+1 < cmd.MaxRestart) {
+			job.setRuns(job.Restarts() + 1)
+			return job
+		}
+	} else if cmd.MaxRestart > 0 && job.Restarts()+1 < cmd.MaxRestart {
+		job.setRuns(job.Restarts() + 1)
+		return job
+	}
+
+	s.lost("process is gone")
+	return nil
+}
+
+//load reads the journal, and keeps the unfinished jobs to be restored by Replay. The finished jobs
+//are dropped from the journal
+func load() {
+	journalM.Lock()
+	defer journalM.Unlock()
+
+	entries, err := journal.Entries()
+	if err != nil {
+		log.Errorf("failed to read jobs journal: %s", err)
+		return
+	}
+
+	ids, states := journalFold(entries)
+	for _, id := range ids {
+		if state := states[id]; !state.finished {
+			replaying = append(replaying, state)
+		}
+	}
+
+	if err := journal.Rewrite(journalEntries(ids, states)); err != nil {
+		log.Errorf("failed to compact jobs journal: %s", err)
+	}
+}
+
+//Replay restores the journaled jobs of the registered commands. It must be called after Start, and
+//after the handlers the jobs need (like the limits handler) are added. The subsystems register their
+//commands at different stages of the boot, so Replay can be called once per stage, the jobs of the
+//commands that are not registered yet are kept for the next call. ReplayDone must be called once all
+//the commands are registered.
+func Replay() {
+	replay(false)
+}
+
+//ReplayDone restores the journaled jobs that are left, the jobs of unknown commands are lost
+func ReplayDone() {
+	replay(true)
+}
+
+func replay(done bool) {
+	if journal == nil {
+		return
+	}
+
+	replayO.Do(load)
+
+	replayM.Lock()
+	var restored []*jobImb
+	var pending []*journalState
+	var adopted bool
+	for _, state := range replaying {
+		if !done && GetProcessFactory(state.command) == nil {
+			pending = append(pending, state)
+			continue
+		}
+
+		if job := state.restore(); job != nil {
+			restored = append(restored, job)
+			adopted = adopted || job.adopt != 0
+		}
+	}
+	replaying = pending
+	replayM.Unlock()
+
+	for _, job := range restored {
+		if err := push(job, false); err != nil {
+			log.Errorf("failed to restore job %s: %s", job.command, err)
+		}
+	}
+
+	if adopted {
+		//adopted processes might have exited before we started listening to SIGCHLD
+		syscall.Kill(syscall.Getpid(), syscall.SIGCHLD)
+	}
+}
@@ -0,0 +1,188 @@
+package pm
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	j, err := NewFileJournal(path.Join(dir, "journal"))
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	cmd := &Command{ID: "job-1", Command: CommandSystem}
+	j.Append(&JournalEntry{Type: JournalSubmit, ID: cmd.ID, Command: cmd})
+	j.Append(&JournalEntry{Type: JournalStart, ID: cmd.ID})
+	j.Append(&JournalEntry{Type: JournalPID, ID: cmd.ID, PID: 100})
+
+	entries, err := j.Entries()
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Len(t, entries, 3); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, 100, entries[2].PID); !ok {
+		t.Error()
+	}
+
+	if ok := assert.NoError(t, j.Rewrite(entries[:1])); !ok {
+		t.Fatal()
+	}
+
+	//appending after a rewrite must go to the new file
+	j.Append(&JournalEntry{Type: JournalStart, ID: cmd.ID})
+
+	entries, err = j.Entries()
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Len(t, entries, 2); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, "job-1", entries[0].Command.ID); !ok {
+		t.Error()
+	}
+}
+
+func TestJournalFold(t *testing.T) {
+	entries := []*JournalEntry{
+		{Type: JournalSubmit, ID: "running", Command: &Command{ID: "running"}},
+		{Type: JournalSubmit, ID: "done", Command: &Command{ID: "done"}},
+		{Type: JournalStart, ID: "running", Time: 10},
+		{Type: JournalPID, ID: "running", PID: 100, Created: 5},
+		{Type: JournalStart, ID: "done", Time: 11},
+		{Type: JournalExit, ID: "done", Runs: 1, Time: 12},
+		{Type: JournalResult, ID: "done"},
+		{Type: JournalSubmit, ID: "recurring", Command: &Command{ID: "recurring", RecurringPeriod: 60}},
+		{Type: JournalStart, ID: "recurring", Time: 13},
+		{Type: JournalExit, ID: "recurring", Time: 14},
+		{Type: JournalStart, ID: "unknown"},
+	}
+
+	ids, states := journalFold(entries)
+	if ok := assert.Equal(t, []string{"running", "done", "recurring"}, ids); !ok {
+		t.Fatal()
+	}
+
+	running := states["running"]
+	if ok := assert.True(t, running.running); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, 100, running.pid); !ok {
+		t.Error()
+	}
+
+	if ok := assert.True(t, states["done"].finished); !ok {
+		t.Error()
+	}
+
+	recurring := states["recurring"]
+	if ok := assert.False(t, recurring.running); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, int64(14), recurring.exited); !ok {
+		t.Error()
+	}
+
+	//compacting the state then folding it again must give the same state
+	compacted := journalEntries(ids, states)
+	cids, cstates := journalFold(compacted)
+
+	if ok := assert.Equal(t, []string{"running", "recurring"}, cids); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, running, cstates["running"]); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, recurring, cstates["recurring"]); !ok {
+		t.Error()
+	}
+}
+
+func TestJournalRestoreRecurring(t *testing.T) {
+	state := &journalState{
+		command: &Command{ID: "recurring", Command: CommandSystem, RecurringPeriod: 60},
+		exited:  time.Now().Add(-20 * time.Second).Unix(),
+	}
+
+	job := state.restore()
+	if ok := assert.NotNil(t, job); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.InDelta(t, float64(40*time.Second), float64(job.delay), float64(2*time.Second)); !ok {
+		t.Error()
+	}
+}
+
+func TestJournalRestoreRunningScheduled(t *testing.T) {
+	New()
+
+	sleep := exec.Command("sleep", "10")
+	if err := sleep.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer sleep.Process.Kill()
+
+	state := &journalState{
+		command: &Command{ID: "scheduled", Command: CommandSystem, Schedule: &Schedule{Cron: "0 3 * * *"}},
+		running: true,
+		pid:     sleep.Process.Pid,
+	}
+
+	job := state.restore()
+	if ok := assert.NotNil(t, job); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, sleep.Process.Pid, job.adopt); !ok {
+		t.Fatal()
+	}
+
+	var table TestingPIDTable
+	job.registerPID = table.RegisterPID
+	job.waitPID = table.WaitPID
+
+	exited := make(chan bool)
+	job.hook(&ExitHook{
+		Action: func(s bool) {
+			exited <- s
+		},
+	})
+
+	go job.start(false)
+	defer job.Unschedule()
+
+	//the adopted process is monitored right away, not after the next schedule
+	sleep.Process.Kill()
+
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("adopted process exit was not monitored")
+	}
+}
@@ -0,0 +1,135 @@
+package pm
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+//limitedExec marks a process started by the process manager to wait for its limits, the process is
+//a copy of the current binary that blocks on the sync pipe (fd 3) until the limits are applied, and
+//then executes the job command with the same pid
+const limitedExec = "__pm_limited_exec__"
+
+func init() {
+	if len(os.Args) < 3 || os.Args[1] != limitedExec {
+		return
+	}
+
+	//the parent writes a single byte once the process is in its groups, it closes the
+	//pipe without writing if the limits failed
+	sync := os.NewFile(3, "sync")
+	var ready [1]byte
+	if n, _ := sync.Read(ready[:]); n != 1 {
+		os.Exit(1)
+	}
+	sync.Close()
+
+	err := syscall.Exec(os.Args[2], os.Args[2:], os.Environ())
+	fmt.Fprintf(os.Stderr, "failed to execute '%s': %s\n", os.Args[2], err)
+	os.Exit(127)
+}
+
+//BlkioThrottle block io throttle of a device, a zero value is not throttled
+type BlkioThrottle struct {
+	//Device device path (for example /dev/sda) or major:minor numbers (for example 8:0)
+	Device    string `json:"device"`
+	ReadBps   uint64 `json:"read_bps,omitempty"`
+	WriteBps  uint64 `json:"write_bps,omitempty"`
+	ReadIOps  uint64 `json:"read_iops,omitempty"`
+	WriteIOps uint64 `json:"write_iops,omitempty"`
+}
+
+//Limits defines the resources limits of a job process. Limits only apply to commands that
+//spawn a process (core.system and the extensions like bash), and containers
+type Limits struct {
+	//Memory max memory in bytes
+	Memory int64 `json:"memory,omitempty"`
+	//Swap max swap in bytes on top of memory, can only be set with memory
+	Swap int64 `json:"swap,omitempty"`
+	//CPUShares relative cpu weight of the job (default is 1024)
+	CPUShares int `json:"cpu_shares,omitempty"`
+	//CPUQuota max cpu usage in percent of a single cpu (200 means 2 full cpus)
+	CPUQuota int `json:"cpu_quota,omitempty"`
+	//CPUSet cpus the job can run on (for example 0-2,4)
+	CPUSet string `json:"cpuset,omitempty"`
+	//BlkioWeight relative block io weight of the job (10 to 1000)
+	BlkioWeight int `json:"blkio_weight,omitempty"`
+	//BlkioThrottle per device bandwidth and iops throttles
+	BlkioThrottle []BlkioThrottle `json:"blkio_throttle,omitempty"`
+	//Pids max number of processes (and threads)
+	Pids int `json:"pids,omitempty"`
+}
+
+//Validate checks the limits values
+func (l *Limits) Validate() error {
+	if l.Memory < 0 || l.Swap < 0 || l.CPUShares < 0 || l.CPUQuota < 0 || l.Pids < 0 {
+		return fmt.Errorf("limits memory, swap, cpu_shares, cpu_quota and pids can't be negative")
+	}
+
+	if l.Swap > 0 && l.Memory == 0 {
+		return fmt.Errorf("limits swap can't be set without memory")
+	}
+
+	if l.CPUShares != 0 && l.CPUShares < 2 {
+		return fmt.Errorf("limits cpu_shares must be >= 2")
+	}
+
+	if l.BlkioWeight != 0 && (l.BlkioWeight < 10 || l.BlkioWeight > 1000) {
+		return fmt.Errorf("limits blkio_weight must be in range [10, 1000]")
+	}
+
+	for _, throttle := range l.BlkioThrottle {
+		if len(throttle.Device) == 0 {
+			return fmt.Errorf("limits blkio_throttle device is required")
+		}
+	}
+
+	return nil
+}
+
+func limitHandlers() []LimitHandler {
+	var limiters []LimitHandler
+	for _, handler := range handlers {
+		if handler, ok := handler.(LimitHandler); ok {
+			limiters = append(limiters, handler)
+		}
+	}
+
+	return limiters
+}
+
+//limit applies the command limits to the process
+func limit(cmd *Command, pid int) error {
+	if cmd.Limits == nil {
+		return nil
+	}
+
+	limiters := limitHandlers()
+	if len(limiters) == 0 {
+		return fmt.Errorf("limits are not supported")
+	}
+
+	for _, limiter := range limiters {
+		if err := limiter.Limit(cmd, pid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//unlimit releases the command limits once the process has exited, it returns true if the process
+//was killed because it ran out of memory
+func unlimit(cmd *Command) bool {
+	if cmd.Limits == nil {
+		return false
+	}
+
+	oom := false
+	for _, limiter := range limitHandlers() {
+		oom = limiter.Unlimit(cmd) || oom
+	}
+
+	return oom
+}
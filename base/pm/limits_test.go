@@ -0,0 +1,169 @@
+package pm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//testLimiter fakes an oom kill of the jobs with the oom tag, and a limits failure of the
+//jobs with the fail tag
+type testLimiter struct {
+	m       sync.Mutex
+	limited map[string]int
+	//waiting is true for the jobs that were still waiting to execute their command when limited
+	waiting map[string]bool
+}
+
+func (l *testLimiter) Limit(cmd *Command, pid int) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+	cmdline, _ := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	l.waiting[cmd.ID] = strings.Contains(string(cmdline), limitedExec)
+	if len(cmd.Tags) == 1 && cmd.Tags[0] == "fail" {
+		return fmt.Errorf("limits failure")
+	}
+
+	l.limited[cmd.ID] = pid
+	return nil
+}
+
+func (l *testLimiter) Unlimit(cmd *Command) bool {
+	l.m.Lock()
+	defer l.m.Unlock()
+	delete(l.limited, cmd.ID)
+	return len(cmd.Tags) == 1 && cmd.Tags[0] == "oom"
+}
+
+var (
+	limiter     = &testLimiter{limited: make(map[string]int), waiting: make(map[string]bool)}
+	limiterOnce sync.Once
+)
+
+func TestLimitsValidate(t *testing.T) {
+	valid := []Limits{
+		{},
+		{Memory: 1024, Swap: 1024},
+		{CPUShares: 512, CPUQuota: 150, CPUSet: "0-1"},
+		{BlkioWeight: 100},
+		{BlkioThrottle: []BlkioThrottle{{Device: "/dev/sda", ReadBps: 1024}}, Pids: 100},
+	}
+
+	for _, limits := range valid {
+		if ok := assert.NoError(t, limits.Validate()); !ok {
+			t.Error()
+		}
+	}
+
+	invalid := []Limits{
+		{Memory: -1},
+		{Swap: 1024},
+		{CPUShares: 1},
+		{CPUQuota: -10},
+		{BlkioWeight: 5},
+		{BlkioWeight: 2000},
+		{BlkioThrottle: []BlkioThrottle{{ReadBps: 1024}}},
+		{Pids: -1},
+	}
+
+	for _, limits := range invalid {
+		if ok := assert.Error(t, limits.Validate()); !ok {
+			t.Error()
+		}
+	}
+}
+
+func TestJobLimits(t *testing.T) {
+	New()
+	limiterOnce.Do(func() {
+		AddHandle(limiter)
+	})
+
+	cmd := Command{
+		ID:      "limits-test",
+		Command: CommandSystem,
+		Arguments: MustArguments(
+			SystemCommandArguments{
+				Name: "sh",
+				Args: []string{"-c", "exit 1"},
+			},
+		),
+		Limits: &Limits{Memory: 1024 * 1024},
+		Tags:   Tags{"oom"},
+	}
+
+	job := newTestJob(&cmd, NewSystemProcess)
+	job.start(false)
+
+	result := job.Wait()
+	if ok := assert.Equal(t, StateOOM, result.State); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Empty(t, limiter.limited); !ok {
+		t.Error()
+	}
+
+	if ok := assert.True(t, limiter.waiting[cmd.ID]); !ok {
+		t.Error()
+	}
+
+	//a job that fails on its own
+	cmd.ID = "limits-test-error"
+	cmd.Tags = nil
+
+	job = newTestJob(&cmd, NewSystemProcess)
+	job.start(false)
+
+	result = job.Wait()
+	if ok := assert.Equal(t, StateError, result.State); !ok {
+		t.Error()
+	}
+}
+
+func TestJobLimitsFailure(t *testing.T) {
+	New()
+	limiterOnce.Do(func() {
+		AddHandle(limiter)
+	})
+
+	dir, err := ioutil.TempDir("", "limits")
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	defer os.RemoveAll(dir)
+
+	cmd := Command{
+		ID:      "limits-test-failure",
+		Command: CommandSystem,
+		Arguments: MustArguments(
+			SystemCommandArguments{
+				Name: "touch",
+				Args: []string{path.Join(dir, "ran")},
+			},
+		),
+		Limits: &Limits{Memory: 1024 * 1024},
+		Tags:   Tags{"fail"},
+	}
+
+	job := newTestJob(&cmd, NewSystemProcess)
+	job.start(false)
+
+	result := job.Wait()
+	if ok := assert.Equal(t, StateError, result.State); !ok {
+		t.Error()
+	}
+
+	//the command never runs without its limits
+	_, err = os.Stat(path.Join(dir, "ran"))
+	if ok := assert.True(t, os.IsNotExist(err)); !ok {
+		t.Error()
+	}
+}
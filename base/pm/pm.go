@@ -45,6 +45,7 @@ var (
 	jobs     map[string]*jobImb
 	jobsM    sync.RWMutex
 	jobsCond *sync.Cond
+	parked   int //scheduled jobs waiting for their next run, they don't count in MaxJobs
 
 	//needs clean up
 	handlers []Handler
@@ -92,24 +93,44 @@ func RunFactory(cmd *Command, factory ProcessFactory, hooks ...RunnerHook) (Job,
 		}
 	}
 
-	jobsM.Lock()
-	defer jobsM.Unlock()
-
-	_, exists := jobs[cmd.ID]
-	if exists {
-		return nil, DuplicateIDErr
-	}
-
 	job := newJob(cmd, factory, hooks...)
-	jobs[cmd.ID] = job
+	if err := job.parseSchedule(); err != nil {
+		return nil, BadRequestError(err)
+	}
 
-	if err := queue.Push(job); err != nil {
+	if err := push(job, true); err != nil {
 		return nil, err
 	}
 
 	return job, nil
 }
 
+//push registers the job and pushes it on the queue, if submit is set the job
+//submission is journaled
+func push(job *jobImb, submit bool) error {
+	var entry *JournalEntry
+
+	jobsM.Lock()
+	id := job.command.ID
+	if _, exists := jobs[id]; exists {
+		jobsM.Unlock()
+		return DuplicateIDErr
+	}
+
+	jobs[id] = job
+	if submit {
+		entry = journalSubmitEntry(job.command)
+	}
+	jobsM.Unlock()
+
+	//the journal is written outside the jobs lock, so submissions don't wait on the disk
+	if entry != nil {
+		journalAppend(entry)
+	}
+
+	return queue.Push(job)
+}
+
 //Run runs a command immediately (no pre-processors)
 func Run(cmd *Command, hooks ...RunnerHook) (Job, error) {
 	factory := GetProcessFactory(cmd)
@@ -120,12 +141,20 @@ func Run(cmd *Command, hooks ...RunnerHook) (Job, error) {
 	return RunFactory(cmd, factory, hooks...)
 }
 
+//active number of jobs that count in MaxJobs, must be called with jobsCond.L held
+func active() int {
+	jobsM.RLock()
+	defer jobsM.RUnlock()
+
+	return len(jobs) - parked
+}
+
 func loop() {
 	ch := queue.Channel()
 	for {
 		jobsCond.L.Lock()
 
-		for len(jobs) >= MaxJobs {
+		for active() >= MaxJobs {
 			jobsCond.Wait()
 		}
 
@@ -197,7 +226,7 @@ func waitPID(pid int) syscall.WaitStatus {
 	return <-c
 }
 
-//Start starts the process manager.
+//Start starts the process manager. The journaled jobs are restored by Replay.
 func Start() {
 	s.Do(func() {
 		go processWait()
@@ -254,6 +283,21 @@ func processArgs(args map[string]interface{}, values map[string]interface{}) {
 	}
 }
 
+//copyArgs deep copies the args maps (processArgs modifies the maps in place)
+func copyArgs(args map[string]interface{}) map[string]interface{} {
+	cp := make(map[string]interface{})
+	for key, value := range args {
+		if value, ok := value.(map[string]interface{}); ok {
+			cp[key] = copyArgs(value)
+			continue
+		}
+
+		cp[key] = value
+	}
+
+	return cp
+}
+
 // convert os Environ slice to map mainly to be used in processArgs for startup files
 func osEnvironAsMap() map[string]interface{} {
 	r := make(map[string]interface{})
@@ -300,12 +344,10 @@ func RunSlice(slice settings.StartupSlice) {
 			continue
 		}
 
-		if startup.Args == nil {
-			startup.Args = make(map[string]interface{})
-		}
-
-		processArgs(startup.Args, osEnvironAsMap())
-		processArgs(startup.Args, cmdline)
+		//process a copy, so the startup config is kept as loaded (to be compared on reload)
+		args := copyArgs(startup.Args)
+		processArgs(args, osEnvironAsMap())
+		processArgs(args, cmdline)
 
 		cmd := &Command{
 			ID:              startup.Key(),
@@ -313,12 +355,55 @@ func RunSlice(slice settings.StartupSlice) {
 			RecurringPeriod: startup.RecurringPeriod,
 			MaxRestart:      startup.MaxRestart,
 			Tags:            startup.Tags,
-			Arguments:       MustArguments(startup.Args),
+			Arguments:       MustArguments(args),
 			Flags: JobFlags{
 				Protected: startup.Protected,
 			},
 		}
 
+		if startup.Schedule != nil {
+			cmd.Schedule = &Schedule{
+				Cron:     startup.Schedule.Cron,
+				Jitter:   startup.Schedule.Jitter,
+				Timezone: startup.Schedule.Timezone,
+			}
+		}
+
+		if startup.RestartPolicy != nil {
+			cmd.RestartPolicy = &RestartPolicy{
+				Policy:     startup.RestartPolicy.Policy,
+				Delay:      startup.RestartPolicy.Delay,
+				Multiplier: startup.RestartPolicy.Multiplier,
+				Max:        startup.RestartPolicy.Max,
+				ResetAfter: startup.RestartPolicy.ResetAfter,
+			}
+		}
+
+		var health *HealthCheck
+		if startup.Health != nil {
+			health = &HealthCheck{
+				TCP:         startup.Health.TCP,
+				Unix:        startup.Health.Unix,
+				HTTP:        startup.Health.HTTP,
+				Exec:        startup.Health.Exec,
+				Interval:    startup.Health.Interval,
+				Timeout:     startup.Health.Timeout,
+				Retries:     startup.Health.Retries,
+				StartPeriod: startup.Health.StartPeriod,
+			}
+
+			if err := health.Validate(); err != nil {
+				log.Errorf("invalid health check for %s: %s", startup.Key(), err)
+				state.Release(startup.Key(), false)
+				continue
+			}
+
+			if cmd.RestartPolicy == nil && !cmd.Flags.Protected && cmd.RecurringPeriod == 0 && cmd.Schedule == nil {
+				//unhealthy services are killed, so they need to be restarted
+				cmd.RestartPolicy = &RestartPolicy{Policy: RestartOnFailure}
+			}
+		}
+
 		go func(up settings.Startup, c *Command) {
 			log.Debugf("Waiting for %s to run %s", up.After, cmd)
 			canRun := state.Wait(up.After...)
@@ -329,10 +414,27 @@ func RunSlice(slice settings.StartupSlice) {
 				return
 			}
 
-			log.Infof("Starting %s", c)
 			var hooks []RunnerHook
 
-			if up.RunningMatch != "" {
+			if c.Schedule != nil {
+				//scheduled services only run at their fire time, so we don't
+				//hold the services that depends on them.
+				state.Release(c.ID, true)
+			} else if health != nil {
+				//NOTE: If a health check is provided, the service is running once it's healthy
+				hooks = append(hooks, &HealthHook{
+					ID:    c.ID,
+					Check: health,
+					Healthy: func() {
+						log.Infof("%s is healthy, signal running", c.ID)
+						state.Release(c.ID, true)
+					},
+					Unhealthy: func() {
+						log.Warningf("%s is unhealthy, restarting", c.ID)
+						Kill(c.ID)
+					},
+				})
+			} else if up.RunningMatch != "" {
 				//NOTE: If r match is provided it take presence over the delay
 				hooks = append(hooks, &MatchHook{
 					Match: up.RunningMatch,
@@ -362,6 +464,18 @@ func RunSlice(slice settings.StartupSlice) {
 				},
 			})
 
+			if job, ok := JobOf(c.ID); ok {
+				//job was restored from the journal and is already running, it keeps
+				//the service hooks (health checks) for its next events
+				log.Infof("%s is already running", c)
+				if job, ok := job.(*jobImb); ok {
+					job.hook(hooks...)
+				}
+				state.Release(c.ID, true)
+				return
+			}
+
+			log.Infof("Starting %s", c)
 			_, err := Run(c, hooks...)
 			if err != nil {
 				//failed to dispatch command to r manager.
@@ -381,10 +495,23 @@ func cleanUp(runner *jobImb) {
 	delete(jobs, runner.Command().ID)
 	jobsM.Unlock()
 
+	removeHealth(runner.Command().ID)
+
 	queue.Notify(runner)
 	jobsCond.Broadcast()
 }
 
+//ConfigureQueue sets the width (how many jobs can run in parallel) and the priority of
+//a named queue. Must be called after New
+func ConfigureQueue(name string, width int, priority int) {
+	queue.Configure(name, width, priority)
+}
+
+//Queues returns the state of the named queues
+func Queues() []QueueInfo {
+	return queue.List()
+}
+
 //Processes returs a list of running processes
 func Jobs() map[string]Job {
 	res := make(map[string]Job)
@@ -418,6 +545,33 @@ func Killall() {
 	}
 }
 
+//Stop terminates a job (even if it's protected or recurring) and waits for it to exit. The
+//job is killed if it doesn't exit within the timeout
+func Stop(id string, timeout time.Duration) error {
+	jobsM.RLock()
+	job, ok := jobs[id]
+	jobsM.RUnlock()
+
+	if !ok {
+		return NotFoundError(fmt.Errorf("job '%s' not found", id))
+	}
+
+	job.Terminate(syscall.SIGTERM)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if job.WaitContext(ctx) != nil {
+		return nil
+	}
+
+	log.Warningf("job %s didn't exit in %s, killing", id, timeout)
+	job.Terminate(syscall.SIGKILL)
+	job.Wait()
+
+	return nil
+}
+
 //Kill kills a r by the cmd ID
 func Kill(cmdID string) error {
 	jobsM.RLock()
@@ -41,6 +41,39 @@ func TestProcessArguments(t *testing.T) {
 	}
 }
 
+func TestProcessArgumentsCopy(t *testing.T) {
+	values := map[string]interface{}{
+		"name": "Azmy",
+	}
+
+	args := map[string]interface{}{
+		"strvalue": "hello {name}",
+		"deepmap": map[string]interface{}{
+			"subkey": "my name is {name}",
+		},
+	}
+
+	cp := copyArgs(args)
+	processArgs(cp, values)
+
+	//the original args are kept as is
+	if ok := assert.Equal(t, "hello {name}", args["strvalue"]); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, map[string]interface{}{
+		"subkey": "my name is {name}",
+	}, args["deepmap"]); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, map[string]interface{}{
+		"subkey": "my name is Azmy",
+	}, cp["deepmap"]); !ok {
+		t.Error()
+	}
+}
+
 func TestProcessArgumentsFromToml(t *testing.T) {
 
 	source := `
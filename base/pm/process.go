@@ -55,5 +55,23 @@ type PIDer interface {
 	GetPID() int32
 }
 
+//Limited a process that runs under resources limits
+type Limited interface {
+	Process
+	//OOMKilled returns true if the process was killed because it exceeded its memory limit
+	OOMKilled() bool
+}
+
+//Interactive a process that accepts input while running
+type Interactive interface {
+	Process
+	//Write writes data to the process input
+	Write(data []byte) (int, error)
+	//CloseInput closes the process input
+	CloseInput() error
+	//Resize sets the terminal size of the process (tty mode only)
+	Resize(rows, cols uint16) error
+}
+
 //ProcessFactory interface
 type ProcessFactory func(PIDTable, *Command) Process
@@ -1,36 +1,154 @@
 package pm
 
 import (
+	"container/heap"
 	"container/list"
 	"fmt"
+	"sort"
 	"sync"
 )
 
+const (
+	//DefaultQueueWidth number of jobs from the same named queue that can run in parallel
+	//unless the queue is configured otherwise
+	DefaultQueueWidth = 1
+)
+
+//QueuedJob is a job in a named queue
+type QueuedJob struct {
+	ID      string `json:"id"`
+	Command string `json:"command"`
+}
+
+//QueueInfo describes the state of a named queue
+type QueueInfo struct {
+	Name     string      `json:"name"`
+	Width    int         `json:"width"`
+	Priority int         `json:"priority"`
+	Depth    int         `json:"depth"`
+	Running  []QueuedJob `json:"running"`
+	Waiting  []QueuedJob `json:"waiting"`
+}
+
+func newQueuedJob(job *jobImb) QueuedJob {
+	return QueuedJob{
+		ID:      job.command.ID,
+		Command: job.command.Command,
+	}
+}
+
+//namedQueue holds the jobs of a single named queue, at most width jobs
+//are released (active) at the same time.
+type namedQueue struct {
+	width    int
+	priority int
+	active   []*jobImb
+	waiting  *list.List
+}
+
+//Len number of jobs in the queue (active and waiting)
+func (n *namedQueue) Len() int {
+	return len(n.active) + n.waiting.Len()
+}
+
+//queued is a job that is ready to run, waiting for the queue loop to pick it up
+type queued struct {
+	job      *jobImb
+	priority int
+	seq      uint64
+	index    int
+}
+
+//readyHeap orders ready jobs by priority (higher first), then by submit order
+type readyHeap []*queued
+
+func (h readyHeap) Len() int { return len(h) }
+
+func (h readyHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+
+	return h[i].seq < h[j].seq
+}
+
+func (h readyHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *readyHeap) Push(x interface{}) {
+	item := x.(*queued)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *readyHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
 /**
-Queue is used for sequential cmds exectuions
+Queue is used for sequential cmds exectuions. Jobs of the same named queue runs
+serially (or up to the queue width in parallel). Jobs that are ready to run are handed
+to the process manager loop by order of their queue priority.
 */
 type Queue struct {
-	queues map[string]*list.List
-	ch     chan *jobImb
-	lock   sync.Mutex
-	o      sync.Once
-	closed bool
+	queues  map[string]*namedQueue
+	config  map[string]namedQueue
+	acquire map[*jobImb]chan struct{}
+	ready   readyHeap
+	seq     uint64
+	ch      chan *jobImb
+	wake    chan struct{}
+	done    chan struct{}
+	lock    sync.Mutex
+	o       sync.Once
+	closed  bool
 }
 
 //Init initializes the queue
 func (q *Queue) Init() {
 	q.o.Do(func() {
-		q.queues = make(map[string]*list.List)
+		q.queues = make(map[string]*namedQueue)
+		q.config = make(map[string]namedQueue)
+		q.acquire = make(map[*jobImb]chan struct{})
 		q.ch = make(chan *jobImb)
+		q.wake = make(chan struct{}, 1)
+		q.done = make(chan struct{})
+
+		go q.dispatch()
 	})
 }
 
+//Configure sets the width (max number of parallel jobs) and the priority of a named queue.
+//Queue "" is the queue of jobs with no queue name, only its priority is used.
+func (q *Queue) Configure(name string, width int, priority int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if width <= 0 {
+		width = DefaultQueueWidth
+	}
+
+	q.config[name] = namedQueue{width: width, priority: priority}
+	if queue, ok := q.queues[name]; ok {
+		queue.width = width
+		queue.priority = priority
+		q.release(queue)
+	}
+}
+
 //Close the queue. Queue can't be used after close
 func (q *Queue) Close() {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 	q.closed = true
-	close(q.ch)
+	close(q.done)
 }
 
 //Channel return job channel
@@ -38,6 +156,69 @@ func (q *Queue) Channel() <-chan *jobImb {
 	return q.ch
 }
 
+//dispatch feeds the ready jobs to the job channel, highest priority first. The top
+//job is re-evaluated every time a new job becomes ready, so a high priority job
+//doesn't wait behind a low priority one.
+func (q *Queue) dispatch() {
+	defer close(q.ch)
+
+	for {
+		q.lock.Lock()
+		var next *queued
+		if len(q.ready) > 0 {
+			next = q.ready[0]
+		}
+		q.lock.Unlock()
+
+		if next == nil {
+			select {
+			case <-q.wake:
+				continue
+			case <-q.done:
+				return
+			}
+		}
+
+		select {
+		case q.ch <- next.job:
+			q.lock.Lock()
+			heap.Remove(&q.ready, next.index)
+			q.lock.Unlock()
+		case <-q.wake:
+		case <-q.done:
+			return
+		}
+	}
+}
+
+//push a job on the ready heap, must be called with the lock held
+func (q *Queue) push(job *jobImb, priority int) {
+	q.seq++
+	heap.Push(&q.ready, &queued{job: job, priority: priority, seq: q.seq})
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+//release moves waiting jobs to the ready heap as long as the queue has free slots,
+//must be called with the lock held
+func (q *Queue) release(queue *namedQueue) {
+	for len(queue.active) < queue.width && queue.waiting.Len() > 0 {
+		job := queue.waiting.Remove(queue.waiting.Front()).(*jobImb)
+		queue.active = append(queue.active, job)
+		if ch, ok := q.acquire[job]; ok {
+			//the job is already started, it only waits for the slot
+			delete(q.acquire, job)
+			close(ch)
+			continue
+		}
+
+		q.push(job, queue.priority)
+	}
+}
+
 //Push a job on queue
 func (q *Queue) Push(job *jobImb) error {
 	q.lock.Lock()
@@ -48,23 +229,58 @@ func (q *Queue) Push(job *jobImb) error {
 
 	name := job.Command().Queue
 	if name == "" {
-		q.ch <- job
+		q.push(job, q.config[name].priority)
 		return nil
 	}
 
+	queue := q.named(name)
+	queue.waiting.PushBack(job)
+	q.release(queue)
+
+	return nil
+}
+
+//named gets (or creates) a named queue, must be called with the lock held
+func (q *Queue) named(name string) *namedQueue {
 	queue, ok := q.queues[name]
 	if !ok {
-		queue = list.New()
+		queue = &namedQueue{
+			width:    DefaultQueueWidth,
+			priority: 0,
+			waiting:  list.New(),
+		}
+
+		if config, ok := q.config[name]; ok {
+			queue.width = config.width
+			queue.priority = config.priority
+		}
+
 		q.queues[name] = queue
 	}
 
-	queue.PushBack(job)
-	if queue.Len() == 1 {
-		//first job in the queue
-		q.ch <- job
+	return queue
+}
+
+//Acquire waits for a slot in the named queue of a job that is already started (a scheduled
+//job that released its slot with Notify while waiting for its next run). The returned
+//channel is closed once the job holds a slot again
+func (q *Queue) Acquire(job *jobImb) <-chan struct{} {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	ch := make(chan struct{})
+	name := job.Command().Queue
+	if name == "" || q.closed {
+		close(ch)
+		return ch
 	}
 
-	return nil
+	queue := q.named(name)
+	q.acquire[job] = ch
+	queue.waiting.PushBack(job)
+	q.release(queue)
+
+	return ch
 }
 
 //Notify tell queue that a job execution has completed
@@ -80,12 +296,76 @@ func (q *Queue) Notify(job Job) {
 	if !ok {
 		return
 	}
-	queue.Remove(queue.Front())
+
+	for i, active := range queue.active {
+		if active.Command().ID == job.Command().ID {
+			queue.active = append(queue.active[:i], queue.active[i+1:]...)
+			break
+		}
+	}
+
+	//a started job that was waiting to acquire a slot again
+	for e := queue.waiting.Front(); e != nil; e = e.Next() {
+		waiting := e.Value.(*jobImb)
+		if _, ok := q.acquire[waiting]; ok && Job(waiting) == job {
+			queue.waiting.Remove(e)
+			delete(q.acquire, waiting)
+			break
+		}
+	}
+
 	if queue.Len() == 0 {
 		delete(q.queues, name)
 		return
 	}
 
-	next := queue.Front().Value.(*jobImb)
-	q.ch <- next
+	q.release(queue)
+}
+
+//List returns the state of the named queues, configured queues are always listed
+func (q *Queue) List() []QueueInfo {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var infos []QueueInfo
+	for name, config := range q.config {
+		if _, ok := q.queues[name]; ok || name == "" {
+			continue
+		}
+
+		infos = append(infos, QueueInfo{
+			Name:     name,
+			Width:    config.width,
+			Priority: config.priority,
+			Running:  []QueuedJob{},
+			Waiting:  []QueuedJob{},
+		})
+	}
+
+	for name, queue := range q.queues {
+		info := QueueInfo{
+			Name:     name,
+			Width:    queue.width,
+			Priority: queue.priority,
+			Depth:    queue.Len(),
+			Running:  make([]QueuedJob, 0, len(queue.active)),
+			Waiting:  make([]QueuedJob, 0, queue.waiting.Len()),
+		}
+
+		for _, job := range queue.active {
+			info.Running = append(info.Running, newQueuedJob(job))
+		}
+
+		for e := queue.waiting.Front(); e != nil; e = e.Next() {
+			info.Waiting = append(info.Waiting, newQueuedJob(e.Value.(*jobImb)))
+		}
+
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+
+	return infos
 }
@@ -118,3 +118,135 @@ func TestQueue_PushQueued(t *testing.T) {
 	}
 
 }
+
+func TestQueue_Width(t *testing.T) {
+	var q Queue
+	q.Init()
+	q.Configure("disk-io", 2, 0)
+	ch := q.Channel()
+
+	for _, id := range []string{"a", "b", "c"} {
+		q.Push(&jobImb{command: &Command{ID: id, Queue: "disk-io"}})
+	}
+
+	receive := func() *jobImb {
+		select {
+		case job := <-ch:
+			return job
+		case <-time.After(1 * time.Second):
+			return nil
+		}
+	}
+
+	//2 slots, so 2 jobs are released
+	for _, id := range []string{"a", "b"} {
+		job := receive()
+		if ok := assert.NotNil(t, job); !ok {
+			t.Fatal()
+		}
+
+		if ok := assert.Equal(t, id, job.command.ID); !ok {
+			t.Fatal()
+		}
+	}
+
+	if ok := assert.Nil(t, receive()); !ok {
+		t.Fatal()
+	}
+
+	infos := q.List()
+	if ok := assert.Len(t, infos, 1); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, 3, infos[0].Depth); !ok {
+		t.Error()
+	}
+
+	if ok := assert.Equal(t, []QueuedJob{{ID: "c"}}, infos[0].Waiting); !ok {
+		t.Error()
+	}
+
+	q.Notify(&jobImb{command: &Command{ID: "b", Queue: "disk-io"}})
+	job := receive()
+	if ok := assert.NotNil(t, job); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, "c", job.command.ID); !ok {
+		t.Fatal()
+	}
+}
+
+func TestQueue_Priority(t *testing.T) {
+	var q Queue
+	q.Init()
+	q.Configure("bulk", 1, -10)
+	ch := q.Channel()
+
+	q.Push(&jobImb{command: &Command{ID: "bulk", Queue: "bulk"}})
+	q.Push(&jobImb{command: &Command{ID: "interactive"}})
+
+	var order []string
+	for i := 0; i < 2; i++ {
+		select {
+		case job := <-ch:
+			order = append(order, job.command.ID)
+		case <-time.After(1 * time.Second):
+			t.Fatal("timed out waiting for job")
+		}
+	}
+
+	if ok := assert.Equal(t, []string{"interactive", "bulk"}, order); !ok {
+		t.Error()
+	}
+}
+
+func TestQueue_Acquire(t *testing.T) {
+	var q Queue
+	q.Init()
+	ch := q.Channel()
+
+	scheduled := &jobImb{command: &Command{ID: "scheduled", Queue: "test"}}
+	other := &jobImb{command: &Command{ID: "other", Queue: "test"}}
+
+	q.Push(scheduled)
+	q.Push(other)
+
+	if ok := assert.Equal(t, scheduled, <-ch); !ok {
+		t.Fatal()
+	}
+
+	//the scheduled job releases its slot while waiting for its next run
+	q.Notify(scheduled)
+
+	select {
+	case job := <-ch:
+		if ok := assert.Equal(t, other, job); !ok {
+			t.Fatal()
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("timed out waiting for job")
+	}
+
+	acquired := q.Acquire(scheduled)
+	select {
+	case <-acquired:
+		t.Fatal("slot acquired while the queue is full")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	q.Notify(other)
+	select {
+	case <-acquired:
+	case <-time.After(1 * time.Second):
+		t.Fatal("timed out waiting for slot")
+	}
+
+	//the started job must not be handed to the loop again
+	select {
+	case job := <-ch:
+		t.Fatalf("unexpected job %s", job.command.ID)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,88 @@
+package pm
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	//RestartAlways restarts the job every time it exits
+	RestartAlways = "always"
+	//RestartOnFailure restarts the job only if it exits with an error
+	RestartOnFailure = "on-failure"
+	//RestartNever never restarts the job
+	RestartNever = "never"
+)
+
+//RestartPolicy defines when and how fast a job is restarted after it exits. If the command
+//MaxRestart is set, the job runs at most MaxRestart times (since the last reset)
+type RestartPolicy struct {
+	//Policy one of always, on-failure, never
+	Policy string `json:"policy"`
+	//Delay initial delay in seconds before restarting the job
+	Delay int `json:"delay,omitempty"`
+	//Multiplier the delay is multiplied by this value on each consecutive restart
+	Multiplier float64 `json:"multiplier,omitempty"`
+	//Max max delay in seconds between restarts
+	Max int `json:"max,omitempty"`
+	//ResetAfter if the job ran for that many seconds before it exited, the restart count
+	//and the delay are reset
+	ResetAfter int `json:"reset_after,omitempty"`
+}
+
+//Validate checks the restart policy values
+func (p *RestartPolicy) Validate() error {
+	switch p.Policy {
+	case RestartAlways, RestartOnFailure, RestartNever:
+	default:
+		return fmt.Errorf("invalid restart policy '%s'", p.Policy)
+	}
+
+	if p.Delay < 0 || p.Max < 0 || p.ResetAfter < 0 {
+		return fmt.Errorf("restart policy delay, max and reset_after can't be negative")
+	}
+
+	if p.Multiplier != 0 && p.Multiplier < 1 {
+		return fmt.Errorf("restart policy multiplier must be >= 1")
+	}
+
+	if p.Delay > 0 && p.Max > 0 && p.Max < p.Delay {
+		return fmt.Errorf("restart policy max can't be less than delay")
+	}
+
+	return nil
+}
+
+//Restart checks if a job that exited with the given state must be restarted
+func (p *RestartPolicy) Restart(state JobState) bool {
+	switch p.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return state != StateSuccess
+	default:
+		return false
+	}
+}
+
+//Reset checks if a job that ran for the given duration must have its restarts count reset
+func (p *RestartPolicy) Reset(ran time.Duration) bool {
+	return p.ResetAfter > 0 && ran >= time.Duration(p.ResetAfter)*time.Second
+}
+
+//apply sets the backoff parameters from the policy, unset values are kept
+func (p *RestartPolicy) apply(b *BackOff) {
+	if p.Delay > 0 {
+		b.Delay = time.Duration(p.Delay) * time.Second
+	}
+
+	if p.Multiplier > 0 {
+		b.Multiply = p.Multiplier
+	}
+
+	if p.Max > 0 {
+		b.Max = time.Duration(p.Max) * time.Second
+	} else if b.Max < b.Delay {
+		b.Max = b.Delay
+	}
+}
@@ -13,6 +13,12 @@ const (
 	StateUnknownCmd JobState = "UNKNOWN_CMD"
 	//StateDuplicateID dublicate id exit status
 	StateDuplicateID JobState = "DUPILICATE_ID"
+	//StateSkipped the job never ran because one of its dependencies failed (batch)
+	StateSkipped JobState = "SKIPPED"
+	//StateOOM the job process was killed because it exceeded its memory limit
+	StateOOM JobState = "OOM"
+	//StateUnauthorized the job never ran because the client is not allowed to run the command
+	StateUnauthorized JobState = "UNAUTHORIZED"
 )
 
 //JobState of a job
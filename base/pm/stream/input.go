@@ -206,3 +206,31 @@ func (c *consumerImpl) consume() error {
 		}
 	}
 }
+
+//ConsumeRaw consumes a stream to the end, and calls the handler with every chunk read from the stream
+//as is (no message headers parsing). It's used for terminals output, where the output is not line based.
+func ConsumeRaw(wg *sync.WaitGroup, source io.ReadCloser, level uint16, handler MessageHandler) {
+	go func() {
+		if wg != nil {
+			defer wg.Done()
+		}
+
+		buffer := make([]byte, ioBufferSize)
+		for {
+			size, err := source.Read(buffer)
+			if size > 0 {
+				handler(&Message{
+					Meta:    NewMeta(level),
+					Message: string(buffer[:size]),
+				})
+			}
+
+			//a terminal master returns EIO once the terminal is closed
+			if err != nil {
+				break
+			}
+		}
+
+		source.Close()
+	}()
+}
@@ -19,6 +19,13 @@ type SystemCommandArguments struct {
 	Args  []string          `json:"args"`
 	Env   map[string]string `json:"env"`
 	StdIn string            `json:"stdin"`
+	//Interactive keeps the process stdin open, so more input can be fed to the process while running
+	Interactive bool `json:"interactive,omitempty"`
+	//Tty runs the process in a pseudo terminal (implies interactive), stdout and stderr are merged
+	Tty bool `json:"tty,omitempty"`
+	//Rows and Cols are the initial terminal size in tty mode
+	Rows uint16 `json:"rows,omitempty"`
+	Cols uint16 `json:"cols,omitempty"`
 }
 
 func (s *SystemCommandArguments) String() string {
@@ -30,6 +37,10 @@ type systemProcessImpl struct {
 	args    SystemCommandArguments
 	pid     int
 	process *psutils.Process
+	oom     bool
+
+	input  *os.File
+	inputM sync.Mutex
 
 	table PIDTable
 }
@@ -87,6 +98,70 @@ func (p *systemProcessImpl) GetPID() int32 {
 	return ps.Pid
 }
 
+//Write writes data to the process stdin (or terminal)
+func (p *systemProcessImpl) Write(data []byte) (int, error) {
+	p.inputM.Lock()
+	defer p.inputM.Unlock()
+
+	if p.input == nil {
+		return 0, fmt.Errorf("process input is closed")
+	}
+
+	return p.input.Write(data)
+}
+
+//CloseInput closes the process stdin. In tty mode, an end of transmission is sent instead
+//which the terminal translates to an end of file.
+func (p *systemProcessImpl) CloseInput() error {
+	p.inputM.Lock()
+	defer p.inputM.Unlock()
+
+	if p.input == nil {
+		return nil
+	}
+
+	if p.args.Tty {
+		_, err := p.input.Write([]byte{0x04})
+		return err
+	}
+
+	err := p.input.Close()
+	p.input = nil
+	return err
+}
+
+//Resize sets the terminal window size of a tty process
+func (p *systemProcessImpl) Resize(rows, cols uint16) error {
+	p.inputM.Lock()
+	defer p.inputM.Unlock()
+
+	if !p.args.Tty {
+		return fmt.Errorf("process has no tty")
+	}
+
+	if p.input == nil {
+		return fmt.Errorf("process terminal is closed")
+	}
+
+	return resizePty(p.input, rows, cols)
+}
+
+//closeInput releases the process input once the process has exited
+func (p *systemProcessImpl) closeInput() {
+	p.inputM.Lock()
+	defer p.inputM.Unlock()
+
+	if p.input != nil {
+		p.input.Close()
+		p.input = nil
+	}
+}
+
+//OOMKilled returns true if the process was killed because it exceeded its memory limit
+func (p *systemProcessImpl) OOMKilled() bool {
+	return p.oom
+}
+
 func (p *systemProcessImpl) Signal(sig syscall.Signal) error {
 	if p.process == nil {
 		return fmt.Errorf("process not found")
@@ -132,32 +207,69 @@ func (p *systemProcessImpl) Run() (ch <-chan *stream.Message, err error) {
 
 	var wg sync.WaitGroup
 
+	handler := func(m *stream.Message) {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Errorf("error while writing output: %s", err)
+			}
+		}()
+		channel <- m
+	}
+
 	var toClose []*os.File
 	var input *os.File
-	if len(p.args.StdIn) != 0 {
+	sys := &syscall.SysProcAttr{
+		Setpgid: !p.cmd.Flags.NoSetPGID,
+	}
+
+	if p.args.Tty {
+		var slave *os.File
+		input, slave, err = openPty()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.args.Rows != 0 && p.args.Cols != 0 {
+			if err = resizePty(input, p.args.Rows, p.args.Cols); err != nil {
+				input.Close()
+				slave.Close()
+				return nil, err
+			}
+		}
+
+		stdin, stdout, stderr = slave, slave, slave
+		toClose = append(toClose, slave)
+
+		//the process becomes a session leader with the terminal as its controlling terminal
+		sys = &syscall.SysProcAttr{
+			Setsid:  true,
+			Setctty: true,
+			Ctty:    0,
+		}
+
+		//the terminal must always be drained, otherwise the process blocks on write
+		output := handler
+		if p.cmd.Flags.NoOutput {
+			output = func(*stream.Message) {}
+		}
+
+		wg.Add(1)
+		stream.ConsumeRaw(&wg, input, stream.LevelStdout, output)
+	} else if len(p.args.StdIn) != 0 || p.args.Interactive {
 		stdin, input, err = os.Pipe()
 		if err != nil {
 			return nil, err
 		}
+		toClose = append(toClose, stdin)
 	} else {
 		stdin, err = os.Open(os.DevNull)
 		if err != nil {
 			return nil, err
 		}
+		toClose = append(toClose, stdin)
 	}
 
-	toClose = append(toClose, stdin)
-
-	if !p.cmd.Flags.NoOutput {
-		handler := func(m *stream.Message) {
-			defer func() {
-				if err := recover(); err != nil {
-					log.Errorf("error while writing output: %s", err)
-				}
-			}()
-			channel <- m
-		}
-
+	if !p.cmd.Flags.NoOutput && !p.args.Tty {
 		var outRead, errRead *os.File
 		outRead, stdout, err = os.Pipe()
 		if err != nil {
@@ -180,16 +292,31 @@ func (p *systemProcessImpl) Run() (ch <-chan *stream.Message, err error) {
 		Files: []*os.File{
 			stdin, stdout, stderr,
 		},
-		Sys: &syscall.SysProcAttr{
-			Setpgid: !p.cmd.Flags.NoSetPGID,
-		},
+		Sys: sys,
 	}
 
 	var ps *os.Process
+	var ready *os.File
+	path := name
 	args := []string{name}
+	if p.cmd.Limits != nil {
+		//the process must not run before it is in its groups, so it starts as a copy of this
+		//binary that waits on the sync pipe before executing the command
+		var sync *os.File
+		sync, ready, err = os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+
+		attrs.Files = append(attrs.Files, sync)
+		toClose = append(toClose, sync)
+		path = "/proc/self/exe"
+		args = append(args, limitedExec, name)
+	}
+
 	args = append(args, p.args.Args...)
 	_, err = p.table.RegisterPID(func() (int, error) {
-		ps, err = os.StartProcess(name, args, &attrs)
+		ps, err = os.StartProcess(path, args, &attrs)
 		if err != nil {
 			return 0, err
 		}
@@ -200,26 +327,77 @@ func (p *systemProcessImpl) Run() (ch <-chan *stream.Message, err error) {
 	})
 
 	if err != nil {
+		for _, f := range toClose {
+			f.Close()
+		}
+		if input != nil {
+			input.Close()
+		}
+		if ready != nil {
+			ready.Close()
+		}
 		return
 	}
 
 	p.pid = ps.Pid
+	if ready != nil {
+		if err = limit(p.cmd, p.pid); err == nil {
+			_, err = ready.Write([]byte{1})
+		}
+		ready.Close()
+	}
+
+	if err != nil {
+		//limits couldn't be applied, the process can't run without them
+		target := p.pid
+		if sys.Setpgid || sys.Setsid {
+			//the process leads its own group
+			target = -p.pid
+		}
+		syscall.Kill(target, syscall.SIGKILL)
+		if input != nil {
+			input.Close()
+		}
+		p.table.WaitPID(p.pid)
+		ps.Release()
+		unlimit(p.cmd)
+		return nil, InternalError(fmt.Errorf("failed to apply limits: %s", err))
+	}
+
 	psProcess, _ := psutils.NewProcess(int32(p.pid))
 	p.process = psProcess
 
+	var exited chan struct{}
 	if input != nil {
 		//write data to command stdin.
 		io.WriteString(input, p.args.StdIn)
-		input.Close()
+		if p.args.Interactive || p.args.Tty {
+			p.inputM.Lock()
+			p.input = input
+			p.inputM.Unlock()
+
+			exited = make(chan struct{})
+			attach(p.cmd.ID, exited)
+		} else {
+			input.Close()
+		}
 	}
 
 	go func(channel chan *stream.Message) {
 		//make sure all outputs are closed before waiting for the p
 		defer close(channel)
 		state := p.table.WaitPID(p.pid)
+		if exited != nil {
+			close(exited)
+		}
+		if !p.args.Tty {
+			p.closeInput()
+		}
 		//wait for all streams to finish copying
 		wg.Wait()
+		p.closeInput()
 		ps.Release()
+		p.oom = unlimit(p.cmd)
 		code := state.ExitStatus()
 		log.Debugf("Process %s exited with state: %d", p.cmd, code)
 		if code == 0 {
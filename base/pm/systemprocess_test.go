@@ -118,3 +118,86 @@ func TestSystemProcess_RunStdin(t *testing.T) {
 		t.Error()
 	}
 }
+
+func TestSystemProcess_RunInteractive(t *testing.T) {
+	ps := NewSystemProcess(&TestingPIDTable{}, &Command{
+		Arguments: MustArguments(
+			SystemCommandArguments{
+				Name:        "cat",
+				StdIn:       "hello ",
+				Interactive: true,
+			},
+		),
+	})
+
+	ch, err := ps.Run()
+
+	if ok := assert.Nil(t, err); !ok {
+		t.Fatal(err)
+	}
+
+	interactive, ok := ps.(Interactive)
+	if ok := assert.True(t, ok); !ok {
+		t.Fatal()
+	}
+
+	if _, err := interactive.Write([]byte("world\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := interactive.CloseInput(); err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []*stream.Message
+	for msg := range ch {
+		messages = append(messages, msg)
+	}
+
+	if ok := assert.Len(t, messages, 2); !ok { //the 2nd is for termination message
+		t.Fatal()
+	}
+
+	if ok := assert.Equal(t, "hello world\n", messages[0].Message); !ok {
+		t.Error()
+	}
+
+	if ok := assert.True(t, messages[1].Meta.Is(stream.ExitSuccessFlag)); !ok {
+		t.Error()
+	}
+}
+
+func TestSystemProcess_RunTty(t *testing.T) {
+	ps := NewSystemProcess(&TestingPIDTable{}, &Command{
+		Arguments: MustArguments(
+			SystemCommandArguments{
+				Name: "sh",
+				Args: []string{"-c", "test -t 0 && test -t 1 && stty size"},
+				Tty:  true,
+				Rows: 24,
+				Cols: 80,
+			},
+		),
+	})
+
+	ch, err := ps.Run()
+
+	if ok := assert.Nil(t, err); !ok {
+		t.Fatal(err)
+	}
+
+	var output string
+	var last *stream.Message
+	for msg := range ch {
+		output += msg.Message
+		last = msg
+	}
+
+	if ok := assert.Equal(t, "24 80\r\n", output); !ok {
+		t.Error()
+	}
+
+	if ok := assert.True(t, last.Meta.Is(stream.ExitSuccessFlag)); !ok {
+		t.Error()
+	}
+}
@@ -0,0 +1,189 @@
+package pm
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var (
+	//DefaultWebhookDeny the destinations denied if the policy has no allow or deny rules, the node
+	//local addresses are not reachable by the webhooks
+	DefaultWebhookDeny = []string{
+		"localhost", "0.0.0.0/8", "127.0.0.0/8", "169.254.0.0/16", "::/128", "::1/128", "fe80::/10",
+	}
+
+	webhookPolicy  = MustWebhookPolicy(nil, nil)
+	webhookPolicyM sync.RWMutex
+)
+
+//Webhook the job result is posted (as json) to the webhook url each time the job exits
+type Webhook struct {
+	//URL http or https url the result is posted to
+	URL string `json:"url"`
+	//Retries max number of delivery retries if the url is unreachable or returns a server error
+	Retries int `json:"retries,omitempty"`
+}
+
+//Validate checks the webhook values, and that the webhook host is allowed by the webhook policy
+func (w *Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook url: %s", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("webhook url must be an http or https url")
+	}
+
+	if len(u.Host) == 0 {
+		return fmt.Errorf("webhook url has no host")
+	}
+
+	if w.Retries < 0 {
+		return fmt.Errorf("webhook retries can't be negative")
+	}
+
+	return GetWebhookPolicy().Check(u.Hostname(), nil)
+}
+
+//webhookRule matches a host name (a *.domain pattern matches all the sub domains), or an ip network
+type webhookRule struct {
+	host    string
+	network *net.IPNet
+}
+
+func parseWebhookRule(rule string) (webhookRule, error) {
+	rule = strings.ToLower(strings.TrimSpace(rule))
+	if len(rule) == 0 {
+		return webhookRule{}, fmt.Errorf("empty webhook rule")
+	}
+
+	if strings.Contains(rule, "/") {
+		_, network, err := net.ParseCIDR(rule)
+		if err != nil {
+			return webhookRule{}, fmt.Errorf("invalid webhook rule '%s': %s", rule, err)
+		}
+
+		return webhookRule{network: network}, nil
+	}
+
+	if ip := net.ParseIP(rule); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+
+		return webhookRule{network: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}}, nil
+	}
+
+	return webhookRule{host: strings.TrimSuffix(rule, ".")}, nil
+}
+
+func (r webhookRule) matchHost(host string) bool {
+	if len(r.host) == 0 {
+		return false
+	}
+
+	if strings.HasPrefix(r.host, "*.") {
+		return strings.HasSuffix(host, r.host[1:])
+	}
+
+	return host == r.host
+}
+
+func (r webhookRule) matchIP(ip net.IP) bool {
+	return r.network != nil && ip != nil && r.network.Contains(ip)
+}
+
+//WebhookPolicy restricts the destinations of the webhooks. A destination is denied if its host name or
+//address matches a deny rule, and if the policy has allow rules, it must match one of them.
+type WebhookPolicy struct {
+	allow []webhookRule
+	deny  []webhookRule
+}
+
+//NewWebhookPolicy creates a webhook policy from the allow and deny rules, a rule is a host name, a
+//*.domain pattern, an ip or an ip network. If no rules are set, the DefaultWebhookDeny rules are used.
+func NewWebhookPolicy(allow, deny []string) (*WebhookPolicy, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		deny = DefaultWebhookDeny
+	}
+
+	var policy WebhookPolicy
+	for _, rules := range []struct {
+		specs []string
+		rules *[]webhookRule
+	}{{allow, &policy.allow}, {deny, &policy.deny}} {
+		for _, spec := range rules.specs {
+			rule, err := parseWebhookRule(spec)
+			if err != nil {
+				return nil, err
+			}
+
+			*rules.rules = append(*rules.rules, rule)
+		}
+	}
+
+	return &policy, nil
+}
+
+//MustWebhookPolicy creates a webhook policy, it panics if the rules are invalid
+func MustWebhookPolicy(allow, deny []string) *WebhookPolicy {
+	policy, err := NewWebhookPolicy(allow, deny)
+	if err != nil {
+		panic(err)
+	}
+
+	return policy
+}
+
+//Check checks if the policy allows the host, and the address the host was resolved to. If ip is nil (the
+//host is not resolved yet), only the host is checked, unless the host is an ip itself.
+func (p *WebhookPolicy) Check(host string, ip net.IP) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if ip == nil {
+		ip = net.ParseIP(host)
+	}
+
+	for _, rule := range p.deny {
+		if rule.matchHost(host) || rule.matchIP(ip) {
+			return fmt.Errorf("webhook destination '%s' is denied", host)
+		}
+	}
+
+	if len(p.allow) == 0 {
+		return nil
+	}
+
+	for _, rule := range p.allow {
+		if rule.matchHost(host) || rule.matchIP(ip) {
+			return nil
+		}
+
+		//the address will be checked once the host is resolved
+		if ip == nil && rule.network != nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("webhook destination '%s' is not allowed", host)
+}
+
+//SetWebhookPolicy sets the policy the webhooks are checked with
+func SetWebhookPolicy(policy *WebhookPolicy) {
+	webhookPolicyM.Lock()
+	defer webhookPolicyM.Unlock()
+
+	webhookPolicy = policy
+}
+
+//GetWebhookPolicy gets the policy the webhooks are checked with
+func GetWebhookPolicy() *WebhookPolicy {
+	webhookPolicyM.RLock()
+	defer webhookPolicyM.RUnlock()
+
+	return webhookPolicy
+}
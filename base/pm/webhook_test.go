@@ -0,0 +1,84 @@
+package pm
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookValidate(t *testing.T) {
+	for _, hook := range []Webhook{
+		{URL: "ftp://example.com/hook"},
+		{URL: "http:///hook"},
+		{URL: "https://example.com/hook", Retries: -1},
+		//denied by the default policy
+		{URL: "http://127.0.0.1:8080/hook"},
+		{URL: "http://localhost/hook"},
+		{URL: "http://[::1]/hook"},
+		{URL: "http://169.254.169.254/latest/meta-data"},
+	} {
+		if ok := assert.Error(t, hook.Validate(), hook.URL); !ok {
+			t.Error()
+		}
+	}
+
+	hook := Webhook{URL: "https://example.com/hook", Retries: 3}
+	if ok := assert.NoError(t, hook.Validate()); !ok {
+		t.Error()
+	}
+}
+
+func TestWebhookPolicy(t *testing.T) {
+	policy, err := NewWebhookPolicy([]string{"*.example.com", "10.0.0.0/8"}, []string{"internal.example.com", "10.0.0.1"})
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	allowed := []struct {
+		host string
+		ip   net.IP
+	}{
+		{"hooks.example.com", nil},
+		{"hooks.example.com", net.ParseIP("192.168.1.1")},
+		{"10.1.2.3", nil},
+		//not resolved yet, the address is checked against the allowed networks later
+		{"hooks.other.com", nil},
+		{"hooks.other.com", net.ParseIP("10.1.2.3")},
+	}
+
+	for _, dest := range allowed {
+		if ok := assert.NoError(t, policy.Check(dest.host, dest.ip), dest.host); !ok {
+			t.Error()
+		}
+	}
+
+	denied := []struct {
+		host string
+		ip   net.IP
+	}{
+		{"internal.example.com", nil},
+		{"INTERNAL.example.com.", nil},
+		{"10.0.0.1", nil},
+		{"hooks.example.com", net.ParseIP("10.0.0.1")},
+		{"hooks.other.com", net.ParseIP("192.168.1.1")},
+		{"192.168.1.1", nil},
+	}
+
+	for _, dest := range denied {
+		if ok := assert.Error(t, policy.Check(dest.host, dest.ip), dest.host); !ok {
+			t.Error()
+		}
+	}
+
+	//names only, a host that doesn't match is denied before it's resolved
+	policy = MustWebhookPolicy([]string{"hooks.example.com"}, nil)
+	if ok := assert.Error(t, policy.Check("hooks.other.com", nil)); !ok {
+		t.Error()
+	}
+
+	_, err = NewWebhookPolicy(nil, []string{"10.0.0.0/33"})
+	if ok := assert.Error(t, err); !ok {
+		t.Error()
+	}
+}
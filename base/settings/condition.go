@@ -2,11 +2,20 @@ package settings
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
 	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
 )
 
-type expBuilder func(args []Expression) Expression
+const (
+	rtfUp = 0x1
+)
+
+type expBuilder func(args []Expression) (Expression, error)
 
 var (
 	word = regexp.MustCompile(`^[\w-]+`)
@@ -14,12 +23,27 @@ var (
 	//EOL parser has reached end of expression
 	EOL = fmt.Errorf("end of line")
 
+	//DevicesPath where device() looks up devices
+	DevicesPath = "/dev"
+	//DMIPath where dmi() reads the DMI fields
+	DMIPath = "/sys/class/dmi/id"
+	//NetworkPath where network(iface) reads the interfaces state
+	NetworkPath = "/sys/class/net"
+	//RoutesPath where network() looks for a default route
+	RoutesPath = "/proc/net/route"
+
 	expressions = map[string]expBuilder{
-		"true":  func(_ []Expression) Expression { return boolExp(true) },
-		"false": func(_ []Expression) Expression { return boolExp(false) },
-		"and":   func(args []Expression) Expression { return andExp{args} },
-		"or":    func(args []Expression) Expression { return orExp{args} },
-		"not":   func(args []Expression) Expression { return notExp{args} },
+		"true":    func(_ []Expression) (Expression, error) { return boolExp(true), nil },
+		"false":   func(_ []Expression) (Expression, error) { return boolExp(false), nil },
+		"and":     func(args []Expression) (Expression, error) { return andExp{args}, nil },
+		"or":      func(args []Expression) (Expression, error) { return orExp{args}, nil },
+		"not":     func(args []Expression) (Expression, error) { return notExp{args}, nil },
+		"eq":      newEqExp,
+		"exists":  newExistsExp,
+		"device":  newDeviceExp,
+		"arch":    newArchExp,
+		"dmi":     newDMIExp,
+		"network": newNetworkExp,
 	}
 )
 
@@ -104,6 +128,201 @@ func (u userExp) Examine(in map[string]interface{}) bool {
 	return ok
 }
 
+//valueExp is a quoted string, it's true if not empty
+type valueExp string
+
+func (v valueExp) Examine(_ map[string]interface{}) bool {
+	return len(v) != 0
+}
+
+//literal gets the string value of an argument, both quoted strings and bare
+//words can be used as values
+func literal(arg Expression) (string, bool) {
+	switch arg := arg.(type) {
+	case valueExp:
+		return string(arg), true
+	case userExp:
+		return string(arg), true
+	}
+
+	return "", false
+}
+
+//literals gets the string values of the arguments, and validates their count
+func literals(args []Expression, min, max int) ([]string, error) {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return nil, fmt.Errorf("expecting %d argument(s)", min)
+		}
+		return nil, fmt.Errorf("expecting %d to %d argument(s)", min, max)
+	}
+
+	var values []string
+	for _, arg := range args {
+		value, ok := literal(arg)
+		if !ok {
+			return nil, fmt.Errorf("expecting a value got '%v'", arg)
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+//eqExp is true if the input key (kernel cmdline argument) equals the value
+type eqExp struct {
+	Key   string
+	Value string
+}
+
+func newEqExp(args []Expression) (Expression, error) {
+	values, err := literals(args, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	return eqExp{Key: values[0], Value: values[1]}, nil
+}
+
+func (e eqExp) Examine(in map[string]interface{}) bool {
+	value, ok := in[e.Key]
+	if !ok {
+		return false
+	}
+
+	return fmt.Sprint(value) == e.Value
+}
+
+func (e eqExp) String() string {
+	return fmt.Sprintf("EQ (%s, %s)", e.Key, e.Value)
+}
+
+//existsExp is true if the given path (or glob pattern) exists
+type existsExp string
+
+func newExistsExp(args []Expression) (Expression, error) {
+	values, err := literals(args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return existsExp(values[0]), nil
+}
+
+func newDeviceExp(args []Expression) (Expression, error) {
+	values, err := literals(args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return existsExp(path.Join(DevicesPath, values[0])), nil
+}
+
+func (e existsExp) Examine(_ map[string]interface{}) bool {
+	matches, err := filepath.Glob(string(e))
+	return err == nil && len(matches) > 0
+}
+
+func (e existsExp) String() string {
+	return fmt.Sprintf("EXISTS (%s)", string(e))
+}
+
+//archExp is true if the node cpu architecture (as in GOARCH) matches
+type archExp string
+
+func newArchExp(args []Expression) (Expression, error) {
+	values, err := literals(args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return archExp(values[0]), nil
+}
+
+func (a archExp) Examine(_ map[string]interface{}) bool {
+	return string(a) == runtime.GOARCH
+}
+
+//dmiExp is true if the DMI (SMBIOS) field contains the value (case insensitive). Fields
+//are the ones exported by the kernel under /sys/class/dmi/id (sys_vendor, product_name, board_name, etc...)
+type dmiExp struct {
+	Field string
+	Value string
+}
+
+func newDMIExp(args []Expression) (Expression, error) {
+	values, err := literals(args, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	return dmiExp{Field: values[0], Value: values[1]}, nil
+}
+
+func (d dmiExp) Examine(_ map[string]interface{}) bool {
+	data, err := ioutil.ReadFile(path.Join(DMIPath, path.Base(d.Field)))
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(
+		strings.ToLower(strings.TrimSpace(string(data))),
+		strings.ToLower(d.Value),
+	)
+}
+
+func (d dmiExp) String() string {
+	return fmt.Sprintf("DMI (%s, %s)", d.Field, d.Value)
+}
+
+//networkExp with no arguments is true if the node has a default route, with an interface
+//name it's true if this interface is up
+type networkExp string
+
+func newNetworkExp(args []Expression) (Expression, error) {
+	values, err := literals(args, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		return networkExp(""), nil
+	}
+
+	return networkExp(values[0]), nil
+}
+
+func (n networkExp) Examine(_ map[string]interface{}) bool {
+	if len(n) != 0 {
+		state, err := ioutil.ReadFile(path.Join(NetworkPath, path.Base(string(n)), "operstate"))
+		return err == nil && strings.TrimSpace(string(state)) == "up"
+	}
+
+	routes, err := ioutil.ReadFile(RoutesPath)
+	if err != nil {
+		return false
+	}
+
+	//Iface Destination Gateway Flags ...
+	for _, line := range strings.Split(string(routes), "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil {
+			continue
+		}
+
+		if fields[1] == "00000000" && flags&rtfUp != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
 //forward return position of the first non space char starting at from
 func forward(from int, s string) int {
 	pos := strings.IndexFunc(s[from:], func(c rune) bool {
@@ -117,9 +336,32 @@ func forward(from int, s string) int {
 	return pos + from
 }
 
+//getString parses a quoted string starting at the quote char
+func getString(at int, expression string) (Expression, int, error) {
+	quote := expression[at]
+	end := strings.IndexByte(expression[at+1:], quote)
+	if end == -1 {
+		return nil, at, fmt.Errorf("unterminated string")
+	}
+
+	value := valueExp(expression[at+1 : at+1+end])
+	next := forward(at+end+2, expression)
+
+	var err error
+	if next >= len(expression) {
+		err = EOL
+	}
+
+	return value, next, err
+}
+
 func getOne(at int, expression string) (Expression, int, error) {
 	at = forward(at, expression)
 
+	if at < len(expression) && (expression[at] == '"' || expression[at] == '\'') {
+		return getString(at, expression)
+	}
+
 	loc := word.FindStringIndex(expression[at:])
 	if loc == nil {
 		return nil, at, nil
@@ -139,7 +381,11 @@ func getOne(at int, expression string) (Expression, int, error) {
 				if err != nil {
 					return nil, next, err
 				}
-				args = append(args, sub)
+
+				if sub != nil {
+					//empty args list like fn()
+					args = append(args, sub)
+				}
 
 				//find next non space char
 				next = strings.IndexFunc(expression[next:], func(c rune) bool {
@@ -160,7 +406,10 @@ func getOne(at int, expression string) (Expression, int, error) {
 	var exp Expression
 	var err error
 	if ok {
-		exp = builder(args)
+		exp, err = builder(args)
+		if err != nil {
+			return nil, next, fmt.Errorf("%s: %s", token, err)
+		}
 	} else {
 		exp = userExp(token)
 	}
@@ -1,6 +1,11 @@
 package settings
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -145,3 +150,144 @@ func TestConditionParseComplexExmpty(t *testing.T) {
 		t.Error()
 	}
 }
+
+func TestConditionEq(t *testing.T) {
+	exp, err := GetExpression(`and(eq(role, storage), eq(zerotier, "a b"))`)
+
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	input := map[string]interface{}{
+		"role":     "storage",
+		"zerotier": "a b",
+	}
+
+	if ok := assert.True(t, exp.Examine(input)); !ok {
+		t.Error()
+	}
+
+	input["role"] = "compute"
+	if ok := assert.False(t, exp.Examine(input)); !ok {
+		t.Error()
+	}
+
+	_, err = GetExpression("eq(role)")
+	if ok := assert.Error(t, err); !ok {
+		t.Error()
+	}
+
+	_, err = GetExpression(`eq(role, "storage)`)
+	if ok := assert.Error(t, err); !ok {
+		t.Error()
+	}
+}
+
+func TestConditionExists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "condition")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(path.Join(dir, "nvme0n1"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	DevicesPath = dir
+	defer func() {
+		DevicesPath = "/dev"
+	}()
+
+	for expression, expected := range map[string]bool{
+		fmt.Sprintf(`exists("%s/nvme0n1")`, dir): true,
+		fmt.Sprintf(`exists("%s/sda")`, dir):     false,
+		`device(nvme0n1)`:                        true,
+		`device("nvme*")`:                        true,
+		`device(sda)`:                            false,
+	} {
+		exp, err := GetExpression(expression)
+		if ok := assert.NoError(t, err, expression); !ok {
+			t.Fatal()
+		}
+
+		if ok := assert.Equal(t, expected, exp.Examine(nil), expression); !ok {
+			t.Error()
+		}
+	}
+}
+
+func TestConditionArch(t *testing.T) {
+	exp, err := GetExpression(fmt.Sprintf("arch(%s)", runtime.GOARCH))
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.True(t, exp.Examine(nil)); !ok {
+		t.Error()
+	}
+
+	exp, err = GetExpression("not(arch(unknown))")
+	if ok := assert.NoError(t, err); !ok {
+		t.Fatal()
+	}
+
+	if ok := assert.True(t, exp.Examine(nil)); !ok {
+		t.Error()
+	}
+}
+
+func TestConditionDMIAndNetwork(t *testing.T) {
+	dir, err := ioutil.TempDir("", "condition")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"dmi/sys_vendor":     "Supermicro\n",
+		"net/eth0/operstate": "up\n",
+		"net/eth1/operstate": "down\n",
+		"route": "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\n" +
+			"eth0\t00000000\t0101A8C0\t0003\t0\t0\t0\t00000000\n",
+	}
+
+	for name, content := range files {
+		name = path.Join(dir, name)
+		os.MkdirAll(path.Dir(name), 0755)
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	DMIPath, NetworkPath, RoutesPath = path.Join(dir, "dmi"), path.Join(dir, "net"), path.Join(dir, "route")
+	defer func() {
+		DMIPath, NetworkPath, RoutesPath = "/sys/class/dmi/id", "/sys/class/net", "/proc/net/route"
+	}()
+
+	for expression, expected := range map[string]bool{
+		`dmi(sys_vendor, "supermicro")`: true,
+		`dmi(sys_vendor, "Dell Inc.")`:  false,
+		`dmi(product_name, "x")`:        false,
+		`network()`:                     true,
+		`network(eth0)`:                 true,
+		`network(eth1)`:                 false,
+	} {
+		exp, err := GetExpression(expression)
+		if ok := assert.NoError(t, err, expression); !ok {
+			t.Fatal()
+		}
+
+		if ok := assert.Equal(t, expected, exp.Examine(nil), expression); !ok {
+			t.Error()
+		}
+	}
+
+	RoutesPath = path.Join(dir, "missing")
+	exp, _ := GetExpression("network")
+	if ok := assert.False(t, exp.Examine(nil)); !ok {
+		t.Error()
+	}
+}
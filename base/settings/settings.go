@@ -17,6 +17,96 @@ const (
 //Logger settings
 type Logger struct {
 	Levels []uint16 `json:"levels"`
+	//QueueSize max number of records queued for the logger (default 1000)
+	QueueSize int `json:"queue_size"`
+	//Policy when the queue is full, drop-oldest (default), drop-newest or block
+	Policy string `json:"policy"`
+}
+
+//RemoteLogger remote log endpoint the jobs logs are shipped to
+type RemoteLogger struct {
+	Logger
+	//Format of the log messages, syslog (RFC5424) or gelf
+	Format string `json:"format"`
+	//Network tcp, tls or udp
+	Network string `json:"network"`
+	//Address (host:port) of the endpoint
+	Address string `json:"address"`
+	//CA optional path to the CA certificates (pem) used to verify the endpoint certificate (tls only)
+	CA string `json:"ca"`
+	//Buffer path of the disk buffer directory (default /var/cache/core0/logger/<name>)
+	Buffer string `json:"buffer"`
+	//BufferSize max size in bytes of the disk buffer (default 64MiB)
+	BufferSize int64 `json:"buffer_size"`
+}
+
+//LogStore persistent per job log store
+type LogStore struct {
+	Logger
+	Enabled bool `json:"enabled"`
+	//Dir root directory of the store (default /var/log/core0/jobs)
+	Dir string `json:"dir"`
+	//MaxSize size in bytes after which a job log file is rotated (default 10MiB)
+	MaxSize int64 `json:"max_size"`
+	//MaxAge seconds after which a job log file is rotated (default 86400)
+	MaxAge int `json:"max_age"`
+	//MaxFiles max number of rotated files kept per job (default 10)
+	MaxFiles int `json:"max_files"`
+	//Retention seconds after which rotated files and logs of finished jobs are deleted (default 7 days)
+	Retention int `json:"retention"`
+	//Compress rotated files with gzip
+	Compress bool `json:"compress"`
+}
+
+//Webhook jobs results webhooks config
+type Webhook struct {
+	//Secret key used to sign the webhooks payloads (HMAC-SHA256), payloads are not signed if not set
+	Secret string `json:"secret"`
+	//Timeout seconds to wait for a webhook response (default 10)
+	Timeout int `json:"timeout"`
+	//Retries number of delivery retries of the webhooks that don't set it (default 5)
+	Retries int `json:"retries"`
+	//Allow destinations the webhooks can post to (host names, *.domain patterns, ips or ip networks), all
+	//the destinations that are not denied are allowed if not set
+	Allow []string `json:"allow"`
+	//Deny destinations the webhooks can't post to, if neither allow nor deny are set, the node local
+	//addresses (loopback and link local) are denied
+	Deny []string `json:"deny"`
+}
+
+//AuthorizationRule allows the clients that match one of its subjects, organizations or scopes to run
+//the commands that match its patterns
+type AuthorizationRule struct {
+	//Subjects clients subjects (the JWT azp or username, or the client certificate common name)
+	Subjects []string `json:"subjects"`
+	//Organizations clients organizations (user:memberof:<org> JWT scopes, or the client certificate organizations)
+	Organizations []string `json:"organizations"`
+	//Scopes clients JWT scopes
+	Scopes []string `json:"scopes"`
+	//Commands patterns of the allowed commands names (ex: core.*)
+	Commands []string `json:"commands"`
+	//Containers if set (or tags), the commands are only allowed on these containers
+	Containers []uint16 `json:"containers"`
+	//Tags if set (or containers), the commands are only allowed on the containers with one of these tags
+	Tags []string `json:"tags"`
+}
+
+//Authorization commands authorization config
+type Authorization struct {
+	Enabled bool `json:"enabled"`
+	//Anonymous patterns of the commands the clients with no identity can run
+	Anonymous []string `json:"anonymous"`
+	//Rule authorization rules, a command is allowed if any rule allows it
+	Rule map[string]AuthorizationRule `json:"rule"`
+}
+
+//Audit commands audit log config
+type Audit struct {
+	Enabled bool `json:"enabled"`
+	//Dir directory of the audit log (default /var/log/core0/audit)
+	Dir string `json:"dir"`
+	//MaxSize size in bytes after which the audit log file is rotated (default 10MiB), rotated files are never deleted
+	MaxSize int64 `json:"max_size"`
 }
 
 //Extension cmd config
@@ -37,6 +127,30 @@ func (e *Extension) Key() string {
 	return e.key
 }
 
+//Queue named jobs queue config
+type Queue struct {
+	//Width max number of jobs from this queue that can run in parallel
+	Width int `json:"width"`
+	//Priority jobs of higher priority queues are started first
+	Priority int `json:"priority"`
+}
+
+//StatsRemote a remote time-series backend the aggregated stats are pushed to
+type StatsRemote struct {
+	//Protocol of the backend, one of influx-udp, influx-tcp or graphite
+	Protocol string `json:"protocol"`
+	//Address (host:port) of the backend
+	Address string `json:"address"`
+	//Prefix optional prefix of the metrics names
+	Prefix string `json:"prefix"`
+	//Buffer max number of points kept while the backend is unreachable (default 10000)
+	Buffer int `json:"buffer"`
+	//Batch max number of points sent at once (default 500)
+	Batch int `json:"batch"`
+	//Interval seconds between two flushes (default 10)
+	Interval int `json:"interval"`
+}
+
 //Security certificate path
 type Security struct {
 	CertificateAuthority string
@@ -61,24 +175,55 @@ type AppSettings struct {
 		MaxJobs  int      `json:"max_jobs"`
 		Include  []string `json:"include"`
 		Network  string   `json:"network"`
+		Journal  string   `json:"journal"`
 		LogLevel string   `json:"log_level"` //deprecated (not used)
 	} `json:"main"`
 
 	Globals   Globals              `json:"globals"`
 	Extension map[string]Extension `json:"extension"`
+	Queue     map[string]Queue     `json:"queue"`
 	Logging   struct {
 		File  Logger `json:"file"`
 		Ledis struct {
 			Logger `json:"ledis"`
 			Size   int64 `json:"size"`
 		}
+		Remote map[string]RemoteLogger `json:"remote"`
+		Store  LogStore                `json:"store"`
 	} `json:"logger"`
 
 	Containers struct {
 		MaxCount int `json:"max_count"`
+		//Store directory of the persisted containers definitions (default /var/cache/core0/containers)
+		Store string `json:"store"`
 	} `json:"containers"`
+	Webhook       Webhook       `json:"webhook"`
+	Authorization Authorization `json:"authorization"`
+	Audit         Audit         `json:"audit"`
+	API           struct {
+		//Socket path of the api unix socket (ex: /var/run/core0.sock), not served on a unix socket if not set
+		Socket string `json:"socket"`
+		//Listen address of the api tls port (ex: :6380), not served on tcp if not set
+		Listen string `json:"listen"`
+		//Cert, Key path of the tls certificate and key (pem)
+		Cert string `json:"cert"`
+		Key  string `json:"key"`
+		//ClientCA path of the CA certificates (pem) the clients certificates must be signed by
+		ClientCA string `json:"client_ca"`
+	} `json:"api"`
 	Stats struct {
 		Enabled bool `json:"enabled"`
+		//Periods aggregation periods in seconds (default [300, 3600])
+		Periods []int64 `json:"periods"`
+		//History number of aggregated samples kept per period (default 5)
+		History int `json:"history"`
+		//TTL seconds after which a key that is no longer reported is dropped (default 3600)
+		TTL int `json:"ttl"`
+		//Remote time-series backends the aggregated samples are pushed to
+		Remote map[string]StatsRemote `json:"remote"`
+		//Listen address of the metrics http exporter (ex: :9100), the exporter is disabled if not set. The
+		//exporter has no authentication, an address without a host only binds the loopback interface
+		Listen string `json:"listen"`
 	} `json:"stats"`
 }
 
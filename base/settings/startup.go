@@ -12,7 +12,10 @@ type Startup struct {
 	RunningDelay    int
 	RunningMatch    string
 	RecurringPeriod int
+	Schedule        *StartupSchedule
 	MaxRestart      int
+	RestartPolicy   *StartupRestartPolicy
+	Health          *StartupHealth
 	Protected       bool
 	Name            string
 	Tags            []string
@@ -22,6 +25,34 @@ type Startup struct {
 	key string
 }
 
+//StartupSchedule cron schedule of a startup service
+type StartupSchedule struct {
+	Cron     string
+	Jitter   int
+	Timezone string
+}
+
+//StartupRestartPolicy restart policy of a startup service
+type StartupRestartPolicy struct {
+	Policy     string
+	Delay      int
+	Multiplier float64
+	Max        int
+	ResetAfter int
+}
+
+//StartupHealth health check of a startup service
+type StartupHealth struct {
+	TCP         string
+	Unix        string
+	HTTP        string
+	Exec        []string
+	Interval    int
+	Timeout     int
+	Retries     int
+	StartPeriod int
+}
+
 func (s Startup) String() string {
 	return fmt.Sprintf("[%s]/{%s}", s.Key(), s.After)
 }
@@ -2,6 +2,7 @@ package client
 
 type AggregatorManager interface {
 	Query() (interface{}, error)
+	QueryRange(key string, start, end int64, periods ...int64) (interface{}, error)
 }
 
 func Aggregator(cl Client) AggregatorManager {
@@ -23,3 +24,22 @@ func (b *AggregatorMgr) Query() (interface{}, error) {
 	err = res.Json(&stats)
 	return stats, err
 }
+
+//QueryRange queries the state of the metric key (all keys if empty) with only the samples of the given periods (all
+//periods if not set) that overlap with the time range [start, end] (end of 0 means now)
+func (b *AggregatorMgr) QueryRange(key string, start, end int64, periods ...int64) (interface{}, error) {
+	var stats interface{}
+
+	res, err := sync(b, "aggregator.query", A{
+		"key":     key,
+		"start":   start,
+		"end":     end,
+		"periods": periods,
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	err = res.Json(&stats)
+	return stats, err
+}
@@ -9,16 +9,58 @@ type A map[string]interface{}
 type Tags []string
 
 type Command struct {
-	ID              string `json:"id"`
-	Command         string `json:"command"`
-	Arguments       A      `json:"arguments"`
-	Queue           string `json:"queue"`
-	StatsInterval   int    `json:"stats_interval,omitempty"`
-	MaxTime         int    `json:"max_time,omitempty"`
-	MaxRestart      int    `json:"max_restart,omitempty"`
-	RecurringPeriod int    `json:"recurring_period,omitempty"`
-	LogLevels       []int  `json:"log_levels,omitempty"`
-	Tags            Tags   `json:"tags"`
+	ID              string         `json:"id"`
+	Command         string         `json:"command"`
+	Arguments       A              `json:"arguments"`
+	Queue           string         `json:"queue"`
+	StatsInterval   int            `json:"stats_interval,omitempty"`
+	MaxTime         int            `json:"max_time,omitempty"`
+	MaxRestart      int            `json:"max_restart,omitempty"`
+	RecurringPeriod int            `json:"recurring_period,omitempty"`
+	Schedule        *Schedule      `json:"schedule,omitempty"`
+	RestartPolicy   *RestartPolicy `json:"restart_policy,omitempty"`
+	Limits          *Limits        `json:"limits,omitempty"`
+	LogLevels       []int          `json:"log_levels,omitempty"`
+	Tags            Tags           `json:"tags"`
+	Webhook         *Webhook       `json:"webhook,omitempty"`
+}
+
+type Webhook struct {
+	URL     string `json:"url"`
+	Retries int    `json:"retries,omitempty"`
+}
+
+type RestartPolicy struct {
+	Policy     string  `json:"policy"`
+	Delay      int     `json:"delay,omitempty"`
+	Multiplier float64 `json:"multiplier,omitempty"`
+	Max        int     `json:"max,omitempty"`
+	ResetAfter int     `json:"reset_after,omitempty"`
+}
+
+type BlkioThrottle struct {
+	Device    string `json:"device"`
+	ReadBps   uint64 `json:"read_bps,omitempty"`
+	WriteBps  uint64 `json:"write_bps,omitempty"`
+	ReadIOps  uint64 `json:"read_iops,omitempty"`
+	WriteIOps uint64 `json:"write_iops,omitempty"`
+}
+
+type Limits struct {
+	Memory        int64           `json:"memory,omitempty"`
+	Swap          int64           `json:"swap,omitempty"`
+	CPUShares     int             `json:"cpu_shares,omitempty"`
+	CPUQuota      int             `json:"cpu_quota,omitempty"`
+	CPUSet        string          `json:"cpuset,omitempty"`
+	BlkioWeight   int             `json:"blkio_weight,omitempty"`
+	BlkioThrottle []BlkioThrottle `json:"blkio_throttle,omitempty"`
+	Pids          int             `json:"pids,omitempty"`
+}
+
+type Schedule struct {
+	Cron     string `json:"cron"`
+	Jitter   int    `json:"jitter,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
 }
 
 type Option interface {
@@ -23,12 +23,14 @@ type ContainerCreateAguments struct {
 	Hostname    string            `json:"hostname"`     //hostname
 	Storage     string            `json:"storage"`      //ardb storage needed for g8ufs mounts.
 	Tags        []string          `json:"tags"`         //for searching containers
+	Limits      *Limits           `json:"limits"`       //resources limits of the container processes
 }
 
 type ContainerInfo struct {
 	Arguments ContainerCreateAguments `json:"arguments"`
 	Pid       int                     `json:"pid"`
 	Root      string                  `json:"root"`
+	State     string                  `json:"state"` //created, running, paused, stopped or exited
 }
 
 type ContainerResult struct {
@@ -52,6 +52,12 @@ type CPUStats struct {
 	User      float64 `json:"user"`
 }
 
+//BatchCommand is a command in a batch, it only runs if all the commands in DependsOn succeeded
+type BatchCommand struct {
+	Command
+	DependsOn []string `json:"depends_on,omitempty"`
+}
+
 type CoreManager interface {
 	System(cmd string, env map[string]string, cwd string, stdin string, opt ...Option) (JobId, error)
 	SystemArgs(cmd string, args []string, env map[string]string, cwd string, stdin string, opt ...Option) (JobId, error)
@@ -60,12 +66,14 @@ type CoreManager interface {
 	Jobs() ([]Job, error)
 	Job(job JobId) (*Job, error)
 	KillJob(job JobId, signal syscall.Signal) error
+	Stdin(job JobId, data []byte, eof bool) error
 	KillAllJobs() error
 	Process(pid ProcessId) (*Process, error)
 	ProcessAlive(pid ProcessId) (bool, error)
 	Processes() ([]Process, error)
 	KillProcess(pid ProcessId, signal syscall.Signal) error
 	State() (*JobStats, error)
+	Batch(cmds []BatchCommand, opt ...Option) (JobId, error)
 }
 
 type coreMgr struct {
@@ -110,6 +118,15 @@ func (s *coreMgr) Bash(bash, stdin string, opt ...Option) (JobId, error) {
 	}, opt...)
 }
 
+//Batch runs the commands honoring their dependencies, the string arguments of a command can refer
+//to the output of a dependency with {<id>.stdout}, {<id>.stderr} and {<id>.data}. Killing the batch
+//job cancels the whole batch
+func (s *coreMgr) Batch(cmds []BatchCommand, opt ...Option) (JobId, error) {
+	return s.cl.Raw("core.batch", A{
+		"commands": cmds,
+	}, opt...)
+}
+
 func (s *coreMgr) Ping() error {
 	_, err := sync(s.cl, "core.ping", A{})
 	return err
@@ -158,6 +175,17 @@ func (s *coreMgr) KillJob(job JobId, signal syscall.Signal) error {
 	return err
 }
 
+//Stdin feeds data to the stdin of an interactive job (started with interactive or tty set), eof closes the job stdin
+func (s *coreMgr) Stdin(job JobId, data []byte, eof bool) error {
+	_, err := sync(s.cl, "job.stdin", A{
+		"id":   job,
+		"data": data,
+		"eof":  eof,
+	})
+
+	return err
+}
+
 func (s *coreMgr) KillAllJobs() error {
 	if res, err := sync(s.cl, "job.killall", A{}); res != nil && res.State == StateKilled {
 		return nil
@@ -59,3 +59,15 @@ func (o idOpt) apply(cmd *Command) {
 func ID(id string) Option {
 	return idOpt{id}
 }
+
+type webhookOpt struct {
+	webhook Webhook
+}
+
+func (o webhookOpt) apply(cmd *Command) {
+	cmd.Webhook = &o.webhook
+}
+
+func WithWebhook(url string, retries int) Option {
+	return webhookOpt{Webhook{URL: url, Retries: retries}}
+}
@@ -24,6 +24,12 @@ const (
 	//StateDuplicateID dublicate id exit status
 	StateDuplicateID = State("DUPILICATE_ID")
 
+	//StateOOM the job process exceeded its memory limit
+	StateOOM = State("OOM")
+
+	//StateUnauthorized the client is not allowed to run the command
+	StateUnauthorized = State("UNAUTHORIZED")
+
 	LevelJson = 20
 )
 
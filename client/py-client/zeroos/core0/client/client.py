@@ -98,7 +98,7 @@ class Return:
     def state(self):
         """
         Exit state
-        :return: str one of [SUCCESS, ERROR, KILLED, TIMEOUT, UNKNOWN_CMD, DUPLICATE_ID]
+        :return: str one of [SUCCESS, ERROR, KILLED, TIMEOUT, UNKNOWN_CMD, DUPLICATE_ID, UNAUTHORIZED]
         """
         return self._payload['state']
 
@@ -917,7 +917,8 @@ class ContainerClient(BaseClient):
             'stream': bool,
             'tags': typchk.Or([str], typchk.IsNone()),
             'id': typchk.Or(str, typchk.IsNone()),
-            'recurring_period': typchk.Or(typchk.IsNone(), int)
+            'recurring_period': typchk.Or(typchk.IsNone(), int),
+            'webhook': typchk.Or(typchk.IsNone(), {'url': str, 'retries': int}),
         }
     })
 
@@ -943,7 +944,8 @@ class ContainerClient(BaseClient):
         """
         return self._zerotier
 
-    def raw(self, command, arguments, queue=None, max_time=None, stream=False, tags=None, id=None, recurring_period=None):
+    def raw(self, command, arguments, queue=None, max_time=None, stream=False, tags=None, id=None, recurring_period=None,
+            webhook=None, webhook_retries=0):
         """
         Implements the low level command call, this needs to build the command structure
         and push it on the correct queue.
@@ -957,6 +959,8 @@ class ContainerClient(BaseClient):
             client can stream output
         :param tags: job tags
         :param id: job id. Generated if not supplied
+        :param webhook: url the job result is posted to when the job exits
+        :param webhook_retries: max number of webhook delivery retries (node default if 0)
         :return: Response object
         """
         args = {
@@ -970,6 +974,7 @@ class ContainerClient(BaseClient):
                 'tags': tags,
                 'id': id,
                 'recurring_period': recurring_period,
+                'webhook': {'url': webhook, 'retries': webhook_retries} if webhook else None,
             },
         }
 
@@ -1029,7 +1034,9 @@ class ContainerManager:
         'cgroups': typchk.Or(
             typchk.IsNone(),
             [typchk.Length((str,), 2, 2)], # array of (str, str) tuples i.e [(subsyste, name), ...]
-        )
+        ),
+        'persist': bool,
+        'limits': typchk.Or(typchk.IsNone(), typchk.Map(str, typchk.Any())),
     })
 
     _get_chk = typchk.Checker({
@@ -1045,6 +1052,11 @@ class ContainerManager:
         typchk.Or(int, str)
     )
 
+    _stop_chk = typchk.Checker({
+        'container': int,
+        'timeout': typchk.Or(int, typchk.IsNone()),
+    })
+
     _nic_add = typchk.Checker({
         'container': int,
         'nic': _nic,
@@ -1062,7 +1074,7 @@ class ContainerManager:
 
     def create(self, root_url, mount=None, host_network=False, nics=DefaultNetworking, port=None,
         hostname=None, privileged=False, storage=None, name=None, tags=None, identity=None, env=None,
-        cgroups=None):
+        cgroups=None, persist=False, limits=None):
         """
         Creater a new container with the given root flist, mount points and
         zerotier id, and connected to the given bridges
@@ -1107,6 +1119,10 @@ class ContainerManager:
         :param env: a dict with the environment variables needed to be set for the container
         :param cgroups: custom list of cgroups to apply to this container on creation. formated as [(subsystem, name), ...]
                         please refer to the cgroup api for more detailes.
+        :param persist: If true, the container definition is persisted and the container is recreated
+                        (with the same id) when the node reboots.
+        :param limits: resources limits of the container processes, a dict with the jobs limits keys
+                       (memory, swap, cpu_shares, cpu_quota, cpuset, blkio_weight, blkio_throttle, pids)
         """
 
         if nics == self.DefaultNetworking:
@@ -1127,6 +1143,8 @@ class ContainerManager:
             'identity': identity,
             'env': env,
             'cgroups': cgroups,
+            'persist': persist,
+            'limits': limits,
         }
 
         # validate input
@@ -1154,7 +1172,7 @@ class ContainerManager:
 
     def list(self):
         """
-        List running containers
+        List containers, the container info object has the container state (created, running, paused, stopped or exited)
         :return: a dict with {container_id: <container info object>}
         """
         return self._client.json('corex.list', {})
@@ -1198,6 +1216,83 @@ class ContainerManager:
         if result.state != 'SUCCESS':
             raise RuntimeError('failed to terminate container: %s' % result.data)
 
+    def stop(self, container, timeout=None):
+        """
+        Stop a container given it's id, the container sandbox and configuration are kept
+        so it can be started again
+
+        :param container: container id
+        :param timeout: seconds to wait for the container to exit before it's killed (default 10)
+        :return:
+        """
+        self._client_chk.check(container)
+        args = {
+            'container': int(container),
+            'timeout': timeout,
+        }
+        self._stop_chk.check(args)
+
+        return self._client.json('corex.stop', args)
+
+    def start(self, container):
+        """
+        Start a stopped (or exited) container given it's id
+
+        :param container: container id
+        :return:
+        """
+        self._client_chk.check(container)
+        args = {
+            'container': int(container),
+        }
+
+        return self._client.json('corex.start', args)
+
+    def restart(self, container, timeout=None):
+        """
+        Stop the container (if running), and start it again
+
+        :param container: container id
+        :param timeout: seconds to wait for the container to exit before it's killed (default 10)
+        :return:
+        """
+        self._client_chk.check(container)
+        args = {
+            'container': int(container),
+            'timeout': timeout,
+        }
+        self._stop_chk.check(args)
+
+        return self._client.json('corex.restart', args)
+
+    def pause(self, container):
+        """
+        Pause (freeze) all the processes of a running container given it's id
+
+        :param container: container id
+        :return:
+        """
+        self._client_chk.check(container)
+        args = {
+            'container': int(container),
+        }
+
+        return self._client.json('corex.pause', args)
+
+    def resume(self, container):
+        """
+        Resume (thaw) a paused container given it's id
+
+        :param container: container id
+        :return:
+        """
+        self._client_chk.check(container)
+        args = {
+            'container': int(container),
+        }
+
+        return self._client.json('corex.resume', args)
+
     def nic_add(self, container, nic):
         """
         Hot plug a nic into a container
@@ -1265,7 +1360,8 @@ class ContainerManager:
     def backup(self, container, url):
         """
         Backup a container to the given restic url
-        all restic urls are supported
+        all restic urls are supported. A running container is paused while the
+        snapshot is taken, so it's consistent
 
         :param container:
         :param url: Url to restic repo
@@ -2709,6 +2805,17 @@ class Logger:
         'levels': [int],
     })
 
+    _query_chk = typchk.Checker({
+        'id': str,
+        'core': int,
+        'levels': [int],
+        'start': int,
+        'end': int,
+        'regex': str,
+        'offset': int,
+        'limit': int,
+    })
+
     def __init__(self, client):
         self._client = client
 
@@ -2732,6 +2839,14 @@ class Logger:
         """
         return self._client.json('logger.reopen', {})
 
+    def status(self):
+        """
+        Get the status of the loggers queues
+
+        :return: list of {'name', 'policy', 'size', 'queued', 'dropped', 'delivered', 'state'}
+        """
+        return self._client.json('logger.status', {})
+
     def subscribe(self, queue=None, *levels):
         """
         Subscribe to the aggregated log stream. On subscribe a ledis queue will be fed with all running processes
@@ -2763,6 +2878,93 @@ class Logger:
         """
         return self._client.json('logger.unsubscribe', {'queue': queue})
 
+    def query(self, id, core=0, levels=None, start=0, end=0, regex='', offset=0, limit=100):
+        """
+        Query the persisted logs of a job (requires the logs store to be enabled)
+
+        :param id: job id
+        :param core: container id if the job is running inside a container (0 for jobs on the host)
+        :param levels: only return records of these levels
+        :param start: only return records logged at or after this time (epoch seconds)
+        :param end: only return records logged at or before this time (epoch seconds)
+        :param regex: only return records with a message that matches this regular expression
+        :param offset: number of matching records to skip
+        :param limit: max number of records to return
+        :return: {'records': [...], 'next': <offset of next page>, 'more': <true if there are more records>}
+        """
+        args = {
+            'id': id,
+            'core': core,
+            'levels': levels or [],
+            'start': start,
+            'end': end,
+            'regex': regex,
+            'offset': offset,
+            'limit': limit,
+        }
+
+        self._query_chk.check(args)
+
+        return self._client.json('logger.query', args)
+
+
+class Audit:
+    _query_chk = typchk.Checker({
+        'id': str,
+        'command': str,
+        'subject': str,
+        'container': int,
+        'event': typchk.Enum('', 'submitted', 'rejected', 'finished'),
+        'state': str,
+        'start': int,
+        'end': int,
+        'offset': int,
+        'limit': int,
+    })
+
+    def __init__(self, client):
+        self._client = client
+
+    def query(self, id='', command='', subject='', container=0, event='', state='', start=0, end=0, offset=0, limit=100):
+        """
+        Query the audit log of the commands (requires the audit log to be enabled)
+
+        :param id: command id
+        :param command: pattern of the commands names (ex: core.*)
+        :param subject: client subject ('local' for the trusted local clients, 'anonymous' for the clients with no identity)
+        :param container: id of the container the commands run on
+        :param event: one of submitted, rejected or finished
+        :param state: final state of the jobs (finished entries)
+        :param start: only return entries logged at or after this time (epoch seconds)
+        :param end: only return entries logged at or before this time (epoch seconds)
+        :param offset: number of matching entries to skip
+        :param limit: max number of entries to return
+        :return: {'entries': [...], 'next': <offset of next page>, 'more': <true if there are more entries>}
+        """
+        args = {
+            'id': id,
+            'command': command,
+            'subject': subject,
+            'container': container,
+            'event': event,
+            'state': state,
+            'start': start,
+            'end': end,
+            'offset': offset,
+            'limit': limit,
+        }
+
+        self._query_chk.check(args)
+
+        return self._client.json('audit.query', args)
+
+    def verify(self):
+        """
+        Verify the hash chain of the audit log
+
+        :return: {'entries': <number of verified entries>, 'valid': bool, 'broken': <seq of the first broken entry>, 'reason': str}
+        """
+        return self._client.json('audit.verify', {})
 
 
 class Nft:
@@ -2846,12 +3048,15 @@ class AggregatorManager:
     _query_chk = typchk.Checker({
         'key': typchk.Or(str, typchk.IsNone()),
         'tags': typchk.Map(str, str),
+        'start': typchk.Or(int, typchk.IsNone()),
+        'end': typchk.Or(int, typchk.IsNone()),
+        'periods': typchk.Or([int], typchk.IsNone()),
     })
 
     def __init__(self, client):
         self._client = client
 
-    def query(self, key=None, **tags):
+    def query(self, key=None, start=None, end=None, periods=None, **tags):
         """
         Query zero-os aggregator for current state object of monitored metrics.
 
@@ -2864,6 +3069,9 @@ class AggregatorManager:
             self.query(key=key, id=value)
 
         :param key: metric key (ex: machine.memory.ram.available)
+        :param start: optional start of the time range (unix timestamp), only the samples that overlap with the range are returned
+        :param end: optional end of the time range (unix timestamp), default to now
+        :param periods: optional list of the aggregation periods to return (ex: [300])
         :param tags: optional tags filter
         :return: dict of {
             'key[/id]': state object
@@ -2872,6 +3080,9 @@ class AggregatorManager:
         args = {
             'key': key,
             'tags': tags,
+            'start': start,
+            'end': end,
+            'periods': periods,
         }
         self._query_chk.check(args)
 
@@ -2934,7 +3145,7 @@ class RTInfoManager:
 
 
 class CGroupManager:
-    _subsystem_chk = typchk.Enum('cpuset', 'memory')
+    _subsystem_chk = typchk.Enum('cpuset', 'memory', 'cpu', 'blkio', 'pids')
 
     _cgroup_chk = typchk.Checker({
         'subsystem': _subsystem_chk,
@@ -2959,6 +3170,30 @@ class CGroupManager:
         'mems': typchk.Or(typchk.IsNone(), str),
     })
 
+    _cpu_spec = typchk.Checker({
+        'name': str,
+        'shares': int,
+        'quota': int,
+        'period': int,
+    })
+
+    _blkio_spec = typchk.Checker({
+        'name': str,
+        'weight': int,
+        'throttle': [{
+            'device': str,
+            'read_bps': typchk.Or(int, typchk.Missing()),
+            'write_bps': typchk.Or(int, typchk.Missing()),
+            'read_iops': typchk.Or(int, typchk.Missing()),
+            'write_iops': typchk.Or(int, typchk.Missing()),
+        }],
+    })
+
+    _pids_spec = typchk.Checker({
+        'name': str,
+        'max': int,
+    })
+
     def __init__(self, client):
         self._client = client
 
@@ -3110,6 +3345,69 @@ class CGroupManager:
         self._cpuset_spec.check(args)
         return self._client.json('cgroup.cpuset.spec', args)
 
+    def cpu(self, name, shares=0, quota=0, period=0):
+        """
+        Set/Get cpu cgroup specification/limitation
+        the call to this method will always GET the current set values (and the group cpu usage in nanoseconds)
+        zero values are not changed
+
+        :param shares: Relative cpu weight of the group (default 1024)
+        :param quota: Cpu time in microseconds the group can use in each period, -1 means no limit
+        :param period: Length of the period in microseconds (default 100000)
+
+        :return: current cpu spec
+        """
+
+        args = {
+            'name': name,
+            'shares': shares,
+            'quota': quota,
+            'period': period,
+        }
+
+        self._cpu_spec.check(args)
+        return self._client.json('cgroup.cpu.spec', args)
+
+    def blkio(self, name, weight=0, throttle=None):
+        """
+        Set/Get blkio cgroup specification/limitation
+        the call to this method will always GET the current set values
+
+        :param weight: Relative block io weight of the group (10 to 1000), ignored if 0
+        :param throttle: list of device throttles of the form
+                         {'device': '/dev/sda', 'read_bps': 0, 'write_bps': 0, 'read_iops': 0, 'write_iops': 0}
+                         the device is a path or major:minor, zero values remove the throttle
+
+        :return: current blkio spec
+        """
+
+        args = {
+            'name': name,
+            'weight': weight,
+            'throttle': throttle or [],
+        }
+
+        self._blkio_spec.check(args)
+        return self._client.json('cgroup.blkio.spec', args)
+
+    def pids(self, name, max=0):
+        """
+        Set/Get pids cgroup specification/limitation
+        the call to this method will always GET the current set values (and the current number of processes)
+
+        :param max: Max number of processes in the group, -1 means no limit, ignored if 0
+
+        :return: current pids spec
+        """
+
+        args = {
+            'name': name,
+            'max': max,
+        }
+
+        self._pids_spec.check(args)
+        return self._client.json('cgroup.pids.spec', args)
+
 
 class ZFSManager():
     PATH = '/var/cache/router.yaml'
@@ -3267,7 +3565,8 @@ class Client(BaseClient):
         'max_time': typchk.Or(int, typchk.IsNone()),
         'stream': bool,
         'tags': typchk.Or([str], typchk.IsNone()),
-        'recurring_period': typchk.Or(typchk.IsNone(), int)
+        'recurring_period': typchk.Or(typchk.IsNone(), int),
+        'webhook': typchk.Or(typchk.IsNone(), {'url': str, 'retries': int}),
     })
 
     def __init__(self, host, port=6379, password="", db=0, ssl=True, timeout=None, testConnectionAttempts=3):
@@ -3291,6 +3590,7 @@ class Client(BaseClient):
         self._zerotier = ZerotierManager(self)
         self._kvm = KvmManager(self)
         self._logger = Logger(self)
+        self._audit = Audit(self)
         self._nft = Nft(self)
         self._config = Config(self)
         self._aggregator = AggregatorManager(self)
@@ -3378,6 +3678,14 @@ class Client(BaseClient):
         """
         return self._logger
 
+    @property
+    def audit(self):
+        """
+        Audit log
+        :return:
+        """
+        return self._audit
+
     @property
     def nft(self):
         """
@@ -3417,7 +3725,7 @@ class Client(BaseClient):
         return self._cgroup
 
     def raw(self, command, arguments, queue=None, max_time=None,
-            stream=False, tags=None, id=None, recurring_period=None):
+            stream=False, tags=None, id=None, recurring_period=None, webhook=None, webhook_retries=0):
         """
         Implements the low level command call, this needs to build the command structure
         and push it on the correct queue.
@@ -3431,6 +3739,8 @@ class Client(BaseClient):
             client can stream output
         :param tags: job tags
         :param id: job id. Generated if not supplied
+        :param webhook: url the job result is posted to when the job exits
+        :param webhook_retries: max number of webhook delivery retries (node default if 0)
         :return: Response object
         """
         if not id:
@@ -3444,7 +3754,8 @@ class Client(BaseClient):
             'max_time': max_time,
             'stream': stream,
             'tags': tags,
-            'recurring_period': recurring_period
+            'recurring_period': recurring_period,
+            'webhook': {'url': webhook, 'retries': webhook_retries} if webhook else None,
         }
 
         self._raw_chk.check(payload)